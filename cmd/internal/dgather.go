@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 
 	"github.com/pkg/errors"
@@ -13,6 +12,7 @@ import (
 	yaml "gopkg.in/yaml.v2"
 
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/versionedsecretstore"
 )
 
 // dataGatherCmd represents the dataGather command
@@ -48,7 +48,7 @@ inside a bosh manifest file.
 			return fmt.Errorf("instance-group-name cannot be empty")
 		}
 
-		boshManifestBytes, err := ioutil.ReadFile(boshManifestPath)
+		boshManifestBytes, err := versionedsecretstore.ReadMountedFile(boshManifestPath)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+
+	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/client/clientset/versioned"
+	kubeConfig "code.cloudfoundry.org/cf-operator/pkg/kube/config"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/errand"
+)
+
+// errandCmd represents the errand subcommand
+var errandCmd = &cobra.Command{
+	Use:   "errand",
+	Short: "Calls an errand subcommand",
+	Long:  `Calls an errand subcommand.`,
+}
+
+// errandRunCmd represents the errand run command
+var errandRunCmd = &cobra.Command{
+	Use:   "run <name> [flags]",
+	Short: "Runs an errand and follows its logs",
+	Long: `Runs an errand and follows its logs.
+
+This will set the trigger strategy of the errand's ExtendedJob to 'now',
+wait for the job to start and stream the logs of its containers. It exits
+with the exit code of the errand once the run finished, like
+'bosh run-errand'.
+
+The args and env of a container can be overridden for this run only.
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flags := cmd.Flags()
+		namespace, _ := flags.GetString("namespace")
+		if namespace == "" {
+			namespace = viper.GetString("cf-operator-namespace")
+		}
+		timeout, _ := flags.GetDuration("timeout")
+
+		overrides, err := errandOverrides(cmd)
+		if err != nil {
+			return err
+		}
+
+		restConfig, err := kubeConfig.NewGetter(newLogger()).Get(viper.GetString("kubeconfig"))
+		if err != nil {
+			return errors.Wrap(err, "getting the kube config")
+		}
+		clientset, err := kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			return errors.Wrap(err, "creating the kube client")
+		}
+		versionedClientset, err := versioned.NewForConfig(restConfig)
+		if err != nil {
+			return errors.Wrap(err, "creating the extended job client")
+		}
+
+		runner := errand.NewRunner(
+			clientset,
+			versionedClientset,
+			errand.NewLogStreamer(clientset.CoreV1()),
+			2*time.Second,
+			timeout,
+			os.Stdout,
+			os.Stderr,
+		)
+		exitCode, err := runner.Run(namespace, args[0], overrides)
+		if err != nil {
+			return err
+		}
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(errandCmd)
+	errandCmd.AddCommand(errandRunCmd)
+
+	flags := errandRunCmd.Flags()
+	flags.String("namespace", "", "Namespace of the errand (default: the operator namespace)")
+	flags.String("container", "", "Container the args and env are overridden for (default: the first container)")
+	flags.StringArray("arg", []string{}, "Argument of the container for this run, replaces all args of the container, can be given several times")
+	flags.StringArray("env", []string{}, "Environment variable of the container for this run as KEY=VALUE, can be given several times")
+	flags.Duration("timeout", 0, "Maximum time to wait for the job, for each container to start and for the result, 0 waits forever")
+}
+
+// errandOverrides returns the container overrides given by the flags
+func errandOverrides(cmd *cobra.Command) ([]ejv1.ContainerOverride, error) {
+	flags := cmd.Flags()
+	args, _ := flags.GetStringArray("arg")
+	envs, _ := flags.GetStringArray("env")
+	if !flags.Changed("arg") && len(envs) == 0 {
+		return nil, nil
+	}
+
+	// The runner applies overrides without a container name to the first container
+	container, _ := flags.GetString("container")
+	override := ejv1.ContainerOverride{Name: container}
+	if flags.Changed("arg") {
+		override.Args = args
+	}
+	for _, env := range envs {
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid env '%s', must be KEY=VALUE", env)
+		}
+		override.Env = append(override.Env, corev1.EnvVar{Name: parts[0], Value: parts[1]})
+	}
+
+	return []ejv1.ContainerOverride{override}, nil
+}
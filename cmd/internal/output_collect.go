@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/extendedjob"
+)
+
+// outputCollectCmd represents the output-collect command
+var outputCollectCmd = &cobra.Command{
+	Use:   "output-collect [flags]",
+	Short: "Collects the output files of an extended job",
+	Long: `Collects the output files of an extended job.
+
+This will read the files in the output directory of every container
+and write them as JSON to STDOUT, one object per container with
+the file names as keys.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputDir := viper.GetString("output-files-dir")
+		if len(outputDir) == 0 {
+			return fmt.Errorf("output directory cannot be empty")
+		}
+
+		output, err := extendedjob.CollectOutputFiles(outputDir)
+		if err != nil {
+			return err
+		}
+
+		jsonBytes, err := json.Marshal(output)
+		if err != nil {
+			return errors.Wrapf(err, "could not marshal json output")
+		}
+
+		f := bufio.NewWriter(os.Stdout)
+		defer f.Flush()
+		_, err = f.Write(jsonBytes)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	utilCmd.AddCommand(outputCollectCmd)
+
+	outputCollectCmd.Flags().String("output-files-dir", extendedjob.OutputDir, "path to the directory holding the output directories of the containers")
+
+	viper.BindPFlag("output-files-dir", outputCollectCmd.Flags().Lookup("output-files-dir"))
+
+	argToEnv := map[string]string{
+		"output-files-dir": "OUTPUT_FILES_DIR",
+	}
+	AddEnvToUsage(outputCollectCmd, argToEnv)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	golog "log"
 	"os"
+	"strings"
 	"time"
 
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
@@ -16,6 +17,8 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc" // from https://github.com/kubernetes/client-go/issues/345
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/runtime/signals"
@@ -41,30 +44,70 @@ var rootCmd = &cobra.Command{
 		}
 
 		cfOperatorNamespace := viper.GetString("cf-operator-namespace")
+		watchNamespaces := []string{}
+		for _, namespace := range strings.Split(viper.GetString("watch-namespaces"), ",") {
+			if namespace = strings.TrimSpace(namespace); namespace != "" {
+				watchNamespaces = append(watchNamespaces, namespace)
+			}
+		}
+		watchAllNamespaces := viper.GetBool("watch-all-namespaces")
 		manifest.DockerImageOrganization = viper.GetString("docker-image-org")
 		manifest.DockerImageRepository = viper.GetString("docker-image-repository")
 		manifest.DockerImageTag = viper.GetString("docker-image-tag")
+		manifest.DockerImagePullPolicy = corev1.PullPolicy(viper.GetString("docker-image-pull-policy"))
+		switch manifest.DockerImagePullPolicy {
+		case "", corev1.PullAlways, corev1.PullIfNotPresent, corev1.PullNever:
+		default:
+			log.Fatalf("invalid image pull policy '%s'", manifest.DockerImagePullPolicy)
+		}
 
 		log.Infof("Starting cf-operator %s with namespace %s", version.Version, cfOperatorNamespace)
 		log.Infof("cf-operator docker image: %s", manifest.GetOperatorDockerImage())
+		if watchAllNamespaces {
+			log.Info("Watching all namespaces")
+		} else if len(watchNamespaces) > 0 {
+			log.Infof("Watching namespaces %s", strings.Join(watchNamespaces, ", "))
+		}
 
 		operatorWebhookHost := viper.GetString("operator-webhook-service-host")
 		operatorWebhookPort := viper.GetInt32("operator-webhook-service-port")
+		operatorWebhookServiceName := viper.GetString("operator-webhook-service-name")
+
+		if operatorWebhookHost == "" && operatorWebhookServiceName == "" {
+			log.Fatal("required flag 'operator-webhook-service-host' or 'operator-webhook-service-name' not set (env variables: CF_OPERATOR_WEBHOOK_SERVICE_HOST, CF_OPERATOR_WEBHOOK_SERVICE_NAME)")
+		}
+
+		if viper.GetInt("versioned-secret-retention") > 0 && viper.GetDuration("versioned-secret-prune-interval") <= 0 {
+			log.Fatal("'versioned-secret-prune-interval' must be positive when pruning versioned secrets")
+		}
 
-		if operatorWebhookHost == "" {
-			log.Fatal("required flag 'operator-webhook-service-host' not set (env variable: CF_OPERATOR_WEBHOOK_SERVICE_HOST)")
+		controllers := map[string]config.ControllerConfig{}
+		if err := viper.UnmarshalKey("controllers", &controllers); err != nil {
+			log.Fatalf("invalid controller settings: %v", err)
 		}
 
 		config := &config.Config{
-			CtxTimeOut:        10 * time.Second,
-			Namespace:         cfOperatorNamespace,
-			WebhookServerHost: operatorWebhookHost,
-			WebhookServerPort: operatorWebhookPort,
-			Fs:                afero.NewOsFs(),
+			CtxTimeOut:                   viper.GetDuration("ctx-timeout"),
+			MaxConcurrentReconciles:      viper.GetInt("max-concurrent-reconciles"),
+			BackoffMin:                   viper.GetDuration("backoff-min"),
+			BackoffMax:                   viper.GetDuration("backoff-max"),
+			Controllers:                  controllers,
+			Namespace:                    cfOperatorNamespace,
+			WatchNamespaces:              watchNamespaces,
+			WatchAllNamespaces:           watchAllNamespaces,
+			LeaderElection:               viper.GetBool("leader-elect"),
+			LeaseDuration:                viper.GetDuration("leader-elect-lease-duration"),
+			RenewDeadline:                viper.GetDuration("leader-elect-renew-deadline"),
+			WebhookServerHost:            operatorWebhookHost,
+			WebhookServerPort:            operatorWebhookPort,
+			WebhookServiceName:           operatorWebhookServiceName,
+			VersionedSecretRetention:     viper.GetInt("versioned-secret-retention"),
+			VersionedSecretPruneInterval: viper.GetDuration("versioned-secret-prune-interval"),
+			Fs:                           afero.NewOsFs(),
 		}
 		ctx := ctxlog.NewParentContext(log)
 
-		mgr, err := operator.NewManager(ctx, config, restConfig, manager.Options{Namespace: cfOperatorNamespace})
+		mgr, err := operator.NewManager(ctx, config, restConfig, manager.Options{MetricsBindAddress: viper.GetString("metrics-bind-address")})
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -92,39 +135,125 @@ func Execute() {
 func init() {
 	pf := rootCmd.PersistentFlags()
 
+	pf.String("config", "", "Path to a config file, its keys are the names of the flags. Settings of single controllers are read from the 'controllers' key.")
 	pf.StringP("kubeconfig", "c", "", "Path to a kubeconfig, not required in-cluster")
-	pf.StringP("cf-operator-namespace", "n", "default", "Namespace to watch for BOSH deployments")
+	pf.String("log-level", "debug", "Log level, one of debug, info, warn or error")
+	pf.String("log-format", "console", "Log format, console for human readable logs or json for structured logs")
+	pf.String("metrics-bind-address", ":60000", "Address the Prometheus metrics are served on, '0' disables them")
+	pf.Duration("ctx-timeout", 10*time.Second, "Time a single reconcile of a controller may take")
+	pf.Int("max-concurrent-reconciles", 1, "Number of requests each controller handles in parallel")
+	pf.Duration("backoff-min", 0, "Delay before retrying a failed reconcile, doubled for every further failure. The controllers' default rate limiting is used if not set.")
+	pf.Duration("backoff-max", 5*time.Minute, "Maximum delay before retrying a failed reconcile")
+	pf.StringP("cf-operator-namespace", "n", "default", "Namespace the operator runs in, it is watched for BOSH deployments unless other namespaces are given")
+	pf.String("watch-namespaces", "", "Comma separated list of namespaces to watch for BOSH deployments")
+	pf.Bool("watch-all-namespaces", false, "Watch all namespaces for BOSH deployments")
+	pf.Bool("leader-elect", false, "Use leader election, so only one of several replicas runs the controllers")
+	pf.Duration("leader-elect-lease-duration", 15*time.Second, "Time non-leader replicas wait before trying to acquire the leader lock")
+	pf.Duration("leader-elect-renew-deadline", 10*time.Second, "Time the leader retries renewing the leader lock before giving up")
 	pf.StringP("docker-image-org", "o", "cfcontainerization", "Dockerhub organization that provides the operator docker image")
 	pf.StringP("docker-image-repository", "r", "cf-operator", "Dockerhub repository that provides the operator docker image")
 	pf.StringP("operator-webhook-service-host", "w", "", "Hostname/IP under which the webhook server can be reached from the cluster")
 	pf.StringP("operator-webhook-service-port", "p", "2999", "Port the webhook server listens on")
+	pf.String("operator-webhook-service-name", "", "Name of a service in the operator namespace, which forwards port 443 to the webhook server. Replaces the webhook host when running in-cluster.")
 	pf.StringP("docker-image-tag", "t", version.Version, "Tag of the operator docker image")
+	pf.String("docker-image-pull-policy", "", "Image pull policy of all containers, one of Always, IfNotPresent or Never")
+	pf.Int("versioned-secret-retention", 5, "Number of versions of each versioned secret kept when pruning, versions in use are kept, too. Pruning is disabled if 0.")
+	pf.Duration("versioned-secret-prune-interval", 10*time.Minute, "Time between two prunings of versioned secrets")
+	viper.BindPFlag("config", pf.Lookup("config"))
 	viper.BindPFlag("kubeconfig", pf.Lookup("kubeconfig"))
+	viper.BindPFlag("log-level", pf.Lookup("log-level"))
+	viper.BindPFlag("log-format", pf.Lookup("log-format"))
+	viper.BindPFlag("metrics-bind-address", pf.Lookup("metrics-bind-address"))
+	viper.BindPFlag("ctx-timeout", pf.Lookup("ctx-timeout"))
+	viper.BindPFlag("max-concurrent-reconciles", pf.Lookup("max-concurrent-reconciles"))
+	viper.BindPFlag("backoff-min", pf.Lookup("backoff-min"))
+	viper.BindPFlag("backoff-max", pf.Lookup("backoff-max"))
 	viper.BindPFlag("cf-operator-namespace", pf.Lookup("cf-operator-namespace"))
+	viper.BindPFlag("watch-namespaces", pf.Lookup("watch-namespaces"))
+	viper.BindPFlag("watch-all-namespaces", pf.Lookup("watch-all-namespaces"))
+	viper.BindPFlag("leader-elect", pf.Lookup("leader-elect"))
+	viper.BindPFlag("leader-elect-lease-duration", pf.Lookup("leader-elect-lease-duration"))
+	viper.BindPFlag("leader-elect-renew-deadline", pf.Lookup("leader-elect-renew-deadline"))
 	viper.BindPFlag("docker-image-org", pf.Lookup("docker-image-org"))
 	viper.BindPFlag("docker-image-repository", pf.Lookup("docker-image-repository"))
 	viper.BindPFlag("operator-webhook-service-host", pf.Lookup("operator-webhook-service-host"))
 	viper.BindPFlag("operator-webhook-service-port", pf.Lookup("operator-webhook-service-port"))
+	viper.BindPFlag("operator-webhook-service-name", pf.Lookup("operator-webhook-service-name"))
 	viper.BindPFlag("docker-image-tag", rootCmd.PersistentFlags().Lookup("docker-image-tag"))
+	viper.BindPFlag("docker-image-pull-policy", pf.Lookup("docker-image-pull-policy"))
+	viper.BindPFlag("versioned-secret-retention", pf.Lookup("versioned-secret-retention"))
+	viper.BindPFlag("versioned-secret-prune-interval", pf.Lookup("versioned-secret-prune-interval"))
 
 	argToEnv := map[string]string{
-		"kubeconfig":                    "KUBECONFIG",
-		"cf-operator-namespace":         "CF_OPERATOR_NAMESPACE",
-		"docker-image-org":              "DOCKER_IMAGE_ORG",
-		"docker-image-repository":       "DOCKER_IMAGE_REPOSITORY",
-		"operator-webhook-service-host": "CF_OPERATOR_WEBHOOK_SERVICE_HOST",
-		"operator-webhook-service-port": "CF_OPERATOR_WEBHOOK_SERVICE_PORT",
-		"docker-image-tag":              "DOCKER_IMAGE_TAG",
+		"config":                          "CF_OPERATOR_CONFIG",
+		"kubeconfig":                      "KUBECONFIG",
+		"log-level":                       "CF_OPERATOR_LOG_LEVEL",
+		"log-format":                      "CF_OPERATOR_LOG_FORMAT",
+		"metrics-bind-address":            "CF_OPERATOR_METRICS_BIND_ADDRESS",
+		"ctx-timeout":                     "CF_OPERATOR_CTX_TIMEOUT",
+		"max-concurrent-reconciles":       "CF_OPERATOR_MAX_CONCURRENT_RECONCILES",
+		"backoff-min":                     "CF_OPERATOR_BACKOFF_MIN",
+		"backoff-max":                     "CF_OPERATOR_BACKOFF_MAX",
+		"cf-operator-namespace":           "CF_OPERATOR_NAMESPACE",
+		"watch-namespaces":                "CF_OPERATOR_WATCH_NAMESPACES",
+		"watch-all-namespaces":            "CF_OPERATOR_WATCH_ALL_NAMESPACES",
+		"leader-elect":                    "CF_OPERATOR_LEADER_ELECT",
+		"leader-elect-lease-duration":     "CF_OPERATOR_LEADER_ELECT_LEASE_DURATION",
+		"leader-elect-renew-deadline":     "CF_OPERATOR_LEADER_ELECT_RENEW_DEADLINE",
+		"docker-image-org":                "DOCKER_IMAGE_ORG",
+		"docker-image-repository":         "DOCKER_IMAGE_REPOSITORY",
+		"operator-webhook-service-host":   "CF_OPERATOR_WEBHOOK_SERVICE_HOST",
+		"operator-webhook-service-port":   "CF_OPERATOR_WEBHOOK_SERVICE_PORT",
+		"operator-webhook-service-name":   "CF_OPERATOR_WEBHOOK_SERVICE_NAME",
+		"docker-image-tag":                "DOCKER_IMAGE_TAG",
+		"docker-image-pull-policy":        "DOCKER_IMAGE_PULL_POLICY",
+		"versioned-secret-retention":      "CF_OPERATOR_VERSIONED_SECRET_RETENTION",
+		"versioned-secret-prune-interval": "CF_OPERATOR_VERSIONED_SECRET_PRUNE_INTERVAL",
 	}
 
 	// Add env variables to help
 	AddEnvToUsage(rootCmd, argToEnv)
+
+	cobra.OnInitialize(readConfigFile)
+}
+
+// readConfigFile reads the settings from the config file, if one is given. Flags and env
+// variables take precedence.
+func readConfigFile() {
+	configFile := viper.GetString("config")
+	if configFile == "" {
+		return
+	}
+
+	viper.SetConfigFile(configFile)
+	if err := viper.ReadInConfig(); err != nil {
+		golog.Fatalf("cannot read config file '%s': %v", configFile, err)
+	}
 }
 
 // newLogger returns a new zap logger
 func newLogger(options ...zap.Option) *zap.SugaredLogger {
 
-	logger, err := zap.NewDevelopment(options...)
+	level := zap.NewAtomicLevel()
+	if err := level.UnmarshalText([]byte(viper.GetString("log-level"))); err != nil {
+		golog.Fatalf("invalid log level '%s': %v", viper.GetString("log-level"), err)
+	}
+
+	var config zap.Config
+	switch format := viper.GetString("log-format"); format {
+	case "console":
+		config = zap.NewDevelopmentConfig()
+	case "json":
+		// Log aggregation systems need every line, so lines are not sampled
+		config = zap.NewProductionConfig()
+		config.Sampling = nil
+		config.EncoderConfig.TimeKey = "timestamp"
+		config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	default:
+		golog.Fatalf("invalid log format '%s', must be console or json", format)
+	}
+	config.Level = level
+	logger, err := config.Build(options...)
 	if err != nil {
 		golog.Fatalf("cannot initialize ZAP logger: %v", err)
 	}
@@ -11,6 +11,7 @@ import (
 	"github.com/spf13/viper"
 
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/versionedsecretstore"
 )
 
 // templateRenderCmd represents the template-render command
@@ -61,7 +62,12 @@ This will render a provided manifest instance-group
 			specIndex = (azIndex-1)*replicas + podOrdinal
 		}
 
-		return manifest.RenderJobTemplates(boshManifestPath, jobsDir, outputDir, instanceGroupName, specIndex)
+		resolvedYML, err := versionedsecretstore.ReadMountedFile(boshManifestPath)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't read manifest file %s", boshManifestPath)
+		}
+
+		return manifest.RenderJobTemplatesFromManifest(resolvedYML, jobsDir, outputDir, instanceGroupName, specIndex)
 	},
 }
 
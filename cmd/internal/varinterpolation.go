@@ -1,11 +1,11 @@
 package cmd
 
 import (
-	"io/ioutil"
 	"os"
 	"path/filepath"
 
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/versionedsecretstore"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -62,7 +62,7 @@ func (i *initCmd) runVariableInterpolationCmd(cmd *cobra.Command, args []string)
 	}
 
 	// Read files
-	boshManifestBytes, err := ioutil.ReadFile(boshManifestPath)
+	boshManifestBytes, err := versionedsecretstore.ReadMountedFile(boshManifestPath)
 	if err != nil {
 		return errors.Wrapf(err, "could not read manifest variable")
 	}
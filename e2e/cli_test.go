@@ -28,20 +28,38 @@ var _ = Describe("CLI", func() {
 			session, err := act("help")
 			Expect(err).ToNot(HaveOccurred())
 			Eventually(session.Out).Should(Say(`Flags:
-  -n, --cf-operator-namespace string           \(CF_OPERATOR_NAMESPACE\) Namespace to watch for BOSH deployments \(default "default"\)
-  -o, --docker-image-org string                \(DOCKER_IMAGE_ORG\) Dockerhub organization that provides the operator docker image \(default "cfcontainerization"\)
-  -r, --docker-image-repository string         \(DOCKER_IMAGE_REPOSITORY\) Dockerhub repository that provides the operator docker image \(default "cf-operator"\)
-  -t, --docker-image-tag string                \(DOCKER_IMAGE_TAG\) Tag of the operator docker image \(default "\d+.\d+.\d+"\)
-  -h, --help                                   help for cf-operator
-  -c, --kubeconfig string                      \(KUBECONFIG\) Path to a kubeconfig, not required in-cluster
-  -w, --operator-webhook-service-host string   \(CF_OPERATOR_WEBHOOK_SERVICE_HOST\) Hostname/IP under which the webhook server can be reached from the cluster
-  -p, --operator-webhook-service-port string   \(CF_OPERATOR_WEBHOOK_SERVICE_PORT\) Port the webhook server listens on \(default "2999"\)`))
+      --backoff-max duration                       \(CF_OPERATOR_BACKOFF_MAX\) Maximum delay before retrying a failed reconcile \(default 5m0s\)
+      --backoff-min duration                       \(CF_OPERATOR_BACKOFF_MIN\) Delay before retrying a failed reconcile, doubled for every further failure. The controllers' default rate limiting is used if not set.
+  -n, --cf-operator-namespace string               \(CF_OPERATOR_NAMESPACE\) Namespace the operator runs in, it is watched for BOSH deployments unless other namespaces are given \(default "default"\)
+      --config string                              \(CF_OPERATOR_CONFIG\) Path to a config file, its keys are the names of the flags. Settings of single controllers are read from the 'controllers' key.
+      --ctx-timeout duration                       \(CF_OPERATOR_CTX_TIMEOUT\) Time a single reconcile of a controller may take \(default 10s\)
+  -o, --docker-image-org string                    \(DOCKER_IMAGE_ORG\) Dockerhub organization that provides the operator docker image \(default "cfcontainerization"\)
+      --docker-image-pull-policy string            \(DOCKER_IMAGE_PULL_POLICY\) Image pull policy of all containers, one of Always, IfNotPresent or Never
+  -r, --docker-image-repository string             \(DOCKER_IMAGE_REPOSITORY\) Dockerhub repository that provides the operator docker image \(default "cf-operator"\)
+  -t, --docker-image-tag string                    \(DOCKER_IMAGE_TAG\) Tag of the operator docker image \(default "\d+.\d+.\d+"\)
+  -h, --help                                       help for cf-operator
+  -c, --kubeconfig string                          \(KUBECONFIG\) Path to a kubeconfig, not required in-cluster
+      --leader-elect                               \(CF_OPERATOR_LEADER_ELECT\) Use leader election, so only one of several replicas runs the controllers
+      --leader-elect-lease-duration duration       \(CF_OPERATOR_LEADER_ELECT_LEASE_DURATION\) Time non-leader replicas wait before trying to acquire the leader lock \(default 15s\)
+      --leader-elect-renew-deadline duration       \(CF_OPERATOR_LEADER_ELECT_RENEW_DEADLINE\) Time the leader retries renewing the leader lock before giving up \(default 10s\)
+      --log-format string                          \(CF_OPERATOR_LOG_FORMAT\) Log format, console for human readable logs or json for structured logs \(default "console"\)
+      --log-level string                           \(CF_OPERATOR_LOG_LEVEL\) Log level, one of debug, info, warn or error \(default "debug"\)
+      --max-concurrent-reconciles int              \(CF_OPERATOR_MAX_CONCURRENT_RECONCILES\) Number of requests each controller handles in parallel \(default 1\)
+      --metrics-bind-address string                \(CF_OPERATOR_METRICS_BIND_ADDRESS\) Address the Prometheus metrics are served on, '0' disables them \(default ":60000"\)
+  -w, --operator-webhook-service-host string       \(CF_OPERATOR_WEBHOOK_SERVICE_HOST\) Hostname/IP under which the webhook server can be reached from the cluster
+      --operator-webhook-service-name string       \(CF_OPERATOR_WEBHOOK_SERVICE_NAME\) Name of a service in the operator namespace, which forwards port 443 to the webhook server. Replaces the webhook host when running in-cluster.
+  -p, --operator-webhook-service-port string       \(CF_OPERATOR_WEBHOOK_SERVICE_PORT\) Port the webhook server listens on \(default "2999"\)
+      --versioned-secret-prune-interval duration   \(CF_OPERATOR_VERSIONED_SECRET_PRUNE_INTERVAL\) Time between two prunings of versioned secrets \(default 10m0s\)
+      --versioned-secret-retention int             \(CF_OPERATOR_VERSIONED_SECRET_RETENTION\) Number of versions of each versioned secret kept when pruning, versions in use are kept, too. Pruning is disabled if 0. \(default 5\)
+      --watch-all-namespaces                       \(CF_OPERATOR_WATCH_ALL_NAMESPACES\) Watch all namespaces for BOSH deployments
+      --watch-namespaces string                    \(CF_OPERATOR_WATCH_NAMESPACES\) Comma separated list of namespaces to watch for BOSH deployments`))
 		})
 
 		It("shows all available commands", func() {
 			session, err := act("help")
 			Expect(err).ToNot(HaveOccurred())
 			Eventually(session.Out).Should(Say(`Available Commands:
+  errand      Calls an errand subcommand
   help        Help about any command
   util        Calls a utility subcommand
   version     Print the version number
@@ -132,7 +132,7 @@ var _ = Describe("Examples", func() {
 				err = kubectlHelper.DeleteResource(namespace, "secret", "nats-deployment.ig-resolved.nats-v1")
 				Expect(err).ToNot(HaveOccurred())
 
-				err = kubectlHelper.DeleteResource(namespace, "secret", "nats-deployment.with-ops")
+				err = kubectlHelper.DeleteResource(namespace, "secret", "nats-deployment.with-ops-v1")
 				Expect(err).ToNot(HaveOccurred())
 
 				err = kubectlHelper.DeleteResource(namespace, "secret", "nats-deployment.with-vars.interpolation-v1")
@@ -169,7 +169,7 @@ var _ = Describe("Examples", func() {
 				err = kubectlHelper.Delete(namespace, yamlFilePath)
 				Expect(err).ToNot(HaveOccurred())
 
-				err = kubectlHelper.DeleteResource(namespace, "secret", "nats-deployment.with-ops")
+				err = kubectlHelper.DeleteResource(namespace, "secret", "nats-deployment.with-ops-v1")
 				Expect(err).ToNot(HaveOccurred())
 
 				err = kubectlHelper.DeleteResource(namespace, "secret", "nats-deployment.ig-resolved.nats-v1")
@@ -323,6 +323,33 @@ var _ = Describe("Examples", func() {
 				Expect(err).ToNot(HaveOccurred())
 			})
 
+			It("extended-job output files example must work", func() {
+
+				yamlFilePath := examplesDir + "extended-job/exjob_output-files.yaml"
+
+				By("Creating exjob")
+				kubectlHelper := testing.NewKubectl()
+				err := kubectlHelper.Create(namespace, yamlFilePath)
+				Expect(err).ToNot(HaveOccurred())
+
+				By("Checking for pods")
+				err = kubectlHelper.WaitLabelFilter(namespace, "complete", "pod", "ejob-name=myfiles")
+				Expect(err).ToNot(HaveOccurred())
+
+				By("Checking the secret data created")
+				outSecret, err := kubectlHelper.GetSecretData(namespace, "files-files", "go-template={{.data.bar}}")
+				Expect(err).ToNot(HaveOccurred())
+				outSecretDecoded, _ := b64.StdEncoding.DecodeString(string(outSecret))
+				Expect(string(outSecretDecoded)).To(Equal("baz"))
+
+				By("Clean up resources")
+				err = kubectlHelper.DeleteLabelFilter(namespace, "pod", "ejob-name=myfiles")
+				Expect(err).ToNot(HaveOccurred())
+
+				err = kubectlHelper.Delete(namespace, yamlFilePath)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
 			It("extended-secret example must work", func() {
 
 				yamlFilePath := examplesDir + "extended-secret/password.yaml"
@@ -62,12 +62,12 @@ func InterpolateVariables(log *zap.SugaredLogger, boshManifestBytes []byte, vari
 			// Re-unmarshal staticVars
 			bytes, err := yaml.Marshal(staticVars)
 			if err != nil {
-				return errors.Wrapf(err, "could not marshal variables: %s", string(bytes))
+				return errors.Wrapf(err, "could not marshal variable '%s'", variable.Name())
 			}
 
 			err = yaml.Unmarshal(bytes, &staticVars)
 			if err != nil {
-				return errors.Wrapf(err, "could not unmarshal variables: %s", string(bytes))
+				return errors.Wrapf(err, "could not unmarshal variable '%s'", variable.Name())
 			}
 
 			vars = append(vars, staticVars)
@@ -36,6 +36,8 @@ var (
 	DockerImageRepository = ""
 	// DockerImageTag is the tag of the operator image
 	DockerImageTag = ""
+	// DockerImagePullPolicy is the pull policy of all containers, Kubernetes' default is used if empty
+	DockerImagePullPolicy corev1.PullPolicy
 	// LabelDeploymentName is the name of a label for the deployment name
 	LabelDeploymentName = fmt.Sprintf("%s/deployment-name", apis.GroupName)
 	// LabelInstanceGroupName is the name of a label for an instance group name
@@ -112,13 +114,15 @@ func (m *Manifest) variableInterpolationJob(namespace string) (*ejv1.ExtendedJob
 	// Prepare Volumes and Volume mounts
 
 	// This is a volume for the "not interpolated" manifest,
-	// that has the ops files applied, but still contains '((vars))'
+	// that has the ops files applied, but still contains '((vars))'.
+	// It's the latest revision of the desired manifest, the versioned
+	// secret reference is updated when the job starts.
 	volumes := []corev1.Volume{
 		{
 			Name: generateVolumeName(manifestSecretName),
 			VolumeSource: corev1.VolumeSource{
 				Secret: &corev1.SecretVolumeSource{
-					SecretName: manifestSecretName,
+					SecretName: fmt.Sprintf("%s-v0", manifestSecretName),
 				},
 			},
 		},
@@ -214,11 +218,12 @@ func (m *Manifest) variableInterpolationJob(namespace string) (*ejv1.ExtendedJob
 					RestartPolicy: corev1.RestartPolicyOnFailure,
 					Containers: []corev1.Container{
 						{
-							Name:         VarInterpolationContainerName,
-							Image:        GetOperatorDockerImage(),
-							Command:      cmd,
-							Args:         args,
-							VolumeMounts: volumeMounts,
+							Name:            VarInterpolationContainerName,
+							Image:           GetOperatorDockerImage(),
+							ImagePullPolicy: DockerImagePullPolicy,
+							Command:         cmd,
+							Args:            args,
+							VolumeMounts:    volumeMounts,
 							Env: []corev1.EnvVar{
 								{
 									Name:  "BOSH_MANIFEST_PATH",
@@ -263,6 +268,10 @@ func (m *Manifest) SHA1() (string, error) {
 
 // dataGatheringJob generates the Data Gathering Job for a manifest
 func (m *Manifest) dataGatheringJob(namespace string) (*ejv1.ExtendedJob, error) {
+	manifestSignature, err := m.SHA1()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not calculate manifest SHA1")
+	}
 
 	_, interpolatedManifestSecretName := names.CalculateEJobOutputSecretPrefixAndName(
 		names.DeploymentSecretTypeManifestAndVars,
@@ -309,10 +318,11 @@ func (m *Manifest) dataGatheringJob(namespace string) (*ejv1.ExtendedJob, error)
 		// One container per Instance Group
 		// There will be one secret generated for each of these containers
 		containers[idx] = corev1.Container{
-			Name:    ig.Name,
-			Image:   GetOperatorDockerImage(),
-			Command: []string{"/bin/sh"},
-			Args:    []string{"-c", `cf-operator util data-gather`},
+			Name:            ig.Name,
+			Image:           GetOperatorDockerImage(),
+			ImagePullPolicy: DockerImagePullPolicy,
+			Command:         []string{"/bin/sh"},
+			Args:            []string{"-c", `cf-operator util data-gather`},
 			VolumeMounts: []corev1.VolumeMount{
 				{
 					Name:      generateVolumeName(interpolatedManifestSecretName),
@@ -356,6 +366,7 @@ func (m *Manifest) dataGatheringJob(namespace string) (*ejv1.ExtendedJob, error)
 				NamePrefix: outputSecretNamePrefix,
 				SecretLabels: map[string]string{
 					bdv1.LabelDeploymentName: m.Name,
+					bdv1.LabelManifestSHA1:   manifestSignature,
 				},
 				Versioned: true,
 			},
@@ -445,9 +456,10 @@ func (m *Manifest) jobsToInitContainers(igName string, jobs []Job, namespace str
 	}
 
 	initContainers = append(initContainers, corev1.Container{
-		Name:         fmt.Sprintf("renderer-%s", igName),
-		Image:        GetOperatorDockerImage(),
-		VolumeMounts: volumeMounts,
+		Name:            fmt.Sprintf("renderer-%s", igName),
+		Image:           GetOperatorDockerImage(),
+		ImagePullPolicy: DockerImagePullPolicy,
+		VolumeMounts:    volumeMounts,
 		Env: []corev1.EnvVar{
 			{
 				Name:  "INSTANCE_GROUP_NAME",
@@ -483,8 +495,9 @@ func (m *Manifest) jobsToContainers(igName string, jobs []Job, namespace string)
 			return []corev1.Container{}, err
 		}
 		jobsToContainerPods = append(jobsToContainerPods, corev1.Container{
-			Name:  fmt.Sprintf(job.Name),
-			Image: jobImage,
+			Name:            fmt.Sprintf(job.Name),
+			Image:           jobImage,
+			ImagePullPolicy: DockerImagePullPolicy,
 			VolumeMounts: []corev1.VolumeMount{
 				{
 					Name:      "rendering-data",
@@ -495,11 +508,38 @@ func (m *Manifest) jobsToContainers(igName string, jobs []Job, namespace string)
 					MountPath: "/var/vcap/jobs",
 				},
 			},
+			Lifecycle: &corev1.Lifecycle{
+				PreStop: drainHandler(job.Name),
+			},
 		})
 	}
 	return jobsToContainerPods, nil
 }
 
+// drainHandler runs the drain script of a BOSH job, if it has one, before its container is stopped.
+// A positive result is the number of seconds to wait, a negative one asks for polling the script
+// with 'job_check_status' after waiting that long.
+func drainHandler(jobName string) *corev1.Handler {
+	drainScript := filepath.Join("/var/vcap/jobs", jobName, "bin", "drain")
+
+	return &corev1.Handler{
+		Exec: &corev1.ExecAction{
+			Command: []string{
+				"/bin/sh",
+				"-c",
+				fmt.Sprintf(`[ -x "%[1]s" ] || exit 0
+wait=$("%[1]s" job_shutdown hash_unchanged) || exit 1
+while [ "$wait" -lt 0 ] 2>/dev/null; do
+  sleep $((-wait))
+  wait=$("%[1]s" job_check_status hash_unchanged) || exit 1
+done
+[ "$wait" -gt 0 ] 2>/dev/null && sleep "$wait"
+exit 0`, drainScript),
+			},
+		},
+	}
+}
+
 // serviceToExtendedSts will generate an ExtendedStatefulSet
 func (m *Manifest) serviceToExtendedSts(ig *InstanceGroup, namespace string) (essv1.ExtendedStatefulSet, error) {
 	igName := ig.Name
@@ -514,6 +554,12 @@ func (m *Manifest) serviceToExtendedSts(ig *InstanceGroup, namespace string) (es
 		return essv1.ExtendedStatefulSet{}, err
 	}
 
+	// The drain scripts run when the pods are stopped
+	gracePeriod, err := m.terminationGracePeriod(ig)
+	if err != nil {
+		return essv1.ExtendedStatefulSet{}, err
+	}
+
 	_, interpolatedManifestSecretName := names.CalculateEJobOutputSecretPrefixAndName(
 		names.DeploymentSecretTypeManifestAndVars,
 		m.Name,
@@ -585,9 +631,10 @@ func (m *Manifest) serviceToExtendedSts(ig *InstanceGroup, namespace string) (es
 							},
 						},
 						Spec: corev1.PodSpec{
-							Volumes:        volumes,
-							Containers:     listOfContainers,
-							InitContainers: listOfInitContainers,
+							Volumes:                       volumes,
+							Containers:                    listOfContainers,
+							InitContainers:                listOfInitContainers,
+							TerminationGracePeriodSeconds: gracePeriod,
 						},
 					},
 				},
@@ -721,6 +768,12 @@ func (m *Manifest) convertToExtendedStsAndServices(namespace string) ([]essv1.Ex
 func (m *Manifest) errandToExtendedJob(ig *InstanceGroup, namespace string) (ejv1.ExtendedJob, error) {
 	igName := ig.Name
 
+	// Errands are triggered manually, auto-errands run once when deployed
+	strategy := ejv1.TriggerManual
+	if ig.LifeCycle == "auto-errand" {
+		strategy = ejv1.TriggerOnce
+	}
+
 	listOfContainers, err := m.jobsToContainers(igName, ig.Jobs, namespace)
 	if err != nil {
 		return ejv1.ExtendedJob{}, err
@@ -780,6 +833,9 @@ func (m *Manifest) errandToExtendedJob(ig *InstanceGroup, namespace string) (ejv
 		},
 		Spec: ejv1.ExtendedJobSpec{
 			UpdateOnConfigChange: true,
+			Trigger: ejv1.Trigger{
+				Strategy: strategy,
+			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: igName,
@@ -803,7 +859,7 @@ func (m *Manifest) errandToExtendedJob(ig *InstanceGroup, namespace string) (ejv
 func (m *Manifest) convertToExtendedJob(namespace string) ([]ejv1.ExtendedJob, error) {
 	eJobs := []ejv1.ExtendedJob{}
 	for _, ig := range m.InstanceGroups {
-		if ig.LifeCycle == "errand" {
+		if ig.LifeCycle == "errand" || ig.LifeCycle == "auto-errand" {
 			convertedEJob, err := m.errandToExtendedJob(ig, namespace)
 			if err != nil {
 				return []ejv1.ExtendedJob{}, err
@@ -986,8 +1042,9 @@ func (m *Manifest) JobSpecCopierContainer(releaseName string, releaseImage strin
 
 	inContainerReleasePath := filepath.Join("/var/vcap/all-releases/jobs-src", releaseName)
 	initContainers := corev1.Container{
-		Name:  fmt.Sprintf("spec-copier-%s", releaseName),
-		Image: releaseImage,
+		Name:            fmt.Sprintf("spec-copier-%s", releaseName),
+		Image:           releaseImage,
+		ImagePullPolicy: DockerImagePullPolicy,
 		VolumeMounts: []corev1.VolumeMount{
 			{
 				Name:      volumeMountName,
@@ -9,6 +9,8 @@ import (
 	corev1 "k8s.io/api/core/v1"
 
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
 	esv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedsecret/v1alpha1"
 	essv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedstatefulset/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/testing"
@@ -120,6 +122,7 @@ var _ = Describe("kube converter", func() {
 					volumes = append(volumes, v.Name)
 				}
 				Expect(volumes).To(ConsistOf("with-ops", "var-adminpass"))
+				Expect(podSpec.Volumes[0].Secret.SecretName).To(Equal("foo-deployment.with-ops-v0"))
 
 				mountPaths := []string{}
 				for _, p := range podSpec.Containers[0].VolumeMounts {
@@ -140,6 +143,17 @@ var _ = Describe("kube converter", func() {
 				Expect(jobDG.InitContainers[0].VolumeMounts[0].MountPath).To(Equal("/var/vcap/all-releases"))
 				Expect(jobDG.InitContainers[1].VolumeMounts[0].MountPath).To(Equal("/var/vcap/all-releases"))
 			})
+
+			It("labels the output with the manifest signature", func() {
+				kubeConfig, err := m.ConvertToKube("foo")
+				Expect(err).ShouldNot(HaveOccurred())
+				manifestSHA1, err := m.SHA1()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				secretLabels := kubeConfig.DataGatheringJob.Spec.Output.SecretLabels
+				Expect(secretLabels).To(HaveKeyWithValue(bdv1.LabelDeploymentName, "foo-deployment"))
+				Expect(secretLabels).To(HaveKeyWithValue(bdv1.LabelManifestSHA1, manifestSHA1))
+			})
 		})
 
 		Context("when the lifecycle is set to service", func() {
@@ -156,6 +170,7 @@ var _ = Describe("kube converter", func() {
 				Expect(anExtendedSts.Spec.Containers[0].Image).To(Equal("hub.docker.com/cfcontainerization/cflinuxfs3:opensuse-15.0-28.g837c5b3-30.263-7.0.0_233.gde0accd0-0.62.0"))
 				Expect(anExtendedSts.Spec.Containers[0].Command).To(BeNil())
 				Expect(anExtendedSts.Spec.Containers[0].Name).To(Equal("cflinuxfs3-rootfs-setup"))
+				Expect(anExtendedSts.Spec.Containers[0].Lifecycle.PreStop.Exec.Command[2]).To(ContainSubstring("/var/vcap/jobs/cflinuxfs3-rootfs-setup/bin/drain"))
 
 				// Test init containers in the extended statefulSet
 				Expect(specCopierInitContainer.Name).To(Equal("spec-copier-cflinuxfs3"))
@@ -258,6 +273,52 @@ var _ = Describe("kube converter", func() {
 				}))
 				Expect(headlessService.Spec.ClusterIP).To(Equal("None"))
 			})
+
+			Context("when an update watch time is set", func() {
+				var diegoCell *manifest.InstanceGroup
+
+				BeforeEach(func() {
+					for _, ig := range m.InstanceGroups {
+						if ig.Name == "diego-cell" {
+							diegoCell = ig
+						}
+					}
+					m.Update = &manifest.Update{UpdateWatchTime: "5000-90000"}
+				})
+
+				gracePeriod := func() *int64 {
+					kubeConfig, err := m.ConvertToKube("foo")
+					Expect(err).ShouldNot(HaveOccurred())
+					return kubeConfig.InstanceGroups[0].Spec.Template.Spec.Template.Spec.TerminationGracePeriodSeconds
+				}
+
+				It("gives the drain scripts the upper bound of the watch time to finish", func() {
+					Expect(*gracePeriod()).To(Equal(int64(90)))
+				})
+
+				It("uses the watch time of the instance group", func() {
+					diegoCell.Update = &manifest.Update{UpdateWatchTime: "120500"}
+					Expect(*gracePeriod()).To(Equal(int64(121)))
+				})
+
+				It("keeps at least the default grace period", func() {
+					m.Update.UpdateWatchTime = "1000"
+					Expect(*gracePeriod()).To(Equal(int64(30)))
+				})
+
+				It("fails for an invalid watch time", func() {
+					m.Update.UpdateWatchTime = "soon"
+					_, err := m.ConvertToKube("foo")
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("invalid update watch time 'soon' of instance group 'diego-cell'"))
+				})
+			})
+
+			It("keeps the default grace period if no update watch time is set", func() {
+				kubeConfig, err := m.ConvertToKube("foo")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(kubeConfig.InstanceGroups[0].Spec.Template.Spec.Template.Spec.TerminationGracePeriodSeconds).To(BeNil())
+			})
 		})
 
 		Context("when the lifecycle is set to errand", func() {
@@ -269,6 +330,7 @@ var _ = Describe("kube converter", func() {
 				Expect(len(kubeConfig.Errands)).To(Equal(1))
 				Expect(len(kubeConfig.Errands)).ToNot(Equal(2))
 				Expect(anExtendedJob.Name).To(Equal("foo-deployment-redis-slave"))
+				Expect(anExtendedJob.Spec.Trigger.Strategy).To(Equal(ejv1.TriggerManual))
 
 				specCopierInitContainer := anExtendedJob.Spec.Template.Spec.InitContainers[0]
 				rendererInitContainer := anExtendedJob.Spec.Template.Spec.InitContainers[1]
@@ -299,6 +361,15 @@ var _ = Describe("kube converter", func() {
 				Expect(rendererInitContainer.VolumeMounts[1].Name).To(Equal("jobs-dir"))
 				Expect(rendererInitContainer.VolumeMounts[1].MountPath).To(Equal("/var/vcap/jobs"))
 			})
+
+			It("converts auto-errands to ExtendedJobs which run once", func() {
+				m.InstanceGroups[0].LifeCycle = "auto-errand"
+				kubeConfig, err := m.ConvertToKube("foo")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(kubeConfig.Errands).To(HaveLen(1))
+				Expect(kubeConfig.Errands[0].Spec.Trigger.Strategy).To(Equal(ejv1.TriggerOnce))
+				Expect(kubeConfig.Errands[0].IsAutoErrand()).To(BeTrue())
+			})
 		})
 	})
 
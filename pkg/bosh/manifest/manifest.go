@@ -146,7 +146,7 @@ type Update struct {
 	MaxInFlight     string  `yaml:"max_in_flight"`
 	CanaryWatchTime string  `yaml:"canary_watch_time"`
 	UpdateWatchTime string  `yaml:"update_watch_time"`
-	Serial          bool    `yaml:"serial,omitempty"`
+	Serial          *bool   `yaml:"serial,omitempty"`
 	VMStrategy      *string `yaml:"vm_strategy,omitempty"`
 }
 
@@ -21,10 +21,18 @@ func RenderJobTemplates(boshManifestPath string, jobsDir string, jobsOutputDir s
 	if err != nil {
 		return errors.Wrapf(err, "couldn't read manifest file %s", boshManifestPath)
 	}
+
+	err = RenderJobTemplatesFromManifest(resolvedYML, jobsDir, jobsOutputDir, instanceGroupName, specIndex)
+	return errors.Wrapf(err, "failed to render templates of deployment manifest %s", boshManifestPath)
+}
+
+// RenderJobTemplatesFromManifest renders the templates like RenderJobTemplates, for a
+// resolved manifest which was already read
+func RenderJobTemplatesFromManifest(resolvedYML []byte, jobsDir string, jobsOutputDir string, instanceGroupName string, specIndex int) error {
 	boshManifest := Manifest{}
-	err = yaml.Unmarshal(resolvedYML, &boshManifest)
+	err := yaml.Unmarshal(resolvedYML, &boshManifest)
 	if err != nil {
-		return errors.Wrapf(err, "failed to unmarshal deployment manifest %s", boshManifestPath)
+		return errors.Wrap(err, "failed to unmarshal deployment manifest")
 	}
 
 	// Loop over instancegroups
@@ -15,6 +15,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	bdc "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/names"
 )
 
@@ -61,10 +62,12 @@ func (r *ResolverImpl) ResolveManifest(instance *bdc.BOSHDeployment, namespace s
 	// Interpolate implicit variables
 	vars, err := r.ImplicitVariables(manifest, m)
 	for _, v := range vars {
-		varData, err := r.getRefData(namespace, bdc.SecretType, names.CalculateSecretName(names.DeploymentSecretTypeImplicitVariable, instance.GetName(), v), bdc.ImplicitVariableKeyName)
+		secretName := names.CalculateSecretName(names.DeploymentSecretTypeImplicitVariable, instance.GetName(), v)
+		varData, err := r.getRefData(namespace, bdc.SecretType, secretName, bdc.ImplicitVariableKeyName)
 		if err != nil {
 			return manifest, errors.Wrapf(err, "failed to load secret for variable '%s'", v)
 		}
+		ctxlog.AddSecretValues(fmt.Sprintf("Secret/%s/%s", namespace, secretName), varData)
 
 		m = strings.Replace(m, fmt.Sprintf("((%s))", v), varData, -1)
 	}
@@ -83,13 +86,13 @@ func (r *ResolverImpl) ResolveManifest(instance *bdc.BOSHDeployment, namespace s
 		}
 		err = interpolator.BuildOps([]byte(opsData))
 		if err != nil {
-			return manifest, errors.Wrapf(err, "failed to build ops with: %#v", opsData)
+			return manifest, errors.Wrapf(err, "failed to build ops from %s '%s'", op.Type, op.Ref)
 		}
 	}
 
 	bytes, err := interpolator.Interpolate([]byte(m))
 	if err != nil {
-		return manifest, errors.Wrapf(err, "failed to interpolate %#v", m)
+		return manifest, errors.Wrapf(err, "failed to interpolate manifest from %s '%s'", spec.Manifest.Type, spec.Manifest.Ref)
 	}
 
 	err = yaml.Unmarshal(bytes, manifest)
@@ -521,7 +521,8 @@ instance_groups:
 			}
 			_, err := resolver.ResolveManifest(deployment, "default")
 			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(ContainSubstring("failed to build ops"))
+			Expect(err.Error()).To(ContainSubstring("failed to build ops from configmap 'invalid-ops'"))
+			Expect(err.Error()).ToNot(ContainSubstring("new-deployment"))
 		})
 
 		It("throws an error if interpolate a missing key into a manifest", func() {
@@ -542,7 +543,8 @@ instance_groups:
 			}
 			_, err := resolver.ResolveManifest(deployment, "default")
 			Expect(err).To(HaveOccurred())
-			Expect(err.Error()).To(ContainSubstring("failed to interpolate"))
+			Expect(err.Error()).To(ContainSubstring("failed to interpolate manifest from configmap 'base-manifest'"))
+			Expect(err.Error()).ToNot(ContainSubstring("instance_groups"))
 		})
 
 		It("throws an error if containing unsupported ops type", func() {
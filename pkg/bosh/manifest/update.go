@@ -0,0 +1,75 @@
+package manifest
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// defaultTerminationGracePeriod is the termination grace period of pods in Kubernetes
+const defaultTerminationGracePeriod = 30
+
+// DeploymentSteps groups the instance groups in the order in which they are deployed.
+// A serial instance group is deployed on its own, after the instance groups before it are
+// ready. Consecutive non-serial instance groups are deployed in parallel.
+func (m *Manifest) DeploymentSteps() [][]string {
+	steps := [][]string{}
+	previousSerial := true
+
+	for _, ig := range m.InstanceGroups {
+		serial := m.isSerial(ig)
+		if serial || previousSerial {
+			steps = append(steps, []string{ig.Name})
+		} else {
+			steps[len(steps)-1] = append(steps[len(steps)-1], ig.Name)
+		}
+		previousSerial = serial
+	}
+
+	return steps
+}
+
+// isSerial returns the serial setting of an instance group, which defaults to the
+// one of the manifest. Like in BOSH, instance groups are serial if neither sets it.
+func (m *Manifest) isSerial(ig *InstanceGroup) bool {
+	if ig.Update != nil && ig.Update.Serial != nil {
+		return *ig.Update.Serial
+	}
+
+	if m.Update != nil && m.Update.Serial != nil {
+		return *m.Update.Serial
+	}
+
+	return true
+}
+
+// terminationGracePeriod returns the time the pods of an instance group get to run their
+// drain scripts when they are stopped, in seconds. It is the upper bound of the update watch
+// time, which defaults to the one of the manifest, but not less than the Kubernetes default.
+// Nil is returned if no update watch time is set.
+func (m *Manifest) terminationGracePeriod(ig *InstanceGroup) (*int64, error) {
+	watchTime := ""
+	if m.Update != nil {
+		watchTime = m.Update.UpdateWatchTime
+	}
+	if ig.Update != nil && ig.Update.UpdateWatchTime != "" {
+		watchTime = ig.Update.UpdateWatchTime
+	}
+	if watchTime == "" {
+		return nil, nil
+	}
+
+	// The watch time is in milliseconds, either a number or a range like '5000-30000'
+	bounds := strings.Split(watchTime, "-")
+	millis, err := strconv.ParseInt(strings.TrimSpace(bounds[len(bounds)-1]), 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid update watch time '%s' of instance group '%s'", watchTime, ig.Name)
+	}
+
+	seconds := (millis + 999) / 1000
+	if seconds < defaultTerminationGracePeriod {
+		seconds = defaultTerminationGracePeriod
+	}
+	return &seconds, nil
+}
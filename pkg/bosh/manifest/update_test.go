@@ -0,0 +1,66 @@
+package manifest_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+)
+
+var _ = Describe("DeploymentSteps", func() {
+	var (
+		m        *manifest.Manifest
+		serial   = true
+		parallel = false
+	)
+
+	BeforeEach(func() {
+		m = &manifest.Manifest{
+			InstanceGroups: []*manifest.InstanceGroup{
+				{Name: "nats"},
+				{Name: "database"},
+				{Name: "api"},
+				{Name: "router"},
+			},
+		}
+	})
+
+	It("deploys instance groups one after another if serial is not set", func() {
+		Expect(m.DeploymentSteps()).To(Equal([][]string{{"nats"}, {"database"}, {"api"}, {"router"}}))
+	})
+
+	It("deploys all instance groups at once if the manifest is not serial", func() {
+		m.Update = &manifest.Update{Serial: &parallel}
+		Expect(m.DeploymentSteps()).To(Equal([][]string{{"nats", "database", "api", "router"}}))
+	})
+
+	It("keeps the default for instance groups which only set other update settings", func() {
+		m.Update = &manifest.Update{}
+		m.InstanceGroups[0].Update = &manifest.Update{}
+		Expect(m.DeploymentSteps()).To(Equal([][]string{{"nats"}, {"database"}, {"api"}, {"router"}}))
+	})
+
+	It("deploys instance groups one after another if the manifest is serial", func() {
+		m.Update = &manifest.Update{Serial: &serial}
+		Expect(m.DeploymentSteps()).To(Equal([][]string{{"nats"}, {"database"}, {"api"}, {"router"}}))
+	})
+
+	It("lets instance groups override the serial setting of the manifest", func() {
+		m.Update = &manifest.Update{Serial: &serial}
+		m.InstanceGroups[2].Update = &manifest.Update{Serial: &parallel}
+		m.InstanceGroups[3].Update = &manifest.Update{Serial: &parallel}
+		Expect(m.DeploymentSteps()).To(Equal([][]string{{"nats"}, {"database"}, {"api", "router"}}))
+	})
+
+	It("deploys a serial instance group after the parallel ones before it", func() {
+		m.Update = &manifest.Update{Serial: &parallel}
+		m.InstanceGroups[1].Update = &manifest.Update{Serial: &serial}
+		Expect(m.DeploymentSteps()).To(Equal([][]string{{"nats"}, {"database"}, {"api", "router"}}))
+	})
+
+	It("lets instance groups be parallel if the manifest does not set serial", func() {
+		m.InstanceGroups[2].Update = &manifest.Update{Serial: &parallel}
+		m.InstanceGroups[3].Update = &manifest.Update{Serial: &parallel}
+		Expect(m.DeploymentSteps()).To(Equal([][]string{{"nats"}, {"database"}, {"api", "router"}}))
+	})
+})
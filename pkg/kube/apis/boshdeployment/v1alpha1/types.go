@@ -2,7 +2,9 @@ package v1alpha1
 
 import (
 	"fmt"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"code.cloudfoundry.org/cf-operator/pkg/kube/apis"
@@ -22,11 +24,46 @@ const (
 	ImplicitVariableKeyName string = "value"
 )
 
+// DefaultProgressDeadlineSeconds is the time instance groups get to become ready,
+// if the BOSHDeployment does not specify a deadline
+const DefaultProgressDeadlineSeconds int32 = 600
+
+// DefaultRevisionHistoryLimit is the number of manifest revisions kept,
+// if the BOSHDeployment does not specify a limit
+const DefaultRevisionHistoryLimit int32 = 10
+
+// DeletionPolicy decides what happens to the persistent volume claims of a deleted BOSHDeployment
+type DeletionPolicy string
+
+// Valid deletion policies
+const (
+	// DeletionPolicyRetain keeps the persistent volume claims, this is the default
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyDelete deletes the persistent volume claims
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+)
+
+// TeardownPhase is a phase of deleting a BOSHDeployment
+type TeardownPhase string
+
+// Teardown phases, in order
+const (
+	// TeardownDraining means instance groups are drained and their ExtendedStatefulSets deleted, in reverse deploy order
+	TeardownDraining TeardownPhase = "Draining"
+	// TeardownDeletingVolumes means persistent volume claims are deleted
+	TeardownDeletingVolumes TeardownPhase = "DeletingVolumes"
+	// TeardownRetainingVariables means generated variable secrets are kept for a later deployment
+	TeardownRetainingVariables TeardownPhase = "RetainingVariables"
+)
+
 var (
 	// LabelDeploymentName is the label key for manifest name
 	LabelDeploymentName = fmt.Sprintf("%s/deployment-name", apis.GroupName)
 	// LabelManifestSHA1 is the label key for manifest SHA1
 	LabelManifestSHA1 = fmt.Sprintf("%s/manifestsha1", apis.GroupName)
+	// LabelManifestRevision is the label key for the revision of a manifest revision secret,
+	// its history is limited by the BOSHDeployment instead of the pruning of versioned secrets
+	LabelManifestRevision = fmt.Sprintf("%s/manifest-revision", apis.GroupName)
 	// AnnotationManifestSHA1 is the annotation key for manifest SHA1
 	AnnotationManifestSHA1 = fmt.Sprintf("%s/manifestsha1", apis.GroupName)
 )
@@ -35,6 +72,20 @@ var (
 type BOSHDeploymentSpec struct {
 	Manifest Manifest `json:"manifest"`
 	Ops      []Ops    `json:"ops,omitempty"`
+	// ProgressDeadlineSeconds is the maximum time in seconds for all instance groups
+	// to become ready, before the deployment is considered failed
+	ProgressDeadlineSeconds int32 `json:"progressDeadlineSeconds,omitempty"`
+	// Paused stops the controller from acting on the deployment, changes are only reported
+	Paused bool `json:"paused,omitempty"`
+	// RollbackTo is a manifest revision to deploy instead of the referenced manifest and ops files
+	RollbackTo int `json:"rollbackTo,omitempty"`
+	// RevisionHistoryLimit is the number of manifest revisions to keep
+	RevisionHistoryLimit int32 `json:"revisionHistoryLimit,omitempty"`
+	// DeletionPolicy decides if persistent volume claims are kept when the deployment is deleted
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// KeepVariables keeps the generated variable secrets when the deployment is deleted,
+	// so a new deployment of the same name uses the same credentials
+	KeepVariables bool `json:"keepVariables,omitempty"`
 }
 
 // Manifest defines the manifest type and location
@@ -51,8 +102,98 @@ type Ops struct {
 
 // BOSHDeploymentStatus defines the observed state of BOSHDeployment
 type BOSHDeploymentStatus struct {
-	State string   `json:"state"`
-	Nodes []string `json:"nodes"`
+	State string `json:"state"`
+	// StateSince is the time the deployment entered its current state
+	StateSince *metav1.Time `json:"stateSince,omitempty"`
+	Nodes      []string     `json:"nodes"`
+	// ObservedGeneration is the most recent generation observed by the controller
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// ManifestSHA1 is the SHA1 of the manifest, with ops files applied, which is being deployed
+	ManifestSHA1 string `json:"manifestSHA1,omitempty"`
+	// PendingManifestSHA1 is the SHA1 of the manifest which will be deployed once the deployment is resumed
+	PendingManifestSHA1 string `json:"pendingManifestSHA1,omitempty"`
+	// CurrentRevision is the manifest revision which is being deployed
+	CurrentRevision int `json:"currentRevision,omitempty"`
+	// Revisions are the manifest revisions which can be rolled back to
+	Revisions []ManifestRevision `json:"revisions,omitempty"`
+	// Conditions are the latest observations of the deployment's state
+	Conditions []BOSHDeploymentCondition `json:"conditions,omitempty"`
+	// Reason explains the current state, e.g. why the deployment failed
+	Reason string `json:"reason,omitempty"`
+	// DeployingSince is the time the instance groups of the current step were deployed
+	DeployingSince *metav1.Time `json:"deployingSince,omitempty"`
+	// CurrentStep is the step of a serial deployment which is being deployed
+	CurrentStep *DeploymentStep `json:"currentStep,omitempty"`
+	// Teardown is the progress of deleting the deployment
+	Teardown *TeardownStatus `json:"teardown,omitempty"`
+	// InstanceGroups contains the progress of each instance group, keyed by name
+	InstanceGroups map[string]InstanceGroupStatus `json:"instanceGroups,omitempty"`
+}
+
+// BOSHDeploymentConditionType is the type of a BOSHDeployment condition
+type BOSHDeploymentConditionType string
+
+// Valid BOSHDeployment condition types
+const (
+	// ConditionManifestResolved means the manifest and ops files were resolved
+	ConditionManifestResolved BOSHDeploymentConditionType = "ManifestResolved"
+	// ConditionVariablesGenerated means the ExtendedSecrets for all variables exist
+	ConditionVariablesGenerated BOSHDeploymentConditionType = "VariablesGenerated"
+	// ConditionVariablesInterpolated means the variable interpolation ExtendedJob exists
+	ConditionVariablesInterpolated BOSHDeploymentConditionType = "VariablesInterpolated"
+	// ConditionDataGathered means the data gathering ExtendedJob exists
+	ConditionDataGathered BOSHDeploymentConditionType = "DataGathered"
+	// ConditionInstanceGroupsReady means all instance groups are ready and auto-errands completed
+	ConditionInstanceGroupsReady BOSHDeploymentConditionType = "InstanceGroupsReady"
+	// ConditionFailed means the deployment did not become ready in time
+	ConditionFailed BOSHDeploymentConditionType = "Failed"
+	// ConditionPaused means the controller does not act on the deployment
+	ConditionPaused BOSHDeploymentConditionType = "Paused"
+)
+
+// BOSHDeploymentCondition describes the state of a BOSHDeployment at a certain point
+type BOSHDeploymentCondition struct {
+	Type               BOSHDeploymentConditionType `json:"type"`
+	Status             corev1.ConditionStatus      `json:"status"`
+	LastTransitionTime metav1.Time                 `json:"lastTransitionTime,omitempty"`
+	Reason             string                      `json:"reason,omitempty"`
+	Message            string                      `json:"message,omitempty"`
+}
+
+// ManifestRevision describes a desired manifest, with ops files applied, that has been deployed
+type ManifestRevision struct {
+	Revision          int         `json:"revision"`
+	ManifestSHA1      string      `json:"manifestSHA1"`
+	SourceDescription string      `json:"sourceDescription,omitempty"`
+	CreationTimestamp metav1.Time `json:"creationTimestamp"`
+}
+
+// DeploymentStep is a set of instance groups which are deployed in parallel. Steps are
+// deployed one after another, following the serial update setting of the manifest.
+type DeploymentStep struct {
+	// Number counts the steps, starting at 1
+	Number         int      `json:"number"`
+	Total          int      `json:"total"`
+	InstanceGroups []string `json:"instanceGroups"`
+}
+
+// TeardownStatus describes the progress of deleting a BOSHDeployment
+type TeardownStatus struct {
+	Phase TeardownPhase `json:"phase"`
+	// Step is the set of instance groups being drained
+	Step    *DeploymentStep `json:"step,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+// InstanceGroupStatus defines the observed state of an instance group
+type InstanceGroupStatus struct {
+	// Version is the desired version of the ExtendedStatefulSet
+	Version       int   `json:"version,omitempty"`
+	Replicas      int32 `json:"replicas"`
+	ReadyReplicas int32 `json:"readyReplicas"`
+	Ready         bool  `json:"ready"`
+	// Message describes what the instance group is waiting for
+	Message string `json:"message,omitempty"`
 }
 
 // +genclient
@@ -82,3 +223,47 @@ func (e *BOSHDeployment) ToBeDeleted() bool {
 	// IsZero means that the object hasn't been marked for deletion
 	return !e.GetDeletionTimestamp().IsZero()
 }
+
+// GetCondition returns the condition of the given type, or nil if it is not set
+func (s *BOSHDeploymentStatus) GetCondition(conditionType BOSHDeploymentConditionType) *BOSHDeploymentCondition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or updates the condition of the given type.
+// The transition time only changes if the status of the condition changes.
+func (s *BOSHDeploymentStatus) SetCondition(conditionType BOSHDeploymentConditionType, status corev1.ConditionStatus, reason, message string) {
+	condition := s.GetCondition(conditionType)
+	if condition == nil {
+		s.Conditions = append(s.Conditions, BOSHDeploymentCondition{Type: conditionType})
+		condition = &s.Conditions[len(s.Conditions)-1]
+	}
+
+	if condition.Status != status {
+		condition.Status = status
+		condition.LastTransitionTime = metav1.Now()
+	}
+	condition.Reason = reason
+	condition.Message = message
+}
+
+// ProgressDeadline returns the time instance groups get to become ready
+func (e *BOSHDeployment) ProgressDeadline() time.Duration {
+	seconds := e.Spec.ProgressDeadlineSeconds
+	if seconds <= 0 {
+		seconds = DefaultProgressDeadlineSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// RevisionLimit returns the number of manifest revisions to keep
+func (e *BOSHDeployment) RevisionLimit() int {
+	if e.Spec.RevisionHistoryLimit <= 0 {
+		return int(DefaultRevisionHistoryLimit)
+	}
+	return int(e.Spec.RevisionHistoryLimit)
+}
@@ -41,6 +41,23 @@ func (in *BOSHDeployment) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BOSHDeploymentCondition) DeepCopyInto(out *BOSHDeploymentCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BOSHDeploymentCondition.
+func (in *BOSHDeploymentCondition) DeepCopy() *BOSHDeploymentCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(BOSHDeploymentCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BOSHDeploymentList) DeepCopyInto(out *BOSHDeploymentList) {
 	*out = *in
@@ -99,11 +116,50 @@ func (in *BOSHDeploymentSpec) DeepCopy() *BOSHDeploymentSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BOSHDeploymentStatus) DeepCopyInto(out *BOSHDeploymentStatus) {
 	*out = *in
+	if in.StateSince != nil {
+		in, out := &in.StateSince, &out.StateSince
+		*out = (*in).DeepCopy()
+	}
 	if in.Nodes != nil {
 		in, out := &in.Nodes, &out.Nodes
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Revisions != nil {
+		in, out := &in.Revisions, &out.Revisions
+		*out = make([]ManifestRevision, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]BOSHDeploymentCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DeployingSince != nil {
+		in, out := &in.DeployingSince, &out.DeployingSince
+		*out = (*in).DeepCopy()
+	}
+	if in.CurrentStep != nil {
+		in, out := &in.CurrentStep, &out.CurrentStep
+		*out = new(DeploymentStep)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Teardown != nil {
+		in, out := &in.Teardown, &out.Teardown
+		*out = new(TeardownStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.InstanceGroups != nil {
+		in, out := &in.InstanceGroups, &out.InstanceGroups
+		*out = make(map[string]InstanceGroupStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	return
 }
 
@@ -117,6 +173,43 @@ func (in *BOSHDeploymentStatus) DeepCopy() *BOSHDeploymentStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeploymentStep) DeepCopyInto(out *DeploymentStep) {
+	*out = *in
+	if in.InstanceGroups != nil {
+		in, out := &in.InstanceGroups, &out.InstanceGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeploymentStep.
+func (in *DeploymentStep) DeepCopy() *DeploymentStep {
+	if in == nil {
+		return nil
+	}
+	out := new(DeploymentStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstanceGroupStatus) DeepCopyInto(out *InstanceGroupStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceGroupStatus.
+func (in *InstanceGroupStatus) DeepCopy() *InstanceGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(InstanceGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Manifest) DeepCopyInto(out *Manifest) {
 	*out = *in
@@ -133,6 +226,23 @@ func (in *Manifest) DeepCopy() *Manifest {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManifestRevision) DeepCopyInto(out *ManifestRevision) {
+	*out = *in
+	in.CreationTimestamp.DeepCopyInto(&out.CreationTimestamp)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManifestRevision.
+func (in *ManifestRevision) DeepCopy() *ManifestRevision {
+	if in == nil {
+		return nil
+	}
+	out := new(ManifestRevision)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Ops) DeepCopyInto(out *Ops) {
 	*out = *in
@@ -148,3 +258,24 @@ func (in *Ops) DeepCopy() *Ops {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TeardownStatus) DeepCopyInto(out *TeardownStatus) {
+	*out = *in
+	if in.Step != nil {
+		in, out := &in.Step, &out.Step
+		*out = new(DeploymentStep)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TeardownStatus.
+func (in *TeardownStatus) DeepCopy() *TeardownStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TeardownStatus)
+	in.DeepCopyInto(out)
+	return out
+}
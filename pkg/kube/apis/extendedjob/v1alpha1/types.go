@@ -17,14 +17,43 @@ import (
 var (
 	// LabelReferencedJobName is the name key for dependent job
 	LabelReferencedJobName = fmt.Sprintf("%s/referenced-job-name", apis.GroupName)
+	// AnnotationTriggerReason is the annotation key for the reason a job was started
+	AnnotationTriggerReason = fmt.Sprintf("%s/trigger-reason", apis.GroupName)
+	// AnnotationTriggerPod is the annotation key for the name of the pod which triggered a job
+	AnnotationTriggerPod = fmt.Sprintf("%s/trigger-pod", apis.GroupName)
+	// AnnotationRunRecorded is the annotation key for the time the run of a finished job was
+	// recorded, jobs with this annotation are not processed again
+	AnnotationRunRecorded = fmt.Sprintf("%s/run-recorded", apis.GroupName)
+	// AnnotationRunID is the annotation key for the ID of an errand run requested by a
+	// client, it is copied to the job and its run so the client can find them
+	AnnotationRunID = fmt.Sprintf("%s/run-id", apis.GroupName)
+	// AnnotationRunOverrides is the annotation key for the container overrides of the next
+	// errand run, they are stored as a JSON list of ContainerOverride
+	AnnotationRunOverrides = fmt.Sprintf("%s/run-overrides", apis.GroupName)
 )
 
+// DefaultRunHistoryLimit is the number of runs kept in the status if no limit is set
+const DefaultRunHistoryLimit = 10
+
 // ExtendedJobSpec defines the desired state of ExtendedJob
 type ExtendedJobSpec struct {
 	Output               *Output                `json:"output,omitempty"`
 	Trigger              Trigger                `json:"trigger"`
 	Template             corev1.PodTemplateSpec `json:"template"`
 	UpdateOnConfigChange bool                   `json:"updateOnConfigChange"`
+	// BackoffLimit is the number of retries before a job is marked as failed
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+	// ActiveDeadlineSeconds is the time a job may run before it is terminated and marked as failed
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+	// FailedJobTTLSeconds is the time failed jobs are kept for debugging, they are kept
+	// forever if it's not set
+	FailedJobTTLSeconds *int64 `json:"failedJobTTLSeconds,omitempty"`
+	// RunHistoryLimit is the number of finished runs recorded in the status
+	RunHistoryLimit *int32 `json:"runHistoryLimit,omitempty"`
+	// Parallelism is the number of pods of a job running at the same time
+	Parallelism *int32 `json:"parallelism,omitempty"`
+	// Completions is the number of pods of a job which have to succeed
+	Completions *int32 `json:"completions,omitempty"`
 }
 
 // Strategy describes the trigger strategy
@@ -40,12 +69,86 @@ const (
 	TriggerOnce Strategy = "once"
 	// TriggerDone jobs are no longer triggered. It's the final state for TriggerOnce strategies
 	TriggerDone Strategy = "done"
+	// TriggerPodState jobs are triggered by pod state changes, see PodStateTrigger
+	TriggerPodState Strategy = "podstate"
+	// TriggerSchedule jobs run periodically, see ScheduleTrigger
+	TriggerSchedule Strategy = "schedule"
+	// TriggerConfigChange jobs are triggered by secrets or config maps being
+	// created or changed, see ConfigTrigger
+	TriggerConfigChange Strategy = "configchange"
+	// TriggerJobCompletion jobs are triggered by other extended jobs finishing,
+	// see JobCompletionTrigger
+	TriggerJobCompletion Strategy = "jobcompletion"
 )
 
 // Trigger decides how to trigger the ExtendedJob
 type Trigger struct {
-	Strategy Strategy         `json:"strategy"`
-	PodState *PodStateTrigger `json:"podstate,omitempty"`
+	Strategy Strategy              `json:"strategy"`
+	PodState *PodStateTrigger      `json:"podstate,omitempty"`
+	Schedule *ScheduleTrigger      `json:"schedule,omitempty"`
+	Config   *ConfigTrigger        `json:"config,omitempty"`
+	Job      *JobCompletionTrigger `json:"job,omitempty"`
+}
+
+const (
+	// ConfigKindSecret triggers on secrets
+	ConfigKindSecret = "secret"
+	// ConfigKindConfigMap triggers on config maps
+	ConfigKindConfigMap = "configmap"
+)
+
+// ConfigTrigger specifies which secrets or config maps trigger the job
+type ConfigTrigger struct {
+	// Kind is either secret or configmap
+	Kind string `json:"kind"`
+	// Name matches the config with this name, and all versions of a versioned secret
+	// or config map with this name
+	Name     string    `json:"name,omitempty"`
+	Selector *Selector `json:"selector,omitempty"`
+}
+
+// JobOutcome is how a job finished
+type JobOutcome string
+
+const (
+	// JobSucceeded matches jobs which succeeded
+	JobSucceeded JobOutcome = "succeeded"
+	// JobFailed matches jobs which failed
+	JobFailed JobOutcome = "failed"
+	// JobFinished matches jobs which succeeded or failed
+	JobFinished JobOutcome = "finished"
+)
+
+// JobCompletionTrigger specifies which extended job triggers the job when it finishes
+type JobCompletionTrigger struct {
+	Name string     `json:"name"`
+	When JobOutcome `json:"when"`
+}
+
+// ConcurrencyPolicy describes how to handle a scheduled run while a job is still running
+type ConcurrencyPolicy string
+
+const (
+	// AllowConcurrent runs jobs concurrently, it is the default
+	AllowConcurrent ConcurrencyPolicy = "allow"
+	// ForbidConcurrent skips the run if a job is still running
+	ForbidConcurrent ConcurrencyPolicy = "forbid"
+	// ReplaceConcurrent deletes the running jobs before starting the new one
+	ReplaceConcurrent ConcurrencyPolicy = "replace"
+)
+
+// ScheduleTrigger specifies when to run a job periodically
+type ScheduleTrigger struct {
+	// Cron is a cron expression like '0 3 * * *' or a descriptor like '@daily'
+	Cron string `json:"cron"`
+	// TimeZone is the name of the time zone the cron expression is
+	// evaluated in, like 'Europe/Berlin' (default: UTC)
+	TimeZone string `json:"timeZone,omitempty"`
+	// StartingDeadlineSeconds is how late a run may start, runs which are
+	// missed by more are skipped (default: no deadline)
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+	// ConcurrencyPolicy is one of allow, forbid or replace (default: allow)
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
 }
 
 // PodState is our abstraction of the pods state with regards to triggered
@@ -88,18 +191,99 @@ type Requirement struct {
 	Values   []string           `json:"values"`
 }
 
+const (
+	// OutputTypeJSON is the output type for containers writing a flat JSON object to stdout
+	OutputTypeJSON = "json"
+	// OutputTypeYAML is the output type for containers writing a flat YAML map to stdout
+	OutputTypeYAML = "yaml"
+	// OutputTypeRaw is the output type for containers writing arbitrary text to stdout,
+	// it is stored under the RawOutputKey
+	OutputTypeRaw = "raw"
+
+	// RawOutputKey is the key of the secret holding the output of the raw output type
+	RawOutputKey = "output"
+
+	// OutputSourceStdout reads the output from the containers' logs
+	OutputSourceStdout = "stdout"
+	// OutputSourceFiles collects the output from the files the containers write to
+	// their output directory, one file per key
+	OutputSourceFiles = "files"
+
+	// OutputTargetSecret stores the output in secrets
+	OutputTargetSecret = "secret"
+	// OutputTargetConfigMap stores the output in config maps, for output which is not sensitive
+	OutputTargetConfigMap = "configmap"
+
+	// OutputMergePod keeps the output of every pod of a multi-pod job, its keys are
+	// prefixed with the name of the pod, e.g. job-x7k2p.password
+	OutputMergePod = "pod"
+	// OutputMergeFirst keeps the value of the first pod for keys written by several pods
+	OutputMergeFirst = "first"
+	// OutputMergeLast keeps the value of the last pod for keys written by several pods
+	OutputMergeLast = "last"
+	// OutputMergeUnique fails if pods write different values for the same key
+	OutputMergeUnique = "unique"
+)
+
 // Output contains options to persist job output
 type Output struct {
 	NamePrefix     string            `json:"namePrefix"`           // the secret name will be <NamePrefix><container name>
-	OutputType     string            `json:"outputType,omitempty"` // only json is supported for now
+	OutputType     string            `json:"outputType,omitempty"` // json, yaml or raw, only used for the stdout source
+	Source         string            `json:"source,omitempty"`     // stdout or files (default: stdout)
+	Target         string            `json:"target,omitempty"`     // secret or configmap (default: secret)
 	SecretLabels   map[string]string `json:"secretLabels,omitempty"`
 	WriteOnFailure bool              `json:"writeOnFailure,omitempty"`
 	Versioned      bool              `json:"versioned,omitempty"`
+	Merge          string            `json:"merge,omitempty"` // pod, first, last or unique, only used for multi-pod jobs (default: pod)
 }
 
 // ExtendedJobStatus defines the observed state of ExtendedJob
 type ExtendedJobStatus struct {
-	Nodes []string `json:"nodes"`
+	// Runs are the latest finished runs, the most recent one first
+	Runs []Run `json:"runs,omitempty"`
+	// LastScheduleTime is the time the job was last run for its schedule
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// Triggers records the last event which triggered the job for every config or
+	// extended job it depends on, so the same event doesn't trigger it twice
+	Triggers map[string]string `json:"triggers,omitempty"`
+}
+
+// RunResult is the result of a finished run
+type RunResult string
+
+const (
+	// RunSucceeded is the result of runs whose job succeeded
+	RunSucceeded RunResult = "succeeded"
+	// RunFailed is the result of runs whose job failed
+	RunFailed RunResult = "failed"
+)
+
+// ContainerOverride changes a container of the template for a single errand run
+type ContainerOverride struct {
+	Name string `json:"name"`
+	// Args replace the args of the container, if set
+	Args []string `json:"args,omitempty"`
+	// Env is added to the env of the container, replacing variables with the same name
+	Env []corev1.EnvVar `json:"env,omitempty"`
+}
+
+// Run is a finished job of the extended job
+type Run struct {
+	JobName        string       `json:"jobName"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	Result         RunResult    `json:"result"`
+	// Reason describes what started the job, e.g. the trigger strategy
+	Reason string `json:"reason,omitempty"`
+	// Pod is the name of the pod which triggered the job
+	Pod string `json:"pod,omitempty"`
+	// Outputs are the names of the secrets or config maps the output was written to,
+	// including the version of versioned ones
+	Outputs []string `json:"outputs,omitempty"`
+	// FailedPods are the names of the pods of the job which failed
+	FailedPods []string `json:"failedPods,omitempty"`
+	// RunID is the ID of the run requested by a client, see AnnotationRunID
+	RunID string `json:"runID,omitempty"`
 }
 
 // +genclient
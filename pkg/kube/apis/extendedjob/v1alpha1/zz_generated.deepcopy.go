@@ -10,10 +10,60 @@ Don't alter this file, it was generated.
 package v1alpha1
 
 import (
+	v1 "k8s.io/api/core/v1"
 	labels "k8s.io/apimachinery/pkg/labels"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigTrigger) DeepCopyInto(out *ConfigTrigger) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(Selector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigTrigger.
+func (in *ConfigTrigger) DeepCopy() *ConfigTrigger {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigTrigger)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerOverride) DeepCopyInto(out *ContainerOverride) {
+	*out = *in
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerOverride.
+func (in *ContainerOverride) DeepCopy() *ContainerOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExtendedJob) DeepCopyInto(out *ExtendedJob) {
 	*out = *in
@@ -85,6 +135,36 @@ func (in *ExtendedJobSpec) DeepCopyInto(out *ExtendedJobSpec) {
 	}
 	in.Trigger.DeepCopyInto(&out.Trigger)
 	in.Template.DeepCopyInto(&out.Template)
+	if in.BackoffLimit != nil {
+		in, out := &in.BackoffLimit, &out.BackoffLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ActiveDeadlineSeconds != nil {
+		in, out := &in.ActiveDeadlineSeconds, &out.ActiveDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.FailedJobTTLSeconds != nil {
+		in, out := &in.FailedJobTTLSeconds, &out.FailedJobTTLSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.RunHistoryLimit != nil {
+		in, out := &in.RunHistoryLimit, &out.RunHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Parallelism != nil {
+		in, out := &in.Parallelism, &out.Parallelism
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Completions != nil {
+		in, out := &in.Completions, &out.Completions
+		*out = new(int32)
+		**out = **in
+	}
 	return
 }
 
@@ -101,10 +181,23 @@ func (in *ExtendedJobSpec) DeepCopy() *ExtendedJobSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExtendedJobStatus) DeepCopyInto(out *ExtendedJobStatus) {
 	*out = *in
-	if in.Nodes != nil {
-		in, out := &in.Nodes, &out.Nodes
-		*out = make([]string, len(*in))
-		copy(*out, *in)
+	if in.Runs != nil {
+		in, out := &in.Runs, &out.Runs
+		*out = make([]Run, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Triggers != nil {
+		in, out := &in.Triggers, &out.Triggers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
 	}
 	return
 }
@@ -119,6 +212,22 @@ func (in *ExtendedJobStatus) DeepCopy() *ExtendedJobStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JobCompletionTrigger) DeepCopyInto(out *JobCompletionTrigger) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JobCompletionTrigger.
+func (in *JobCompletionTrigger) DeepCopy() *JobCompletionTrigger {
+	if in == nil {
+		return nil
+	}
+	out := new(JobCompletionTrigger)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Output) DeepCopyInto(out *Output) {
 	*out = *in
@@ -184,6 +293,61 @@ func (in *Requirement) DeepCopy() *Requirement {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Run) DeepCopyInto(out *Run) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.FailedPods != nil {
+		in, out := &in.FailedPods, &out.FailedPods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Run.
+func (in *Run) DeepCopy() *Run {
+	if in == nil {
+		return nil
+	}
+	out := new(Run)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduleTrigger) DeepCopyInto(out *ScheduleTrigger) {
+	*out = *in
+	if in.StartingDeadlineSeconds != nil {
+		in, out := &in.StartingDeadlineSeconds, &out.StartingDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduleTrigger.
+func (in *ScheduleTrigger) DeepCopy() *ScheduleTrigger {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduleTrigger)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Selector) DeepCopyInto(out *Selector) {
 	*out = *in
@@ -230,6 +394,21 @@ func (in *Trigger) DeepCopyInto(out *Trigger) {
 		*out = new(PodStateTrigger)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Schedule != nil {
+		in, out := &in.Schedule, &out.Schedule
+		*out = new(ScheduleTrigger)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(ConfigTrigger)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Job != nil {
+		in, out := &in.Job, &out.Job
+		*out = new(JobCompletionTrigger)
+		**out = **in
+	}
 	return
 }
 
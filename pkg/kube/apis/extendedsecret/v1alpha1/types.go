@@ -23,6 +23,15 @@ const (
 	RSAKey      Type = "rsa"
 )
 
+// Values of the secret kind label
+const (
+	// GeneratedSecretKind marks secrets generated by an ExtendedSecret, they are regenerated when reconciled
+	GeneratedSecretKind = "generated"
+	// RetainedSecretKind marks generated secrets which were kept when their owner was deleted.
+	// They are adopted by the next ExtendedSecret of the same secret name instead of being regenerated.
+	RetainedSecretKind = "retained"
+)
+
 var (
 	// LabelKind is the label key for secret kind
 	LabelKind = fmt.Sprintf("%s/secret-kind", apis.GroupName)
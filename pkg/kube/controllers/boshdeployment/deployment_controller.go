@@ -4,26 +4,36 @@ import (
 	"context"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
 	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/backoff"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
 	ctxlog "code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/versionedsecretstore"
 )
 
 // AddDeployment creates a new BOSHDeployment Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func AddDeployment(ctx context.Context, config *config.Config, mgr manager.Manager) error {
+	config = config.ForController("boshdeployment")
 	ctx = ctxlog.NewContextWithRecorder(ctx, "boshdeployment-reconciler", mgr.GetRecorder("boshdeployment-recorder"))
 	r := NewReconciler(ctx, config, mgr, bdm.NewResolver(mgr.GetClient(), func() bdm.Interpolator { return bdm.NewInterpolator() }), controllerutil.SetControllerReference)
 
 	// Create a new controller
-	c, err := controller.New("boshdeployment-controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New("boshdeployment-controller", mgr, controller.Options{
+		Reconciler:              backoff.NewReconciler(ctx, r, config.BackoffMin, config.BackoffMax),
+		MaxConcurrentReconciles: config.MaxConcurrentReconciles,
+	})
 	if err != nil {
 		return err
 	}
@@ -61,5 +71,38 @@ func AddDeployment(ctx context.Context, config *config.Config, mgr manager.Manag
 		return err
 	}
 
+	// Watch new versions of secrets generated for a BOSHDeployment, e.g. the output of the data gathering job.
+	// Versioned secrets are immutable, so only their creation is relevant.
+	p := predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return isDeploymentVersionedSecret(e.Meta.GetLabels()) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return false },
+	}
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+			return []reconcile.Request{
+				{
+					NamespacedName: types.NamespacedName{
+						Name:      a.Meta.GetLabels()[bdv1.LabelDeploymentName],
+						Namespace: a.Meta.GetNamespace(),
+					},
+				},
+			}
+		}),
+	}, p)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
+
+// isDeploymentVersionedSecret matches versioned secrets which belong to a BOSHDeployment
+func isDeploymentVersionedSecret(labels map[string]string) bool {
+	if labels[versionedsecretstore.LabelSecretKind] != versionedsecretstore.VersionSecretKind {
+		return false
+	}
+	_, ok := labels[bdv1.LabelDeploymentName]
+	return ok
+}
@@ -4,13 +4,18 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 	yaml "gopkg.in/yaml.v2"
+	"k8s.io/api/apps/v1beta2"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -27,7 +32,9 @@ import (
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
 	log "code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/finalizer"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/metrics"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/names"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/nslabel"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/owner"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/versionedsecretstore"
 )
@@ -42,6 +49,8 @@ const (
 	DataGatheredState         = "DataGathered"
 	DeployingState            = "Deploying"
 	DeployedState             = "Deployed"
+	FailedState               = "Failed"
+	DeletingState             = "Deleting"
 )
 
 // Check that ReconcileBOSHDeployment implements the reconcile.Reconciler interface
@@ -60,14 +69,15 @@ func NewReconciler(ctx context.Context, config *config.Config, mgr manager.Manag
 	versionedSecretStore := versionedsecretstore.NewVersionedSecretStore(mgr.GetClient())
 
 	return &ReconcileBOSHDeployment{
-		ctx:                  ctx,
-		config:               config,
-		client:               mgr.GetClient(),
-		scheme:               mgr.GetScheme(),
-		resolver:             resolver,
-		setReference:         srf,
-		owner:                owner.NewOwner(mgr.GetClient(), mgr.GetScheme()),
-		versionedSecretStore: versionedSecretStore,
+		ctx:                     ctx,
+		config:                  config,
+		client:                  mgr.GetClient(),
+		scheme:                  mgr.GetScheme(),
+		resolver:                resolver,
+		setReference:            srf,
+		owner:                   owner.NewOwner(mgr.GetClient(), mgr.GetScheme()),
+		versionedSecretStore:    versionedSecretStore,
+		versionedConfigMapStore: versionedsecretstore.NewVersionedConfigMapStore(mgr.GetClient()),
 	}
 }
 
@@ -75,14 +85,15 @@ func NewReconciler(ctx context.Context, config *config.Config, mgr manager.Manag
 type ReconcileBOSHDeployment struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
-	ctx                  context.Context
-	client               client.Client
-	scheme               *runtime.Scheme
-	resolver             bdm.Resolver
-	setReference         setReferenceFunc
-	config               *config.Config
-	owner                Owner
-	versionedSecretStore versionedsecretstore.VersionedSecretStore
+	ctx                     context.Context
+	client                  client.Client
+	scheme                  *runtime.Scheme
+	resolver                bdm.Resolver
+	setReference            setReferenceFunc
+	config                  *config.Config
+	owner                   Owner
+	versionedSecretStore    versionedsecretstore.VersionedSecretStore
+	versionedConfigMapStore versionedsecretstore.VersionedConfigMapStore
 }
 
 // Reconcile reads that state of the cluster for a BOSHDeployment object and makes changes based on the state read
@@ -97,6 +108,7 @@ func (r *ReconcileBOSHDeployment) Reconcile(request reconcile.Request) (reconcil
 	// Set the ctx to be Background, as the top-level context for incoming requests.
 	ctx, cancel := context.WithTimeout(r.ctx, r.config.CtxTimeOut)
 	defer cancel()
+	ctx = log.NewRequestContext(ctx, request.Namespace, request.Name)
 
 	log.Infof(ctx, "Reconciling BOSHDeployment %s", request.NamespacedName)
 	err := r.client.Get(ctx, request.NamespacedName, instance)
@@ -112,6 +124,7 @@ func (r *ReconcileBOSHDeployment) Reconcile(request reconcile.Request) (reconcil
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
+	ctx = log.WithFields(ctx, "deployment", instance.GetName())
 
 	// Clean up instance if has been marked for deletion
 	if instance.ToBeDeleted() {
@@ -124,9 +137,33 @@ func (r *ReconcileBOSHDeployment) Reconcile(request reconcile.Request) (reconcil
 	// Resolve the manifest (incl. ops files and implicit variables)
 	manifest, err := r.resolveManifest(ctx, instance)
 	if err != nil {
+		instance.Status.ObservedGeneration = instance.GetGeneration()
+		instance.Status.SetCondition(bdv1.ConditionManifestResolved, corev1.ConditionFalse, "ResolveManifestError", err.Error())
+		if updateErr := r.updateInstanceState(ctx, instance); updateErr != nil {
+			log.Errorf(ctx, "Failed to update conditions of BOSHDeployment '%s': %v", request.NamespacedName, updateErr)
+		}
 		return reconcile.Result{}, err
 	}
 
+	// Compute SHA1 of the manifest (with ops applied), so we can figure out if anything
+	// has changed.
+	currentManifestSHA1, err := manifest.SHA1()
+	if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "could not calculate manifest SHA1")
+	}
+	oldManifestSHA1, _ := instance.Annotations[bdv1.AnnotationManifestSHA1]
+
+	// The manifest is validated, but a paused deployment must neither change nor touch
+	// the resources it references
+	if instance.Spec.Paused {
+		if _, err := r.convertManifest(ctx, instance, manifest); err != nil {
+			return reconcile.Result{}, err
+		}
+		instance.Status.ObservedGeneration = instance.GetGeneration()
+		instance.Status.SetCondition(bdv1.ConditionManifestResolved, corev1.ConditionTrue, "ManifestResolved", "")
+		return reconcile.Result{}, r.pause(ctx, instance, currentManifestSHA1)
+	}
+
 	// Set manifest and ops ownerReference as instance
 	err = r.setSpecsOwnerReference(ctx, instance)
 	if err != nil {
@@ -141,30 +178,21 @@ func (r *ReconcileBOSHDeployment) Reconcile(request reconcile.Request) (reconcil
 		return reconcile.Result{}, errors.Wrap(err, "could not set instance's finalizer")
 	}
 
-	// Compute SHA1 of the manifest (with ops applied), so we can figure out if anything
-	// has changed.
-	currentManifestSHA1, err := manifest.SHA1()
-	if err != nil {
-		return reconcile.Result{}, errors.Wrap(err, "could not calculate manifest SHA1")
-	}
-	oldManifestSHA1, _ := instance.Annotations[bdv1.AnnotationManifestSHA1]
-	if oldManifestSHA1 == currentManifestSHA1 && instance.Status.State == DeployedState {
-		log.WithEvent(instance, "SkipReconcile").Infof(ctx, "Skip reconcile: deployed BoshDeployment '%s/%s' manifest has not changed", instance.GetNamespace(), instance.GetName())
-		return reconcile.Result{}, nil
+	instance.Status.ObservedGeneration = instance.GetGeneration()
+	instance.Status.SetCondition(bdv1.ConditionManifestResolved, corev1.ConditionTrue, "ManifestResolved", "")
+	if condition := instance.Status.GetCondition(bdv1.ConditionPaused); condition != nil && condition.Status == corev1.ConditionTrue {
+		r.resume(ctx, instance)
 	}
 
-	// If we have no instance groups, we should stop. There must be something wrong
-	// with the manifest.
-	if len(manifest.InstanceGroups) < 1 {
-		err := log.WithEvent(instance, "MissingInstanceError").Errorf(ctx, "No instance groups defined in manifest %s", manifest.Name)
-		return reconcile.Result{}, err
+	if oldManifestSHA1 == currentManifestSHA1 && (instance.Status.State == DeployedState || instance.Status.State == FailedState) {
+		log.WithEvent(instance, "SkipReconcile").Infof(ctx, "Skip reconcile: BoshDeployment '%s/%s' is %s and its manifest has not changed", instance.GetNamespace(), instance.GetName(), instance.Status.State)
+		// Only the observed generation might have changed
+		return reconcile.Result{}, r.updateInstanceState(ctx, instance)
 	}
 
 	// Generate all the kube objects we need for the manifest
-	log.Debug(ctx, "Converting bosh manifest to kube objects")
-	kubeConfigs, err := manifest.ConvertToKube(r.config.Namespace)
+	kubeConfigs, err := r.convertManifest(ctx, instance, manifest)
 	if err != nil {
-		err = log.WithEvent(instance, "BadManifestError").Errorf(ctx, "Error converting bosh manifest %s to kube objects: %s", manifest.Name, err)
 		return reconcile.Result{}, err
 	}
 
@@ -182,12 +210,39 @@ func (r *ReconcileBOSHDeployment) Reconcile(request reconcile.Request) (reconcil
 	case CreatedState:
 		fallthrough
 	case UpdatedState:
+		// Namespaces are only labeled on startup, if a list of namespaces is watched
+		if r.config.WatchAllNamespaces {
+			err = nslabel.Set(ctx, r.client, instance.GetNamespace(), r.config.Namespace)
+			if err != nil {
+				log.WithEvent(instance, "NamespaceLabelError").Errorf(ctx, "Failed to label namespace '%s' for the operator webhooks: %v", instance.GetNamespace(), err)
+				return reconcile.Result{}, err
+			}
+		}
+
+		err = r.createManifestRevision(ctx, instance, manifest, currentManifestSHA1)
+		if err != nil {
+			log.WithEvent(instance, "ManifestRevisionError").Errorf(ctx, "Failed to create manifest revision: %v", err)
+			return reconcile.Result{}, err
+		}
+
 		// Set manifest SHA1
 		if instance.Annotations == nil {
 			instance.Annotations = map[string]string{}
 		}
 		instance.Annotations[bdv1.AnnotationManifestSHA1] = currentManifestSHA1
 		instance.Status.State = OpsAppliedState
+		instance.Status.ManifestSHA1 = currentManifestSHA1
+
+		// Start over for the new manifest
+		for _, conditionType := range []bdv1.BOSHDeploymentConditionType{
+			bdv1.ConditionVariablesGenerated,
+			bdv1.ConditionVariablesInterpolated,
+			bdv1.ConditionDataGathered,
+			bdv1.ConditionInstanceGroupsReady,
+			bdv1.ConditionFailed,
+		} {
+			instance.Status.SetCondition(conditionType, corev1.ConditionFalse, "ManifestChanged", "")
+		}
 
 	case OpsAppliedState:
 		err = r.generateVariableSecrets(ctx, instance, manifest, &kubeConfigs)
@@ -197,13 +252,31 @@ func (r *ReconcileBOSHDeployment) Reconcile(request reconcile.Request) (reconcil
 		}
 
 	case VariableGeneratedState:
-		err = r.createVariableInterpolationEJob(ctx, instance, manifest, kubeConfigs)
+		err = r.createVariableInterpolationEJob(ctx, instance, kubeConfigs)
 		if err != nil {
 			log.WithEvent(instance, "VariableInterpolationError").Errorf(ctx, "Failed to create variable interpolation eJob: %v", err)
 			return reconcile.Result{}, err
 		}
 
 	case VariableInterpolatedState:
+		// Data gathering has to use the interpolated manifest of the current manifest signature
+		_, interpolatedManifestSecretName := names.CalculateEJobOutputSecretPrefixAndName(
+			names.DeploymentSecretTypeManifestAndVars,
+			manifest.Name,
+			bdm.VarInterpolationContainerName,
+			false,
+		)
+		secret, err := r.latestVersionForManifest(ctx, instance.GetNamespace(), interpolatedManifestSecretName, currentManifestSHA1)
+		if err != nil {
+			log.WithEvent(instance, "InterpolatedManifestError").Errorf(ctx, "Failed to get interpolated manifest: %v", err)
+			return reconcile.Result{}, err
+		}
+		if secret == nil {
+			// The creation of the secret triggers a new reconcile
+			log.Infof(ctx, "Waiting for the interpolated manifest '%s' of BoshDeployment '%s/%s'", interpolatedManifestSecretName, instance.GetNamespace(), instance.GetName())
+			return reconcile.Result{}, r.updateInstanceState(ctx, instance)
+		}
+
 		err = r.createDataGatheringJob(ctx, instance, manifest, kubeConfigs)
 		if err != nil {
 			log.WithEvent(instance, "DataGatheringError").Errorf(ctx, "Failed to create data gathering eJob: %v", err)
@@ -213,10 +286,15 @@ func (r *ReconcileBOSHDeployment) Reconcile(request reconcile.Request) (reconcil
 	case DataGatheredState:
 		// Wait for all instance group property outputs to be ready
 		// We need BPM information to start everything up
-		bpmInfo, err := r.waitForBPM(ctx, instance, manifest, &kubeConfigs)
+		bpmInfo, err := r.waitForBPM(ctx, instance, manifest, &kubeConfigs, currentManifestSHA1)
 		if err != nil {
-			log.Infof(ctx, "Waiting for BPM: %s", err.Error())
-			return reconcile.Result{Requeue: true, RequeueAfter: 5 * time.Second}, err
+			log.WithEvent(instance, "BPMInformationError").Errorf(ctx, "Failed to get BPM information: %v", err)
+			return reconcile.Result{}, err
+		}
+		if bpmInfo == nil {
+			// The creation of the missing secrets triggers a new reconcile
+			log.Infof(ctx, "Waiting for BPM information of BoshDeployment '%s/%s'", instance.GetNamespace(), instance.GetName())
+			return reconcile.Result{}, r.updateInstanceState(ctx, instance)
 		}
 
 		err = manifest.ApplyBPMInfo(&kubeConfigs, bpmInfo)
@@ -225,28 +303,62 @@ func (r *ReconcileBOSHDeployment) Reconcile(request reconcile.Request) (reconcil
 			return reconcile.Result{}, err
 		}
 
-		err = r.deployInstanceGroups(ctx, instance, &kubeConfigs)
+		err = r.deployInstanceGroups(ctx, instance, manifest, &kubeConfigs)
 		if err != nil {
 			log.Errorf(ctx, "Failed to deploy instance groups: %v", err)
 			return reconcile.Result{}, err
 		}
 
 	case DeployingState:
-		err = r.actionOnDeploying(ctx, instance, &kubeConfigs)
+		stepReady, err := r.actionOnDeploying(ctx, instance, manifest, &kubeConfigs)
 		if err != nil {
 			log.WithEvent(instance, "InstanceDeploymentError").Errorf(ctx, "Failed to deploy: %v", err)
 			return reconcile.Result{}, err
 		}
 
+		if stepReady {
+			bpmInfo, err := r.waitForBPM(ctx, instance, manifest, &kubeConfigs, currentManifestSHA1)
+			if err != nil {
+				log.WithEvent(instance, "BPMInformationError").Errorf(ctx, "Failed to get BPM information: %v", err)
+				return reconcile.Result{}, err
+			}
+			if bpmInfo == nil {
+				return reconcile.Result{}, errors.Errorf("missing BPM information for the next step of BoshDeployment '%s/%s'", instance.GetNamespace(), instance.GetName())
+			}
+
+			err = manifest.ApplyBPMInfo(&kubeConfigs, bpmInfo)
+			if err != nil {
+				log.Errorf(ctx, "Failed to apply BPM information: %v", err)
+				return reconcile.Result{}, err
+			}
+
+			err = r.deployStep(ctx, instance, manifest, &kubeConfigs, instance.Status.CurrentStep.Number+1)
+			if err != nil {
+				log.Errorf(ctx, "Failed to deploy instance groups: %v", err)
+				return reconcile.Result{}, err
+			}
+		}
+
+		if instance.Status.State == DeployingState {
+			// Pods are not owned by the BOSHDeployment, so we have to poll for their readiness
+			log.Debugf(ctx, "Waiting for instance groups of BoshDeployment '%s/%s' to become ready", instance.GetNamespace(), instance.GetName())
+			return reconcile.Result{RequeueAfter: 5 * time.Second}, r.updateInstanceState(ctx, instance)
+		}
+
 	case DeployedState:
 		log.WithEvent(instance, "SkipReconcile").Infof(ctx, "Skip reconcile: BoshDeployment '%s/%s' already has been deployed", instance.GetNamespace(), instance.GetName())
 		return reconcile.Result{}, nil
+	case FailedState:
+		log.WithEvent(instance, "SkipReconcile").Infof(ctx, "Skip reconcile: BoshDeployment '%s/%s' has failed: %s", instance.GetNamespace(), instance.GetName(), instance.Status.Reason)
+		return reconcile.Result{}, nil
 	default:
 		return reconcile.Result{}, errors.New("unknown instance state")
 	}
 
-	log.Debugf(ctx, "Requeue the reconcile: BoshDeployment '%s/%s' is in state '%s'", instance.GetNamespace(), instance.GetName(), instance.Status.State)
-	return reconcile.Result{Requeue: true}, r.updateInstanceState(ctx, instance)
+	// Updating the state triggers the next reconcile through the BOSHDeployment watch.
+	// Errors are requeued with the rate-limited backoff of the controller.
+	log.Debugf(ctx, "Updating BoshDeployment '%s/%s' to state '%s'", instance.GetNamespace(), instance.GetName(), instance.Status.State)
+	return reconcile.Result{}, r.updateInstanceState(ctx, instance)
 }
 
 // updateInstanceState update instance state
@@ -272,12 +384,20 @@ func (r *ReconcileBOSHDeployment) updateInstanceState(ctx context.Context, curre
 		foundInstance.Annotations[bdv1.AnnotationManifestSHA1] = currentManifestSHA1
 	}
 
+	if foundInstance.Status.State != currentInstance.Status.State {
+		now := metav1.Now()
+		if foundInstance.Status.State != "" && foundInstance.Status.StateSince != nil {
+			metrics.BOSHDeploymentStateDuration.WithLabelValues(foundInstance.Status.State).Observe(now.Sub(foundInstance.Status.StateSince.Time).Seconds())
+		}
+		currentInstance.Status.StateSince = &now
+	}
+
 	// Update the Status of the resource
-	if !reflect.DeepEqual(foundInstance.Status.State, currentInstance.Status.State) {
+	if !reflect.DeepEqual(foundInstance.Status, currentInstance.Status) {
 		log.Debugf(ctx, "Updating boshDeployment from '%s' to '%s'", foundInstance.Status.State, currentInstance.Status.State)
 
 		newInstance := foundInstance.DeepCopy()
-		newInstance.Status.State = currentInstance.Status.State
+		newInstance.Status = currentInstance.Status
 
 		err = r.client.Update(ctx, newInstance)
 		if err != nil {
@@ -289,8 +409,67 @@ func (r *ReconcileBOSHDeployment) updateInstanceState(ctx context.Context, curre
 	return nil
 }
 
+// convertManifest generates all the kube objects we need for the manifest
+func (r *ReconcileBOSHDeployment) convertManifest(ctx context.Context, instance *bdv1.BOSHDeployment, manifest *bdm.Manifest) (bdm.KubeConfig, error) {
+	// If we have no instance groups, we should stop. There must be something wrong
+	// with the manifest.
+	if len(manifest.InstanceGroups) < 1 {
+		err := log.WithEvent(instance, "MissingInstanceError").Errorf(ctx, "No instance groups defined in manifest %s", manifest.Name)
+		return bdm.KubeConfig{}, err
+	}
+
+	log.Debug(ctx, "Converting bosh manifest to kube objects")
+	kubeConfigs, err := manifest.ConvertToKube(instance.GetNamespace())
+	if err != nil {
+		err = log.WithEvent(instance, "BadManifestError").Errorf(ctx, "Error converting bosh manifest %s to kube objects: %s", manifest.Name, err)
+		return bdm.KubeConfig{}, err
+	}
+
+	return kubeConfigs, nil
+}
+
+// pause reports a pending manifest change of a paused deployment
+func (r *ReconcileBOSHDeployment) pause(ctx context.Context, instance *bdv1.BOSHDeployment, currentManifestSHA1 string) error {
+	message := "no pending manifest change"
+	instance.Status.PendingManifestSHA1 = ""
+	if instance.Status.State == "" || instance.GetAnnotations()[bdv1.AnnotationManifestSHA1] != currentManifestSHA1 {
+		message = fmt.Sprintf("manifest '%s' is pending", currentManifestSHA1)
+		instance.Status.PendingManifestSHA1 = currentManifestSHA1
+	}
+
+	if condition := instance.Status.GetCondition(bdv1.ConditionPaused); condition == nil || condition.Status != corev1.ConditionTrue || condition.Message != message {
+		log.WithEvent(instance, "Paused").Infof(ctx, "BoshDeployment '%s/%s' is paused: %s", instance.GetNamespace(), instance.GetName(), message)
+	}
+	instance.Status.SetCondition(bdv1.ConditionPaused, corev1.ConditionTrue, "Paused", message)
+
+	return r.updateInstanceState(ctx, instance)
+}
+
+// resume marks a deployment as no longer paused. The latest manifest will be deployed.
+func (r *ReconcileBOSHDeployment) resume(ctx context.Context, instance *bdv1.BOSHDeployment) {
+	log.WithEvent(instance, "Resumed").Infof(ctx, "BoshDeployment '%s/%s' is resumed", instance.GetNamespace(), instance.GetName())
+	instance.Status.SetCondition(bdv1.ConditionPaused, corev1.ConditionFalse, "Resumed", "")
+	instance.Status.PendingManifestSHA1 = ""
+
+	// The time spent paused does not count towards the progress deadline
+	if instance.Status.State == DeployingState {
+		now := metav1.Now()
+		instance.Status.DeployingSince = &now
+	}
+}
+
 // resolveManifest resolves the manifest and applies ops files and implicit variable interpolation
 func (r *ReconcileBOSHDeployment) resolveManifest(ctx context.Context, instance *bdv1.BOSHDeployment) (*bdm.Manifest, error) {
+	if instance.Spec.RollbackTo > 0 {
+		log.Debugf(ctx, "Loading manifest revision %d", instance.Spec.RollbackTo)
+		manifest, err := r.manifestRevision(ctx, instance, instance.Spec.RollbackTo)
+		if err != nil {
+			log.WithEvent(instance, "RollbackError").Errorf(ctx, "Error loading revision %d of the manifest %s: %s", instance.Spec.RollbackTo, instance.GetName(), err)
+			return nil, err
+		}
+		return manifest, nil
+	}
+
 	// Create temp manifest as variable interpolation job input
 	// retrieve manifest
 	log.Debug(ctx, "Resolving manifest")
@@ -306,6 +485,132 @@ func (r *ReconcileBOSHDeployment) resolveManifest(ctx context.Context, instance
 	return manifest, nil
 }
 
+// manifestRevision loads an earlier revision of the desired manifest
+func (r *ReconcileBOSHDeployment) manifestRevision(ctx context.Context, instance *bdv1.BOSHDeployment, revision int) (*bdm.Manifest, error) {
+	secretName := names.CalculateSecretName(names.DeploymentSecretTypeManifestWithOps, instance.GetName(), "")
+	secret, err := r.versionedSecretStore.Get(ctx, instance.GetNamespace(), secretName, revision)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get revision %d", revision)
+	}
+
+	manifest := &bdm.Manifest{}
+	err = yaml.Unmarshal(secret.Data["manifest.yaml"], manifest)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal revision %d", revision)
+	}
+	manifest.Name = instance.GetName()
+
+	return manifest, nil
+}
+
+// createManifestRevision stores the desired manifest as a new revision, unless it already is the latest
+// revision, and prunes revisions past the history limit
+func (r *ReconcileBOSHDeployment) createManifestRevision(ctx context.Context, instance *bdv1.BOSHDeployment, manifest *bdm.Manifest, manifestSHA1 string) error {
+	secretName := names.CalculateSecretName(names.DeploymentSecretTypeManifestWithOps, manifest.Name, "")
+
+	revisions, err := r.listManifestRevisions(ctx, instance.GetNamespace(), secretName)
+	if err != nil {
+		return err
+	}
+
+	if len(revisions) == 0 || revisions[len(revisions)-1].ManifestSHA1 != manifestSHA1 {
+		manifestBytes, err := yaml.Marshal(manifest)
+		if err != nil {
+			return errors.Wrap(err, "could not marshal manifest")
+		}
+
+		revision := bdv1.ManifestRevision{
+			Revision:          1,
+			ManifestSHA1:      manifestSHA1,
+			SourceDescription: manifestSourceDescription(instance),
+			CreationTimestamp: metav1.Now(),
+		}
+		if len(revisions) > 0 {
+			revision.Revision = revisions[len(revisions)-1].Revision + 1
+		}
+
+		log.Debugf(ctx, "Creating revision %d of manifest '%s'", revision.Revision, secretName)
+		_, err = r.versionedSecretStore.Create(
+			ctx,
+			instance.GetNamespace(),
+			secretName,
+			map[string]string{"manifest.yaml": string(manifestBytes)},
+			map[string]string{
+				bdv1.LabelDeploymentName:   manifest.Name,
+				bdv1.LabelManifestSHA1:     manifestSHA1,
+				bdv1.LabelManifestRevision: strconv.Itoa(revision.Revision),
+			},
+			revision.SourceDescription,
+		)
+		if err != nil {
+			return errors.Wrapf(err, "could not create revision %d of manifest '%s'", revision.Revision, secretName)
+		}
+		revisions = append(revisions, revision)
+	}
+
+	// Prune the oldest revisions, but keep the one which is rolled back to
+	excess := len(revisions) - instance.RevisionLimit()
+	kept := []bdv1.ManifestRevision{}
+	for _, revision := range revisions {
+		if excess > 0 && revision.Revision != instance.Spec.RollbackTo {
+			log.Debugf(ctx, "Pruning revision %d of manifest '%s'", revision.Revision, secretName)
+			err := r.versionedSecretStore.DeleteVersion(ctx, instance.GetNamespace(), secretName, revision.Revision)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return errors.Wrapf(err, "could not prune revision %d of manifest '%s'", revision.Revision, secretName)
+			}
+			excess--
+			continue
+		}
+		kept = append(kept, revision)
+	}
+
+	instance.Status.Revisions = kept
+	instance.Status.CurrentRevision = kept[len(kept)-1].Revision
+
+	return nil
+}
+
+// listManifestRevisions returns the stored revisions of the desired manifest, ordered by revision
+func (r *ReconcileBOSHDeployment) listManifestRevisions(ctx context.Context, namespace string, secretName string) ([]bdv1.ManifestRevision, error) {
+	secrets, err := r.versionedSecretStore.List(ctx, namespace, secretName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list revisions of manifest '%s'", secretName)
+	}
+
+	revisions := []bdv1.ManifestRevision{}
+	for _, secret := range secrets {
+		version, err := names.GetVersionFromVersionedSecretName(secret.GetName())
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid revision name '%s'", secret.GetName())
+		}
+
+		revisions = append(revisions, bdv1.ManifestRevision{
+			Revision:          version,
+			ManifestSHA1:      secret.GetLabels()[bdv1.LabelManifestSHA1],
+			SourceDescription: secret.GetAnnotations()[versionedsecretstore.AnnotationSourceDescription],
+			CreationTimestamp: secret.GetCreationTimestamp(),
+		})
+	}
+
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Revision < revisions[j].Revision })
+
+	return revisions, nil
+}
+
+// manifestSourceDescription describes where a revision of the desired manifest comes from
+func manifestSourceDescription(instance *bdv1.BOSHDeployment) string {
+	if instance.Spec.RollbackTo > 0 {
+		return fmt.Sprintf("rollback of BOSHDeployment %s/%s to revision %d", instance.GetNamespace(), instance.GetName(), instance.Spec.RollbackTo)
+	}
+
+	sources := []string{fmt.Sprintf("manifest %s/%s", instance.Spec.Manifest.Type, instance.Spec.Manifest.Ref)}
+	for _, op := range instance.Spec.Ops {
+		sources = append(sources, fmt.Sprintf("ops %s/%s", op.Type, op.Ref))
+	}
+
+	return fmt.Sprintf("created by BOSHDeployment %s/%s from %s", instance.GetNamespace(), instance.GetName(), strings.Join(sources, ", "))
+}
+
 // setSpecsOwnerReference set manifest/ops ownerReference as BOSHDeployment instance
 func (r *ReconcileBOSHDeployment) setSpecsOwnerReference(ctx context.Context, instance *bdv1.BOSHDeployment) error {
 	var err error
@@ -429,8 +734,21 @@ func (r *ReconcileBOSHDeployment) setFinalizer(ctx context.Context, instance *bd
 	return nil
 }
 
-// handleDeletion remove all ownership from configs and the finalizer from instance
+// handleDeletion tears down the instance groups, then removes all ownership from configs and the finalizer from instance
 func (r *ReconcileBOSHDeployment) handleDeletion(ctx context.Context, instance *bdv1.BOSHDeployment) (reconcile.Result, error) {
+	done, err := r.teardown(ctx, instance)
+	if err != nil {
+		log.WithEvent(instance, "TeardownError").Errorf(ctx, "Failed to tear down BOSHDeployment '%s': %v", instance.GetName(), err)
+		if updateErr := r.updateInstanceState(ctx, instance); updateErr != nil {
+			log.Errorf(ctx, "Failed to update teardown progress of BOSHDeployment '%s': %v", instance.GetName(), updateErr)
+		}
+		return reconcile.Result{}, err
+	}
+	if !done {
+		// ExtendedStatefulSets are not watched, so we have to poll for their deletion
+		log.Infof(ctx, "Tearing down BOSHDeployment '%s/%s': %s", instance.GetNamespace(), instance.GetName(), instance.Status.Teardown.Message)
+		return reconcile.Result{RequeueAfter: 5 * time.Second}, r.updateInstanceState(ctx, instance)
+	}
 
 	existingConfigs, err := r.owner.ListConfigsOwnedBy(ctx, instance)
 	if err != nil {
@@ -483,44 +801,14 @@ func (r *ReconcileBOSHDeployment) generateVariableSecrets(ctx context.Context, i
 	}
 
 	instance.Status.State = VariableGeneratedState
+	instance.Status.SetCondition(bdv1.ConditionVariablesGenerated, corev1.ConditionTrue, "ExtendedSecretsCreated", "")
 
 	return nil
 }
 
-// createVariableInterpolationEJob create temp manifest and variable interpolation eJob
-func (r *ReconcileBOSHDeployment) createVariableInterpolationEJob(ctx context.Context, instance *bdv1.BOSHDeployment, manifest *bdm.Manifest, kubeConfig bdm.KubeConfig) error {
-
-	// Create temp manifest as variable interpolation job input.
-	// Ops files have been applied on this manifest.
-	tempManifestBytes, err := yaml.Marshal(manifest)
-	if err != nil {
-		return errors.Wrap(err, "could not marshal temp manifest")
-	}
-
-	tempManifestSecretName := names.CalculateSecretName(names.DeploymentSecretTypeManifestWithOps, manifest.Name, "")
-
-	// Create a secret object for the manifest
-	tempManifestSecret := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      tempManifestSecretName,
-			Namespace: instance.GetNamespace(),
-		},
-	}
-	_, err = controllerutil.CreateOrUpdate(ctx, r.client, tempManifestSecret, func(obj runtime.Object) error {
-		s, ok := obj.(*corev1.Secret)
-		if !ok {
-			return fmt.Errorf("object is not a Secret")
-		}
-		s.Data = map[string][]byte{}
-		s.StringData = map[string]string{
-			"manifest.yaml": string(tempManifestBytes),
-		}
-		return nil
-	})
-	if err != nil {
-		return errors.Wrapf(err, "creating or updating Secret '%s'", tempManifestSecret.Name)
-	}
-
+// createVariableInterpolationEJob creates the variable interpolation eJob. It reads the manifest
+// with ops applied from the latest manifest revision, which is stored by createManifestRevision.
+func (r *ReconcileBOSHDeployment) createVariableInterpolationEJob(ctx context.Context, instance *bdv1.BOSHDeployment, kubeConfig bdm.KubeConfig) error {
 	// Generate the ExtendedJob object
 	log.Debug(ctx, "Creating variable interpolation extendedJob")
 	varIntEJob := kubeConfig.VariableInterpolationJob
@@ -530,7 +818,7 @@ func (r *ReconcileBOSHDeployment) createVariableInterpolationEJob(ctx context.Co
 		return err
 	}
 
-	_, err = controllerutil.CreateOrUpdate(ctx, r.client, varIntEJob.DeepCopy(), func(obj runtime.Object) error {
+	_, err := controllerutil.CreateOrUpdate(ctx, r.client, varIntEJob.DeepCopy(), func(obj runtime.Object) error {
 		exstEJob, ok := obj.(*ejv1.ExtendedJob)
 		if !ok {
 			return fmt.Errorf("object is not an ExtendedJob")
@@ -546,6 +834,7 @@ func (r *ReconcileBOSHDeployment) createVariableInterpolationEJob(ctx context.Co
 	}
 
 	instance.Status.State = VariableInterpolatedState
+	instance.Status.SetCondition(bdv1.ConditionVariablesInterpolated, corev1.ConditionTrue, "ExtendedJobCreated", "")
 
 	return nil
 }
@@ -579,15 +868,14 @@ func (r *ReconcileBOSHDeployment) createDataGatheringJob(ctx context.Context, in
 	}
 
 	instance.Status.State = DataGatheredState
+	instance.Status.SetCondition(bdv1.ConditionDataGathered, corev1.ConditionTrue, "ExtendedJobCreated", "")
 
 	return nil
 }
 
-// waitForBPM checks to see if all BPM information is available and returns an error if it isn't
-func (r *ReconcileBOSHDeployment) waitForBPM(ctx context.Context, deployment *bdv1.BOSHDeployment, manifest *bdm.Manifest, kubeConfigs *bdm.KubeConfig) (map[string]bdm.Manifest, error) {
-	// TODO: this approach is not good enough, we need to reconcile and trigger on all of these secrets
-	// TODO: these secrets could exist, but not be up to date - we have to make sure they exist for the appropriate version
-
+// waitForBPM returns the BPM information of all instance groups, generated for the given manifest signature.
+// It returns nil if the data gathering job has not generated all of it yet.
+func (r *ReconcileBOSHDeployment) waitForBPM(ctx context.Context, deployment *bdv1.BOSHDeployment, manifest *bdm.Manifest, kubeConfigs *bdm.KubeConfig, manifestSHA1 string) (map[string]bdm.Manifest, error) {
 	result := map[string]bdm.Manifest{}
 
 	for _, container := range kubeConfigs.DataGatheringJob.Spec.Template.Spec.Containers {
@@ -598,17 +886,24 @@ func (r *ReconcileBOSHDeployment) waitForBPM(ctx context.Context, deployment *bd
 			false,
 		)
 
-		log.Debugf(ctx, "Getting latest secret '%s'", secretName)
-		secret, err := r.versionedSecretStore.Latest(ctx, deployment.Namespace, secretName)
-		if err != nil && apierrors.IsNotFound(err) {
-			return nil, fmt.Errorf("secret %s/%s doesn't exist", deployment.Namespace, secretName)
-		} else if err != nil {
+		log.Debugf(ctx, "Getting latest secret '%s' for manifest '%s'", secretName, manifestSHA1)
+		secret, err := r.latestVersionForManifest(ctx, deployment.Namespace, secretName, manifestSHA1)
+		if err != nil {
 			return nil, errors.Wrapf(err, "failed to retrieve resolved properties secret %s/%s", deployment.Namespace, secretName)
 		}
+		if secret == nil {
+			log.Debugf(ctx, "Resolved properties secret %s/%s doesn't exist for manifest '%s'", deployment.Namespace, secretName, manifestSHA1)
+			return nil, nil
+		}
+
+		data, err := r.versionedSecretStore.Decode(ctx, secret)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode resolved properties secret %s/%s", deployment.Namespace, secretName)
+		}
 
 		resolvedProperties := bdm.Manifest{}
 
-		err = yaml.Unmarshal(secret.Data["properties.yaml"], &resolvedProperties)
+		err = yaml.Unmarshal(data["properties.yaml"], &resolvedProperties)
 		if err != nil {
 			return nil, fmt.Errorf("couldn't unmarshal resolved properties from secret %s/%s", deployment.Namespace, secretName)
 		}
@@ -618,32 +913,36 @@ func (r *ReconcileBOSHDeployment) waitForBPM(ctx context.Context, deployment *bd
 	return result, nil
 }
 
-// deployInstanceGroups create ExtendedJobs and ExtendedStatefulSets
-func (r *ReconcileBOSHDeployment) deployInstanceGroups(ctx context.Context, instance *bdv1.BOSHDeployment, kubeConfigs *bdm.KubeConfig) error {
-	log.Debug(ctx, "Creating extendedJobs and extendedStatefulSets of instance groups")
-	for _, eJob := range kubeConfigs.Errands {
-		// Set BOSHDeployment instance as the owner and controller
-		if err := r.setReference(instance, &eJob, r.scheme); err != nil {
-			log.WarningEvent(ctx, instance, "NewExtendedJobForDeploymentError", err.Error())
-			return errors.Wrap(err, "couldn't set reference for an ExtendedJob for a BOSH Deployment")
-		}
+// latestVersionForManifest returns the latest version of a versioned secret, which was generated for the given
+// manifest signature. It returns nil if there is no such version.
+func (r *ReconcileBOSHDeployment) latestVersionForManifest(ctx context.Context, namespace string, secretName string, manifestSHA1 string) (*corev1.Secret, error) {
+	secrets, err := r.versionedSecretStore.List(ctx, namespace, secretName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list versions of secret %s/%s", namespace, secretName)
+	}
 
-		_, err := controllerutil.CreateOrUpdate(ctx, r.client, eJob.DeepCopy(), func(obj runtime.Object) error {
-			exstEJob, ok := obj.(*ejv1.ExtendedJob)
-			if !ok {
-				return fmt.Errorf("object is not an ExtendedJob")
-			}
+	var result *corev1.Secret
+	latestVersion := -1
+	for i := range secrets {
+		if secrets[i].GetLabels()[bdv1.LabelManifestSHA1] != manifestSHA1 {
+			continue
+		}
 
-			exstEJob.Labels = eJob.Labels
-			exstEJob.Spec = eJob.Spec
-			return nil
-		})
+		version, err := names.GetVersionFromVersionedSecretName(secrets[i].GetName())
 		if err != nil {
-			log.WarningEvent(ctx, instance, "CreateExtendedJobForDeploymentError", err.Error())
-			return errors.Wrapf(err, "creating or updating ExtendedJob '%s'", eJob.Name)
+			return nil, errors.Wrapf(err, "invalid versioned secret name '%s'", secrets[i].GetName())
+		}
+		if version > latestVersion {
+			latestVersion = version
+			result = &secrets[i]
 		}
 	}
 
+	return result, nil
+}
+
+// deployInstanceGroups creates the services of all instance groups and starts deploying the first step
+func (r *ReconcileBOSHDeployment) deployInstanceGroups(ctx context.Context, instance *bdv1.BOSHDeployment, manifest *bdm.Manifest, kubeConfigs *bdm.KubeConfig) error {
 	log.Debugf(ctx, "Get result: %v",kubeConfigs.Services)
 	for _, svc := range kubeConfigs.Services {
 		// Set BOSHDeployment instance as the owner and controller
@@ -668,7 +967,69 @@ func (r *ReconcileBOSHDeployment) deployInstanceGroups(ctx context.Context, inst
 		}
 	}
 
+	instance.Status.State = DeployingState
+	instance.Status.Reason = ""
+	instance.Status.InstanceGroups = nil
+
+	return r.deployStep(ctx, instance, manifest, kubeConfigs, 1)
+}
+
+// deployStep creates or updates the ExtendedJobs and ExtendedStatefulSets of the instance groups in a deployment step
+func (r *ReconcileBOSHDeployment) deployStep(ctx context.Context, instance *bdv1.BOSHDeployment, manifest *bdm.Manifest, kubeConfigs *bdm.KubeConfig, number int) error {
+	steps := manifest.DeploymentSteps()
+	if number < 1 || number > len(steps) {
+		return errors.Errorf("deployment step %d does not exist, there are %d steps", number, len(steps))
+	}
+
+	step := map[string]bool{}
+	for _, igName := range steps[number-1] {
+		step[igName] = true
+	}
+
+	log.Debugf(ctx, "Creating extendedJobs and extendedStatefulSets of step %d/%d: %s", number, len(steps), strings.Join(steps[number-1], ", "))
+
+	// Auto-errands are triggered again, the run ID identifies their runs for this step
+	runID := strconv.FormatInt(time.Now().UnixNano(), 36)
+	for _, eJob := range kubeConfigs.Errands {
+		if !step[instanceGroupName(&eJob)] {
+			continue
+		}
+
+		// Set BOSHDeployment instance as the owner and controller
+		if err := r.setReference(instance, &eJob, r.scheme); err != nil {
+			log.WarningEvent(ctx, instance, "NewExtendedJobForDeploymentError", err.Error())
+			return errors.Wrap(err, "couldn't set reference for an ExtendedJob for a BOSH Deployment")
+		}
+
+		_, err := controllerutil.CreateOrUpdate(ctx, r.client, eJob.DeepCopy(), func(obj runtime.Object) error {
+			exstEJob, ok := obj.(*ejv1.ExtendedJob)
+			if !ok {
+				return fmt.Errorf("object is not an ExtendedJob")
+			}
+
+			exstEJob.Labels = eJob.Labels
+			exstEJob.Spec = eJob.Spec
+			if eJob.IsAutoErrand() {
+				annotations := exstEJob.GetAnnotations()
+				if annotations == nil {
+					annotations = map[string]string{}
+				}
+				annotations[ejv1.AnnotationRunID] = runID
+				exstEJob.SetAnnotations(annotations)
+			}
+			return nil
+		})
+		if err != nil {
+			log.WarningEvent(ctx, instance, "CreateExtendedJobForDeploymentError", err.Error())
+			return errors.Wrapf(err, "creating or updating ExtendedJob '%s'", eJob.Name)
+		}
+	}
+
 	for _, eSts := range kubeConfigs.InstanceGroups {
+		if !step[instanceGroupName(&eSts)] {
+			continue
+		}
+
 		// Set BOSHDeployment instance as the owner and controller
 		if err := r.setReference(instance, &eSts, r.scheme); err != nil {
 			log.WarningEvent(ctx, instance, "NewExtendedStatefulSetForDeploymentError", err.Error())
@@ -691,15 +1052,240 @@ func (r *ReconcileBOSHDeployment) deployInstanceGroups(ctx context.Context, inst
 		}
 	}
 
-	instance.Status.State = DeployingState
+	now := metav1.Now()
+	instance.Status.DeployingSince = &now
+	instance.Status.CurrentStep = &bdv1.DeploymentStep{
+		Number:         number,
+		Total:          len(steps),
+		InstanceGroups: steps[number-1],
+	}
 
 	return nil
 }
 
-// actionOnDeploying check out deployment status
-func (r *ReconcileBOSHDeployment) actionOnDeploying(ctx context.Context, instance *bdv1.BOSHDeployment, kubeConfigs *bdm.KubeConfig) error {
-	// TODO Check deployment
-	instance.Status.State = DeployedState
+// actionOnDeploying checks if the instance groups of all steps so far are ready and auto-errands have completed.
+// It returns true if that is the case and another step has to be deployed. A step is not left while only
+// older versions of its instance groups are ready.
+// The deployment fails if a step is not ready before the progress deadline.
+func (r *ReconcileBOSHDeployment) actionOnDeploying(ctx context.Context, instance *bdv1.BOSHDeployment, manifest *bdm.Manifest, kubeConfigs *bdm.KubeConfig) (bool, error) {
+	steps := manifest.DeploymentSteps()
+	current := len(steps)
+	if instance.Status.CurrentStep != nil && instance.Status.CurrentStep.Number < current {
+		current = instance.Status.CurrentStep.Number
+	}
+
+	deployed := map[string]bool{}
+	for _, step := range steps[:current] {
+		for _, igName := range step {
+			deployed[igName] = true
+		}
+	}
+
+	instanceGroups := map[string]bdv1.InstanceGroupStatus{}
+	pending := []string{}
+
+	for _, eSts := range kubeConfigs.InstanceGroups {
+		if !deployed[instanceGroupName(&eSts)] {
+			continue
+		}
+
+		status, err := r.extendedStatefulSetStatus(ctx, &eSts)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to check ExtendedStatefulSet '%s'", eSts.Name)
+		}
+
+		igName := instanceGroupName(&eSts)
+		instanceGroups[igName] = status
+		if !status.Ready {
+			pending = append(pending, igName)
+		}
+	}
+
+	for _, eJob := range kubeConfigs.Errands {
+		if !eJob.IsAutoErrand() || !deployed[instanceGroupName(&eJob)] {
+			continue
+		}
+
+		status, err := r.autoErrandStatus(ctx, &eJob)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to check ExtendedJob '%s'", eJob.Name)
+		}
+
+		igName := instanceGroupName(&eJob)
+		instanceGroups[igName] = status
+		if !status.Ready {
+			pending = append(pending, igName)
+		}
+	}
+
+	instance.Status.InstanceGroups = instanceGroups
+
+	if len(pending) == 0 && current < len(steps) {
+		log.Infof(ctx, "Step %d/%d of BoshDeployment '%s/%s' is ready", current, len(steps), instance.GetNamespace(), instance.GetName())
+		instance.Status.SetCondition(bdv1.ConditionInstanceGroupsReady, corev1.ConditionFalse, "InstanceGroupsNotReady", "waiting for "+strings.Join(steps[current], ", "))
+		return true, nil
+	}
+
+	if len(pending) == 0 {
+		log.Infof(ctx, "All instance groups of BoshDeployment '%s/%s' are ready", instance.GetNamespace(), instance.GetName())
+		instance.Status.State = DeployedState
+		instance.Status.Reason = ""
+		instance.Status.CurrentStep = nil
+		instance.Status.SetCondition(bdv1.ConditionInstanceGroupsReady, corev1.ConditionTrue, "InstanceGroupsReady", "")
+		return false, nil
+	}
+
+	instance.Status.SetCondition(bdv1.ConditionInstanceGroupsReady, corev1.ConditionFalse, "InstanceGroupsNotReady", "waiting for "+strings.Join(pending, ", "))
+
+	if instance.Status.DeployingSince == nil {
+		now := metav1.Now()
+		instance.Status.DeployingSince = &now
+	}
+
+	deadline := instance.ProgressDeadline()
+	if time.Since(instance.Status.DeployingSince.Time) > deadline {
+		instance.Status.State = FailedState
+		instance.Status.Reason = fmt.Sprintf("instance groups not ready after %s: %s", deadline, strings.Join(pending, ", "))
+		instance.Status.SetCondition(bdv1.ConditionFailed, corev1.ConditionTrue, "ProgressDeadlineExceeded", instance.Status.Reason)
+		log.WarningEvent(ctx, instance, "DeploymentFailed", instance.Status.Reason)
+		return false, nil
+	}
+
+	log.Debugf(ctx, "BoshDeployment '%s/%s' is waiting for instance groups: %s", instance.GetNamespace(), instance.GetName(), strings.Join(pending, ", "))
+
+	return false, nil
+}
+
+// extendedStatefulSetStatus checks if the version of an ExtendedStatefulSet, which was created for its
+// applied spec, is available and all its pods are ready. Older versions might still be ready while the
+// ExtendedStatefulSet controller has not processed the spec yet, they are identified by their template SHA1.
+func (r *ReconcileBOSHDeployment) extendedStatefulSetStatus(ctx context.Context, desired *estsv1.ExtendedStatefulSet) (bdv1.InstanceGroupStatus, error) {
+	status := bdv1.InstanceGroupStatus{}
+
+	eSts := &estsv1.ExtendedStatefulSet{}
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: desired.Namespace, Name: desired.Name}, eSts)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			status.Message = "ExtendedStatefulSet does not exist yet"
+			return status, nil
+		}
+		return status, err
+	}
+
+	// The ExtendedStatefulSet controller resolves the versioned references before it calculates the template SHA1
+	applied := eSts.DeepCopy()
+	podSpec := &applied.Spec.Template.Spec.Template.Spec
+	err = r.versionedSecretStore.UpdateSecretReferences(ctx, eSts.Namespace, podSpec)
+	if err != nil {
+		return status, err
+	}
+	err = r.versionedConfigMapStore.UpdateConfigMapReferences(ctx, eSts.Namespace, podSpec)
+	if err != nil {
+		return status, err
+	}
+	templateSHA1, err := applied.CalculateStatefulSetSHA1()
+	if err != nil {
+		return status, err
+	}
+
+	// The ExtendedStatefulSet controller creates the StatefulSet of a new version before it is listed in the status
+	statefulSets := &v1beta2.StatefulSetList{}
+	err = r.client.List(ctx, &client.ListOptions{Namespace: eSts.Namespace, LabelSelector: labels.Everything()}, statefulSets)
+	if err != nil {
+		return status, err
+	}
+
+	found := false
+	for _, statefulSet := range statefulSets.Items {
+		if !metav1.IsControlledBy(&statefulSet, eSts) {
+			continue
+		}
+		if statefulSet.Annotations[estsv1.AnnotationStatefulSetSHA1] != templateSHA1 {
+			continue
+		}
+
+		version, err := strconv.Atoi(statefulSet.Annotations[estsv1.AnnotationVersion])
+		if err != nil {
+			return status, errors.Wrapf(err, "invalid version of StatefulSet '%s'", statefulSet.Name)
+		}
+
+		found = true
+		status.Version = version
+		if statefulSet.Spec.Replicas != nil {
+			status.Replicas += *statefulSet.Spec.Replicas
+		}
+		status.ReadyReplicas += statefulSet.Status.ReadyReplicas
+	}
+
+	switch {
+	case !found:
+		status.Message = "StatefulSets of the applied spec do not exist yet"
+	case !eSts.Status.Versions[status.Version]:
+		status.Message = fmt.Sprintf("waiting for version %d to become available", status.Version)
+	case status.ReadyReplicas < status.Replicas:
+		status.Message = fmt.Sprintf("%d of %d pods are ready", status.ReadyReplicas, status.Replicas)
+	default:
+		status.Ready = true
+	}
+
+	return status, nil
+}
+
+// autoErrandStatus checks if an auto-errand has been triggered and the run triggered by the current
+// deployment step succeeded
+func (r *ReconcileBOSHDeployment) autoErrandStatus(ctx context.Context, desired *ejv1.ExtendedJob) (bdv1.InstanceGroupStatus, error) {
+	status := bdv1.InstanceGroupStatus{Replicas: 1}
 
+	eJob := &ejv1.ExtendedJob{}
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: desired.Namespace, Name: desired.Name}, eJob)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			status.Message = "ExtendedJob does not exist yet"
+			return status, nil
+		}
+		return status, err
+	}
+
+	if eJob.Spec.Trigger.Strategy != ejv1.TriggerDone {
+		status.Message = "waiting for the errand to be triggered"
+		return status, nil
+	}
+
+	// Succeeded jobs are deleted by the ExtendedJob controller, so the result is taken from the runs
+	runID := eJob.GetAnnotations()[ejv1.AnnotationRunID]
+	run := findRun(eJob, runID)
+	if run == nil {
+		status.Message = "waiting for the errand to finish"
+		return status, nil
+	}
+	if run.Result != ejv1.RunSucceeded {
+		status.Message = fmt.Sprintf("job '%s' failed", run.JobName)
+		return status, nil
+	}
+
+	status.ReadyReplicas = 1
+	status.Ready = true
+
+	return status, nil
+}
+
+// findRun returns the latest run of an extended job with the run ID, or nil
+func findRun(eJob *ejv1.ExtendedJob, runID string) *ejv1.Run {
+	if runID == "" {
+		return nil
+	}
+	for i := range eJob.Status.Runs {
+		if eJob.Status.Runs[i].RunID == runID {
+			return &eJob.Status.Runs[i]
+		}
+	}
 	return nil
 }
+
+// instanceGroupName returns the instance group an object was generated for
+func instanceGroupName(object metav1.Object) string {
+	if name, ok := object.GetLabels()[bdm.LabelInstanceGroupName]; ok {
+		return name
+	}
+	return object.GetName()
+}
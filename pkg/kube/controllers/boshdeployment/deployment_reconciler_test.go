@@ -8,7 +8,10 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"go.uber.org/zap"
+	yaml "gopkg.in/yaml.v2"
 
+	"k8s.io/api/apps/v1beta2"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -21,14 +24,21 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/bpm"
 	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
 	"code.cloudfoundry.org/cf-operator/pkg/bosh/manifest/fakes"
 	bdc "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+	esv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedsecret/v1alpha1"
+	essv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedstatefulset/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
 	cfd "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/boshdeployment"
 	cfakes "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/fakes"
 	cfcfg "code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
 	ctxlog "code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/finalizer"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/names"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/versionedsecretstore"
 	helper "code.cloudfoundry.org/cf-operator/pkg/testhelper"
 )
 
@@ -149,6 +159,13 @@ var _ = Describe("ReconcileBoshDeployment", func() {
 
 				// check for events
 				Expect(<-recorder.Events).To(ContainSubstring("ResolveManifestError"))
+
+				Expect(client.UpdateCallCount()).To(Equal(1))
+				_, object := client.UpdateArgsForCall(0)
+				condition := object.(*bdc.BOSHDeployment).Status.GetCondition(bdc.ConditionManifestResolved)
+				Expect(condition).ToNot(BeNil())
+				Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+				Expect(condition.Message).To(ContainSubstring("resolver error"))
 			})
 
 			It("handles errors when missing instance groups", func() {
@@ -218,24 +235,973 @@ var _ = Describe("ReconcileBoshDeployment", func() {
 			It("goes from ops applied to variable generated state successfully", func() {
 				result, err := reconciler.Reconcile(request)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(result).To(Equal(reconcile.Result{
-					Requeue: true,
-				}))
+				Expect(result).To(Equal(reconcile.Result{}))
 
 				instance := &bdc.BOSHDeployment{}
 				err = client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, instance)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(instance.Status.State).To(Equal(cfd.OpsAppliedState))
+				Expect(instance.Status.StateSince).ToNot(BeNil())
+				Expect(instance.Status.ManifestSHA1).To(Equal(instance.Annotations[bdc.AnnotationManifestSHA1]))
+				Expect(instance.Status.GetCondition(bdc.ConditionManifestResolved).Status).To(Equal(corev1.ConditionTrue))
+				Expect(instance.Status.GetCondition(bdc.ConditionVariablesGenerated).Status).To(Equal(corev1.ConditionFalse))
 
 				result, err = reconciler.Reconcile(request)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(result).To(Equal(reconcile.Result{
-					Requeue: true,
-				}))
+				Expect(result).To(Equal(reconcile.Result{}))
 
 				err = client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, instance)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(instance.Status.State).To(Equal(cfd.VariableGeneratedState))
+				Expect(instance.Status.GetCondition(bdc.ConditionVariablesGenerated).Status).To(Equal(corev1.ConditionTrue))
+			})
+		})
+
+		Context("when watching all namespaces", func() {
+			var (
+				client client.Client
+			)
+
+			BeforeEach(func() {
+				config.Namespace = "cf-operator"
+				config.WatchAllNamespaces = true
+				request = reconcile.Request{NamespacedName: types.NamespacedName{Name: "foo", Namespace: "cf-1"}}
+
+				client = fake.NewFakeClient(
+					&corev1.Namespace{
+						TypeMeta:   metav1.TypeMeta{Kind: "Namespace", APIVersion: "v1"},
+						ObjectMeta: metav1.ObjectMeta{Name: "cf-1"},
+					},
+					&bdc.BOSHDeployment{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "foo",
+							Namespace: "cf-1",
+						},
+					},
+				)
+				manager.GetClientReturns(client)
+			})
+
+			It("labels the namespace of the deployment for the webhooks", func() {
+				_, err := reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+
+				ns := &corev1.Namespace{}
+				err = client.Get(context.Background(), types.NamespacedName{Name: "cf-1"}, ns)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ns.GetLabels()).To(HaveKeyWithValue("cf-operator-ns", "cf-operator"))
+			})
+
+			It("creates the resources in the namespace of the deployment", func() {
+				_, err := reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+
+				eSecret := &esv1.ExtendedSecret{}
+				err = client.Get(context.Background(), types.NamespacedName{Name: names.CalculateSecretName(names.DeploymentSecretTypeGeneratedVariable, manifest.Name, "foo_password"), Namespace: "cf-1"}, eSecret)
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the deployment is paused", func() {
+			var (
+				client       client.Client
+				deployment   *bdc.BOSHDeployment
+				manifestSHA1 string
+			)
+
+			BeforeEach(func() {
+				var err error
+				config.Namespace = "default"
+				manifest.Name = "foo"
+				manifestSHA1, err = manifest.SHA1()
+				Expect(err).ToNot(HaveOccurred())
+
+				deployment = &bdc.BOSHDeployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Spec: bdc.BOSHDeploymentSpec{Paused: true},
+				}
+			})
+
+			JustBeforeEach(func() {
+				client = fake.NewFakeClient(deployment)
+				manager.GetClientReturns(client)
+				reconciler = cfd.NewReconciler(ctx, config, manager, &resolver, controllerutil.SetControllerReference)
+			})
+
+			getInstance := func() *bdc.BOSHDeployment {
+				instance := &bdc.BOSHDeployment{}
+				err := client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, instance)
+				Expect(err).ToNot(HaveOccurred())
+				return instance
+			}
+
+			It("reports the pending manifest without creating anything", func() {
+				result, err := reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(Equal(reconcile.Result{}))
+
+				instance := getInstance()
+				Expect(instance.Status.State).To(BeEmpty())
+				Expect(instance.Status.PendingManifestSHA1).To(Equal(manifestSHA1))
+				Expect(instance.Status.GetCondition(bdc.ConditionPaused).Status).To(Equal(corev1.ConditionTrue))
+				Expect(<-recorder.Events).To(ContainSubstring("Paused"))
+
+				// A second reconcile does not move through the states either
+				_, err = reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(getInstance().Status.State).To(BeEmpty())
+
+				variableName := names.CalculateSecretName(names.DeploymentSecretTypeGeneratedVariable, "foo", "foo_password")
+				err = client.Get(context.Background(), types.NamespacedName{Name: variableName, Namespace: "default"}, &esv1.ExtendedSecret{})
+				Expect(errors.IsNotFound(err)).To(BeTrue())
+			})
+
+			Context("when the deployment references a manifest config map", func() {
+				BeforeEach(func() {
+					deployment.Spec.Manifest = bdc.Manifest{Type: bdc.ConfigMapType, Ref: "manifest"}
+				})
+
+				It("neither owns the config map nor adds its finalizer", func() {
+					err := client.Create(context.Background(), &corev1.ConfigMap{
+						ObjectMeta: metav1.ObjectMeta{Name: "manifest", Namespace: "default"},
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = reconciler.Reconcile(request)
+					Expect(err).NotTo(HaveOccurred())
+
+					configMap := &corev1.ConfigMap{}
+					err = client.Get(context.Background(), types.NamespacedName{Name: "manifest", Namespace: "default"}, configMap)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(configMap.GetOwnerReferences()).To(BeEmpty())
+					Expect(getInstance().GetFinalizers()).To(BeEmpty())
+				})
+			})
+
+			Context("when the deployment is deployed", func() {
+				BeforeEach(func() {
+					deployment.Annotations = map[string]string{bdc.AnnotationManifestSHA1: "old"}
+					deployment.Status.State = cfd.DeployedState
+				})
+
+				It("does not update the deployment", func() {
+					_, err := reconciler.Reconcile(request)
+					Expect(err).NotTo(HaveOccurred())
+
+					instance := getInstance()
+					Expect(instance.Status.State).To(Equal(cfd.DeployedState))
+					Expect(instance.Annotations[bdc.AnnotationManifestSHA1]).To(Equal("old"))
+					Expect(instance.Status.PendingManifestSHA1).To(Equal(manifestSHA1))
+				})
+
+				Context("when the deployment is resumed", func() {
+					BeforeEach(func() {
+						deployment.Spec.Paused = false
+						deployment.Status.PendingManifestSHA1 = manifestSHA1
+						deployment.Status.SetCondition(bdc.ConditionPaused, corev1.ConditionTrue, "Paused", "")
+					})
+
+					It("applies the latest manifest", func() {
+						_, err := reconciler.Reconcile(request)
+						Expect(err).NotTo(HaveOccurred())
+
+						instance := getInstance()
+						Expect(instance.Status.State).To(Equal(cfd.OpsAppliedState))
+						Expect(instance.Status.ManifestSHA1).To(Equal(manifestSHA1))
+						Expect(instance.Status.PendingManifestSHA1).To(BeEmpty())
+						Expect(instance.Status.GetCondition(bdc.ConditionPaused).Status).To(Equal(corev1.ConditionFalse))
+					})
+				})
+			})
+		})
+
+		Context("when keeping a manifest revision history", func() {
+			var (
+				client       client.Client
+				deployment   *bdc.BOSHDeployment
+				secrets      []runtime.Object
+				secretName   string
+				manifestSHA1 string
+			)
+
+			revision := func(version int, sha1 string, m *bdm.Manifest) *corev1.Secret {
+				manifestBytes, err := yaml.Marshal(m)
+				Expect(err).ToNot(HaveOccurred())
+				return &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("%s-v%d", secretName, version),
+						Namespace: "default",
+						Labels: map[string]string{
+							versionedsecretstore.LabelSecretKind: versionedsecretstore.VersionSecretKind,
+							versionedsecretstore.LabelVersion:    fmt.Sprintf("%d", version),
+							bdc.LabelDeploymentName:              "foo",
+							bdc.LabelManifestSHA1:                sha1,
+						},
+					},
+					Data: map[string][]byte{"manifest.yaml": manifestBytes},
+				}
+			}
+
+			getInstance := func() *bdc.BOSHDeployment {
+				instance := &bdc.BOSHDeployment{}
+				err := client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, instance)
+				Expect(err).ToNot(HaveOccurred())
+				return instance
+			}
+
+			BeforeEach(func() {
+				var err error
+				config.Namespace = "default"
+				manifest.Name = "foo"
+				manifestSHA1, err = manifest.SHA1()
+				Expect(err).ToNot(HaveOccurred())
+				secretName = names.CalculateSecretName(names.DeploymentSecretTypeManifestWithOps, "foo", "")
+
+				deployment = &bdc.BOSHDeployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+				}
+				secrets = []runtime.Object{}
+			})
+
+			JustBeforeEach(func() {
+				client = fake.NewFakeClient(append(secrets, deployment)...)
+				manager.GetClientReturns(client)
+				reconciler = cfd.NewReconciler(ctx, config, manager, &resolver, controllerutil.SetControllerReference)
+			})
+
+			It("stores the manifest as the first revision", func() {
+				_, err := reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+
+				instance := getInstance()
+				Expect(instance.Status.CurrentRevision).To(Equal(1))
+				Expect(instance.Status.Revisions).To(HaveLen(1))
+				Expect(instance.Status.Revisions[0].ManifestSHA1).To(Equal(manifestSHA1))
+
+				secret := &corev1.Secret{}
+				err = client.Get(context.Background(), types.NamespacedName{Name: secretName + "-v1", Namespace: "default"}, secret)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(secret.GetLabels()[bdc.LabelManifestRevision]).To(Equal("1"))
+				Expect(secret.GetLabels()[bdc.LabelManifestSHA1]).To(Equal(manifestSHA1))
+			})
+
+			Context("when the history limit is reached", func() {
+				BeforeEach(func() {
+					deployment.Spec.RevisionHistoryLimit = 2
+					secrets = append(secrets,
+						revision(1, "one", manifest),
+						revision(2, "two", manifest),
+					)
+				})
+
+				It("prunes the oldest revisions", func() {
+					_, err := reconciler.Reconcile(request)
+					Expect(err).NotTo(HaveOccurred())
+
+					instance := getInstance()
+					Expect(instance.Status.CurrentRevision).To(Equal(3))
+					Expect(instance.Status.Revisions).To(HaveLen(2))
+					Expect(instance.Status.Revisions[0].Revision).To(Equal(2))
+
+					err = client.Get(context.Background(), types.NamespacedName{Name: secretName + "-v1", Namespace: "default"}, &corev1.Secret{})
+					Expect(errors.IsNotFound(err)).To(BeTrue())
+				})
+			})
+
+			Context("when rolling back to an earlier revision", func() {
+				var oldManifestSHA1 string
+
+				BeforeEach(func() {
+					oldManifest := manifest
+					oldManifest.InstanceGroups[0].Instances = 3
+					var err error
+					oldManifestSHA1, err = oldManifest.SHA1()
+					Expect(err).ToNot(HaveOccurred())
+
+					secrets = append(secrets,
+						revision(1, oldManifestSHA1, oldManifest),
+						revision(2, "current", &bdm.Manifest{Name: "foo"}),
+					)
+					deployment.Spec.RollbackTo = 1
+					deployment.Annotations = map[string]string{bdc.AnnotationManifestSHA1: "current"}
+					deployment.Status.State = cfd.DeployedState
+
+					manifest = &bdm.Manifest{Name: "foo"}
+				})
+
+				It("deploys the manifest of that revision", func() {
+					_, err := reconciler.Reconcile(request)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(resolver.ResolveManifestCallCount()).To(Equal(0))
+
+					instance := getInstance()
+					Expect(instance.Status.State).To(Equal(cfd.OpsAppliedState))
+					Expect(instance.Status.ManifestSHA1).To(Equal(oldManifestSHA1))
+					Expect(instance.Status.CurrentRevision).To(Equal(3))
+					Expect(instance.Status.Revisions[2].SourceDescription).To(ContainSubstring("rollback"))
+				})
+			})
+
+			Context("when the revision to roll back to does not exist", func() {
+				BeforeEach(func() {
+					deployment.Spec.RollbackTo = 5
+				})
+
+				It("reports an error", func() {
+					_, err := reconciler.Reconcile(request)
+					Expect(err).To(HaveOccurred())
+					Expect(<-recorder.Events).To(ContainSubstring("RollbackError"))
+				})
+			})
+		})
+
+		Context("when waiting for secrets generated by ExtendedJobs", func() {
+			var (
+				client       client.Client
+				deployment   *bdc.BOSHDeployment
+				secrets      []runtime.Object
+				manifestSHA1 string
+			)
+
+			versionedSecret := func(name string, sha1 string) *corev1.Secret {
+				return &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: "default",
+						Labels: map[string]string{
+							versionedsecretstore.LabelSecretKind: versionedsecretstore.VersionSecretKind,
+							bdc.LabelDeploymentName:              "foo",
+							bdc.LabelManifestSHA1:                sha1,
+						},
+					},
+				}
+			}
+
+			BeforeEach(func() {
+				var err error
+				config.Namespace = "default"
+				manifest.Name = "foo"
+				manifestSHA1, err = manifest.SHA1()
+				Expect(err).ToNot(HaveOccurred())
+
+				deployment = &bdc.BOSHDeployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "foo",
+						Namespace:   "default",
+						Annotations: map[string]string{bdc.AnnotationManifestSHA1: manifestSHA1},
+					},
+				}
+				secrets = []runtime.Object{}
+			})
+
+			JustBeforeEach(func() {
+				client = fake.NewFakeClient(append(secrets, deployment)...)
+				manager.GetClientReturns(client)
+				reconciler = cfd.NewReconciler(ctx, config, manager, &resolver, controllerutil.SetControllerReference)
+			})
+
+			Context("when the variables are interpolated", func() {
+				var secretName string
+
+				BeforeEach(func() {
+					deployment.Status.State = cfd.VariableInterpolatedState
+					_, secretName = names.CalculateEJobOutputSecretPrefixAndName(names.DeploymentSecretTypeManifestAndVars, "foo", bdm.VarInterpolationContainerName, false)
+				})
+
+				Context("when only a version for an old manifest exists", func() {
+					BeforeEach(func() {
+						secrets = append(secrets, versionedSecret(secretName+"-v1", "old"))
+					})
+
+					It("waits without requeueing", func() {
+						result, err := reconciler.Reconcile(request)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(result).To(Equal(reconcile.Result{}))
+
+						instance := &bdc.BOSHDeployment{}
+						err = client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, instance)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(instance.Status.State).To(Equal(cfd.VariableInterpolatedState))
+					})
+				})
+
+				Context("when a version for the current manifest exists", func() {
+					BeforeEach(func() {
+						secrets = append(secrets,
+							versionedSecret(secretName+"-v1", "old"),
+							versionedSecret(secretName+"-v2", manifestSHA1),
+						)
+					})
+
+					It("starts data gathering", func() {
+						_, err := reconciler.Reconcile(request)
+						Expect(err).NotTo(HaveOccurred())
+
+						instance := &bdc.BOSHDeployment{}
+						err = client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, instance)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(instance.Status.State).To(Equal(cfd.DataGatheredState))
+					})
+				})
+			})
+
+			Context("when the data is gathered", func() {
+				BeforeEach(func() {
+					deployment.Status.State = cfd.DataGatheredState
+					_, secretName := names.CalculateEJobOutputSecretPrefixAndName(names.DeploymentSecretTypeInstanceGroupResolvedProperties, "foo", "fakepod", false)
+					secrets = append(secrets, versionedSecret(secretName+"-v1", "old"))
+				})
+
+				It("ignores resolved properties of an old manifest", func() {
+					result, err := reconciler.Reconcile(request)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result).To(Equal(reconcile.Result{}))
+
+					instance := &bdc.BOSHDeployment{}
+					err = client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, instance)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(instance.Status.State).To(Equal(cfd.DataGatheredState))
+				})
+			})
+		})
+
+		Context("when the deployment is deleted", func() {
+			var (
+				client     client.Client
+				deployment *bdc.BOSHDeployment
+				objects    []runtime.Object
+			)
+
+			controlledBy := func(name string, uid types.UID) []metav1.OwnerReference {
+				isController := true
+				return []metav1.OwnerReference{{Name: name, UID: uid, Controller: &isController}}
+			}
+
+			extendedStatefulSet := func(igName string) *essv1.ExtendedStatefulSet {
+				return &essv1.ExtendedStatefulSet{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "foo-" + igName,
+						Namespace:       "default",
+						Labels:          map[string]string{bdm.LabelInstanceGroupName: igName},
+						OwnerReferences: controlledBy("foo", "deployment-uid"),
+					},
+				}
+			}
+
+			persistentVolumeClaim := func(name string, deploymentName string) *corev1.PersistentVolumeClaim {
+				return &corev1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: "default",
+						Labels:    map[string]string{bdc.LabelDeploymentName: deploymentName},
+					},
+				}
+			}
+
+			exists := func(name string, obj runtime.Object) bool {
+				err := client.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "default"}, obj)
+				if errors.IsNotFound(err) {
+					return false
+				}
+				Expect(err).ToNot(HaveOccurred())
+				return true
+			}
+
+			getInstance := func() *bdc.BOSHDeployment {
+				instance := &bdc.BOSHDeployment{}
+				Expect(exists("foo", instance)).To(BeTrue())
+				return instance
+			}
+
+			BeforeEach(func() {
+				serial := true
+				otherpod := *manifest.InstanceGroups[0]
+				otherpod.Name = "otherpod"
+				manifest.InstanceGroups = append(manifest.InstanceGroups, &otherpod)
+				manifest.Update = &bdm.Update{Serial: &serial}
+				manifest.Name = "foo"
+
+				now := metav1.Now()
+				deployment = &bdc.BOSHDeployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:              "foo",
+						Namespace:         "default",
+						UID:               "deployment-uid",
+						DeletionTimestamp: &now,
+						Finalizers:        []string{finalizer.AnnotationFinalizer},
+					},
+					Status: bdc.BOSHDeploymentStatus{State: cfd.DeployedState},
+				}
+				objects = []runtime.Object{
+					extendedStatefulSet("fakepod"),
+					extendedStatefulSet("otherpod"),
+					persistentVolumeClaim("foo-pvc", "foo"),
+					persistentVolumeClaim("bar-pvc", "bar"),
+					&esv1.ExtendedSecret{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:            "foo-password",
+							Namespace:       "default",
+							OwnerReferences: controlledBy("foo", "deployment-uid"),
+						},
+						Spec: esv1.ExtendedSecretSpec{SecretName: "foo-password"},
+					},
+					&corev1.Secret{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:            "foo-password",
+							Namespace:       "default",
+							Labels:          map[string]string{esv1.LabelKind: esv1.GeneratedSecretKind},
+							OwnerReferences: controlledBy("foo-password", "esec-uid"),
+						},
+					},
+				}
+			})
+
+			JustBeforeEach(func() {
+				client = fake.NewFakeClient(append(objects, deployment)...)
+				manager.GetClientReturns(client)
+				reconciler = cfd.NewReconciler(ctx, config, manager, &resolver, controllerutil.SetControllerReference)
+			})
+
+			It("drains the instance groups in reverse deploy order", func() {
+				result, err := reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(Equal(reconcile.Result{RequeueAfter: 5 * time.Second}))
+
+				instance := getInstance()
+				Expect(instance.Status.State).To(Equal(cfd.DeletingState))
+				Expect(instance.Status.Teardown.Phase).To(Equal(bdc.TeardownDraining))
+				Expect(instance.Status.Teardown.Step.Number).To(Equal(1))
+				Expect(instance.Status.Teardown.Step.InstanceGroups).To(ConsistOf("otherpod"))
+				Expect(exists("foo-otherpod", &essv1.ExtendedStatefulSet{})).To(BeFalse())
+				Expect(exists("foo-fakepod", &essv1.ExtendedStatefulSet{})).To(BeTrue())
+
+				_, err = reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(getInstance().Status.Teardown.Step.InstanceGroups).To(ConsistOf("fakepod"))
+				Expect(exists("foo-fakepod", &essv1.ExtendedStatefulSet{})).To(BeFalse())
+
+				result, err = reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(Equal(reconcile.Result{}))
+				Expect(getInstance().GetFinalizers()).To(BeEmpty())
+			})
+
+			It("keeps persistent volume claims and variables by default", func() {
+				for i := 0; i < 3; i++ {
+					_, err := reconciler.Reconcile(request)
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				Expect(exists("foo-pvc", &corev1.PersistentVolumeClaim{})).To(BeTrue())
+
+				secret := &corev1.Secret{}
+				Expect(exists("foo-password", secret)).To(BeTrue())
+				Expect(secret.GetLabels()).To(HaveKeyWithValue(esv1.LabelKind, esv1.GeneratedSecretKind))
+				Expect(secret.GetOwnerReferences()).To(HaveLen(1))
+			})
+
+			Context("when the deletion policy is Delete and variables are kept", func() {
+				BeforeEach(func() {
+					deployment.Spec.DeletionPolicy = bdc.DeletionPolicyDelete
+					deployment.Spec.KeepVariables = true
+				})
+
+				It("deletes the persistent volume claims of the deployment and retains the generated secrets", func() {
+					for i := 0; i < 3; i++ {
+						_, err := reconciler.Reconcile(request)
+						Expect(err).NotTo(HaveOccurred())
+					}
+
+					Expect(exists("foo-pvc", &corev1.PersistentVolumeClaim{})).To(BeFalse())
+					Expect(exists("bar-pvc", &corev1.PersistentVolumeClaim{})).To(BeTrue())
+
+					secret := &corev1.Secret{}
+					Expect(exists("foo-password", secret)).To(BeTrue())
+					Expect(secret.GetLabels()).To(HaveKeyWithValue(esv1.LabelKind, esv1.RetainedSecretKind))
+					Expect(secret.GetOwnerReferences()).To(BeEmpty())
+				})
+			})
+		})
+
+		Context("when the instance groups are deploying", func() {
+			var (
+				client       client.Client
+				deployment   *bdc.BOSHDeployment
+				eSts         *essv1.ExtendedStatefulSet
+				templateSHA1 string
+				objects      []runtime.Object
+			)
+
+			statefulSet := func(version int, sha1 string, readyReplicas int32) *v1beta2.StatefulSet {
+				replicas := int32(2)
+				isController := true
+				return &v1beta2.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("foo-fakepod-v%d", version),
+						Namespace: "default",
+						Annotations: map[string]string{
+							essv1.AnnotationVersion:         fmt.Sprintf("%d", version),
+							essv1.AnnotationStatefulSetSHA1: sha1,
+						},
+						OwnerReferences: []metav1.OwnerReference{
+							{Name: "foo-fakepod", UID: "ests-uid", Controller: &isController},
+						},
+					},
+					Spec:   v1beta2.StatefulSetSpec{Replicas: &replicas},
+					Status: v1beta2.StatefulSetStatus{ReadyReplicas: readyReplicas},
+				}
+			}
+
+			BeforeEach(func() {
+				config.Namespace = "default"
+				manifest.Name = "foo"
+				manifestSHA1, err := manifest.SHA1()
+				Expect(err).ToNot(HaveOccurred())
+
+				since := metav1.Now()
+				deployment = &bdc.BOSHDeployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "foo",
+						Namespace:   "default",
+						Generation:  3,
+						Annotations: map[string]string{bdc.AnnotationManifestSHA1: manifestSHA1},
+					},
+					Status: bdc.BOSHDeploymentStatus{
+						State:          cfd.DeployingState,
+						DeployingSince: &since,
+					},
+				}
+				eSts = &essv1.ExtendedStatefulSet{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "foo-fakepod",
+						Namespace: "default",
+						UID:       "ests-uid",
+					},
+					Status: essv1.ExtendedStatefulSetStatus{
+						Versions: map[int]bool{1: true},
+					},
+				}
+				templateSHA1, err = eSts.CalculateStatefulSetSHA1()
+				Expect(err).ToNot(HaveOccurred())
+				objects = []runtime.Object{}
+			})
+
+			JustBeforeEach(func() {
+				client = fake.NewFakeClient(append(objects,
+					deployment,
+					eSts,
+					statefulSet(1, templateSHA1, 1),
+				)...)
+				manager.GetClientReturns(client)
+				reconciler = cfd.NewReconciler(ctx, config, manager, &resolver, controllerutil.SetControllerReference)
+			})
+
+			It("keeps deploying until all pods are ready and reports the progress", func() {
+				result, err := reconciler.Reconcile(request)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(Equal(reconcile.Result{RequeueAfter: 5 * time.Second}))
+
+				instance := &bdc.BOSHDeployment{}
+				err = client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, instance)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(instance.Status.State).To(Equal(cfd.DeployingState))
+				Expect(instance.Status.ObservedGeneration).To(Equal(int64(3)))
+				Expect(instance.Status.InstanceGroups).To(HaveKey("fakepod"))
+
+				igStatus := instance.Status.InstanceGroups["fakepod"]
+				Expect(igStatus.Version).To(Equal(1))
+				Expect(igStatus.Replicas).To(Equal(int32(2)))
+				Expect(igStatus.ReadyReplicas).To(Equal(int32(1)))
+				Expect(igStatus.Ready).To(BeFalse())
+				Expect(igStatus.Message).To(Equal("1 of 2 pods are ready"))
+
+				condition := instance.Status.GetCondition(bdc.ConditionInstanceGroupsReady)
+				Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+				Expect(condition.Message).To(Equal("waiting for fakepod"))
+			})
+
+			Context("when the desired version is not available", func() {
+				BeforeEach(func() {
+					eSts.Status.Versions = map[int]bool{1: true, 2: false}
+					objects = append(objects, statefulSet(2, templateSHA1, 0))
+					templateSHA1 = "old"
+				})
+
+				It("waits for the new version", func() {
+					_, err := reconciler.Reconcile(request)
+					Expect(err).NotTo(HaveOccurred())
+
+					instance := &bdc.BOSHDeployment{}
+					err = client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, instance)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(instance.Status.State).To(Equal(cfd.DeployingState))
+					Expect(instance.Status.InstanceGroups["fakepod"].Version).To(Equal(2))
+					Expect(instance.Status.InstanceGroups["fakepod"].Ready).To(BeFalse())
+					Expect(instance.Status.InstanceGroups["fakepod"].Message).To(Equal("waiting for version 2 to become available"))
+				})
+			})
+
+			Context("when the progress deadline has passed", func() {
+				BeforeEach(func() {
+					since := metav1.NewTime(time.Now().Add(-2 * time.Minute))
+					deployment.Spec.ProgressDeadlineSeconds = 60
+					deployment.Status.DeployingSince = &since
+				})
+
+				It("sets the failed state with a reason", func() {
+					result, err := reconciler.Reconcile(request)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result).To(Equal(reconcile.Result{}))
+
+					instance := &bdc.BOSHDeployment{}
+					err = client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, instance)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(instance.Status.State).To(Equal(cfd.FailedState))
+					Expect(instance.Status.Reason).To(ContainSubstring("fakepod"))
+					Expect(instance.Status.GetCondition(bdc.ConditionFailed).Status).To(Equal(corev1.ConditionTrue))
+
+					Expect(<-recorder.Events).To(ContainSubstring("DeploymentFailed"))
+				})
+			})
+
+			Context("when all pods are ready", func() {
+				JustBeforeEach(func() {
+					sts := &v1beta2.StatefulSet{}
+					err := client.Get(context.Background(), types.NamespacedName{Name: "foo-fakepod-v1", Namespace: "default"}, sts)
+					Expect(err).ToNot(HaveOccurred())
+					sts.Status.ReadyReplicas = 2
+					Expect(client.Update(context.Background(), sts)).To(Succeed())
+				})
+
+				It("sets the deployed state", func() {
+					_, err := reconciler.Reconcile(request)
+					Expect(err).NotTo(HaveOccurred())
+
+					instance := &bdc.BOSHDeployment{}
+					err = client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, instance)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(instance.Status.State).To(Equal(cfd.DeployedState))
+					Expect(instance.Status.InstanceGroups["fakepod"].Ready).To(BeTrue())
+					Expect(instance.Status.GetCondition(bdc.ConditionInstanceGroupsReady).Status).To(Equal(corev1.ConditionTrue))
+				})
+
+				Context("when the deployment has an auto-errand", func() {
+					var eJob *ejv1.ExtendedJob
+
+					getInstance := func() *bdc.BOSHDeployment {
+						instance := &bdc.BOSHDeployment{}
+						err := client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, instance)
+						Expect(err).ToNot(HaveOccurred())
+						return instance
+					}
+
+					BeforeEach(func() {
+						migrate := *manifest.InstanceGroups[0]
+						migrate.Name = "migrate"
+						migrate.LifeCycle = "auto-errand"
+						manifest.InstanceGroups = append(manifest.InstanceGroups, &migrate)
+
+						manifestSHA1, err := manifest.SHA1()
+						Expect(err).ToNot(HaveOccurred())
+						deployment.Annotations[bdc.AnnotationManifestSHA1] = manifestSHA1
+
+						eJob = &ejv1.ExtendedJob{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:        "foo-migrate",
+								Namespace:   "default",
+								Annotations: map[string]string{ejv1.AnnotationRunID: "current"},
+							},
+							Spec: ejv1.ExtendedJobSpec{Trigger: ejv1.Trigger{Strategy: ejv1.TriggerDone}},
+							Status: ejv1.ExtendedJobStatus{
+								Runs: []ejv1.Run{{JobName: "foo-migrate-old", Result: ejv1.RunSucceeded, RunID: "previous"}},
+							},
+						}
+						objects = append(objects, eJob)
+					})
+
+					It("waits for the run of the current deployment", func() {
+						_, err := reconciler.Reconcile(request)
+						Expect(err).NotTo(HaveOccurred())
+
+						instance := getInstance()
+						Expect(instance.Status.State).To(Equal(cfd.DeployingState))
+						Expect(instance.Status.InstanceGroups["migrate"].Ready).To(BeFalse())
+						Expect(instance.Status.InstanceGroups["migrate"].Message).To(Equal("waiting for the errand to finish"))
+					})
+
+					Context("when the run of the current deployment failed", func() {
+						BeforeEach(func() {
+							eJob.Status.Runs = append([]ejv1.Run{{JobName: "foo-migrate-new", Result: ejv1.RunFailed, RunID: "current"}}, eJob.Status.Runs...)
+						})
+
+						It("reports the failed job", func() {
+							_, err := reconciler.Reconcile(request)
+							Expect(err).NotTo(HaveOccurred())
+
+							instance := getInstance()
+							Expect(instance.Status.State).To(Equal(cfd.DeployingState))
+							Expect(instance.Status.InstanceGroups["migrate"].Message).To(Equal("job 'foo-migrate-new' failed"))
+						})
+					})
+
+					Context("when the run of the current deployment succeeded", func() {
+						BeforeEach(func() {
+							eJob.Status.Runs = append([]ejv1.Run{{JobName: "foo-migrate-new", Result: ejv1.RunSucceeded, RunID: "current"}}, eJob.Status.Runs...)
+						})
+
+						It("sets the deployed state", func() {
+							_, err := reconciler.Reconcile(request)
+							Expect(err).NotTo(HaveOccurred())
+
+							instance := getInstance()
+							Expect(instance.Status.State).To(Equal(cfd.DeployedState))
+							Expect(instance.Status.InstanceGroups["migrate"].Ready).To(BeTrue())
+						})
+					})
+				})
+
+				Context("when an existing ExtendedStatefulSet was updated", func() {
+					BeforeEach(func() {
+						eSts.Spec.Template.Spec.Template.Spec.Containers = []corev1.Container{{Name: "fakepod", Image: "new"}}
+					})
+
+					It("waits until the ExtendedStatefulSet controller creates a version for the applied spec", func() {
+						_, err := reconciler.Reconcile(request)
+						Expect(err).NotTo(HaveOccurred())
+
+						instance := &bdc.BOSHDeployment{}
+						err = client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, instance)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(instance.Status.State).To(Equal(cfd.DeployingState))
+						Expect(instance.Status.InstanceGroups["fakepod"].Ready).To(BeFalse())
+						Expect(instance.Status.InstanceGroups["fakepod"].Message).To(Equal("StatefulSets of the applied spec do not exist yet"))
+					})
+				})
+
+				Context("when the instance groups are deployed serially", func() {
+					BeforeEach(func() {
+						serial := true
+						otherpod := *manifest.InstanceGroups[0]
+						otherpod.Name = "otherpod"
+						manifest.InstanceGroups = append(manifest.InstanceGroups, &otherpod)
+						manifest.Update = &bdm.Update{Serial: &serial}
+
+						manifestSHA1, err := manifest.SHA1()
+						Expect(err).ToNot(HaveOccurred())
+						deployment.Annotations[bdc.AnnotationManifestSHA1] = manifestSHA1
+						deployment.Status.CurrentStep = &bdc.DeploymentStep{Number: 1, Total: 2, InstanceGroups: []string{"fakepod"}}
+
+						for _, igName := range []string{"fakepod", "otherpod"} {
+							resolvedProperties, err := yaml.Marshal(&bdm.Manifest{
+								InstanceGroups: []*bdm.InstanceGroup{
+									{
+										Name: igName,
+										Jobs: []bdm.Job{
+											{
+												Name: "foo",
+												Properties: bdm.JobProperties{
+													BOSHContainerization: bdm.BOSHContainerization{
+														Instances: []bdm.JobInstance{{Name: igName}},
+														BPM:       bpm.Config{Processes: []bpm.Process{{Name: "foo", Executable: "/bin/foo"}}},
+													},
+												},
+											},
+										},
+									},
+								},
+							})
+							Expect(err).ToNot(HaveOccurred())
+
+							_, secretName := names.CalculateEJobOutputSecretPrefixAndName(names.DeploymentSecretTypeInstanceGroupResolvedProperties, "foo", igName, false)
+							objects = append(objects, &corev1.Secret{
+								ObjectMeta: metav1.ObjectMeta{
+									Name:      secretName + "-v1",
+									Namespace: "default",
+									Labels: map[string]string{
+										versionedsecretstore.LabelSecretKind: versionedsecretstore.VersionSecretKind,
+										bdc.LabelDeploymentName:              "foo",
+										bdc.LabelManifestSHA1:                manifestSHA1,
+									},
+								},
+								Data: map[string][]byte{"properties.yaml": resolvedProperties},
+							})
+						}
+					})
+
+					It("deploys the next step", func() {
+						result, err := reconciler.Reconcile(request)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(result).To(Equal(reconcile.Result{RequeueAfter: 5 * time.Second}))
+
+						instance := &bdc.BOSHDeployment{}
+						err = client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, instance)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(instance.Status.State).To(Equal(cfd.DeployingState))
+						Expect(instance.Status.CurrentStep.Number).To(Equal(2))
+						Expect(instance.Status.CurrentStep.InstanceGroups).To(ConsistOf("otherpod"))
+						Expect(instance.Status.GetCondition(bdc.ConditionInstanceGroupsReady).Message).To(Equal("waiting for otherpod"))
+
+						otherEsts := &essv1.ExtendedStatefulSet{}
+						err = client.Get(context.Background(), types.NamespacedName{Name: "foo-otherpod", Namespace: "default"}, otherEsts)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(otherEsts.Spec.Template.Spec.Template.Spec.Containers[0].Command).To(Equal([]string{"/bin/foo"}))
+					})
+
+					Context("when the next step is an auto-errand", func() {
+						BeforeEach(func() {
+							manifest.InstanceGroups[1].LifeCycle = "auto-errand"
+
+							manifestSHA1, err := manifest.SHA1()
+							Expect(err).ToNot(HaveOccurred())
+							deployment.Annotations[bdc.AnnotationManifestSHA1] = manifestSHA1
+							for _, object := range objects {
+								if secret, ok := object.(*corev1.Secret); ok {
+									secret.Labels[bdc.LabelManifestSHA1] = manifestSHA1
+								}
+							}
+						})
+
+						It("triggers it with a new run ID", func() {
+							_, err := reconciler.Reconcile(request)
+							Expect(err).NotTo(HaveOccurred())
+
+							eJob := &ejv1.ExtendedJob{}
+							err = client.Get(context.Background(), types.NamespacedName{Name: "foo-otherpod", Namespace: "default"}, eJob)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(eJob.Spec.Trigger.Strategy).To(Equal(ejv1.TriggerOnce))
+							Expect(eJob.GetAnnotations()[ejv1.AnnotationRunID]).ToNot(BeEmpty())
+						})
+					})
+
+					Context("when only an older version of the first step is ready", func() {
+						BeforeEach(func() {
+							eSts.Spec.Template.Spec.Template.Spec.Containers = []corev1.Container{{Name: "fakepod", Image: "new"}}
+						})
+
+						It("does not deploy the next step", func() {
+							result, err := reconciler.Reconcile(request)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(result).To(Equal(reconcile.Result{RequeueAfter: 5 * time.Second}))
+
+							instance := &bdc.BOSHDeployment{}
+							err = client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, instance)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(instance.Status.CurrentStep.Number).To(Equal(1))
+							Expect(instance.Status.GetCondition(bdc.ConditionInstanceGroupsReady).Message).To(Equal("waiting for fakepod"))
+
+							err = client.Get(context.Background(), types.NamespacedName{Name: "foo-otherpod", Namespace: "default"}, &essv1.ExtendedStatefulSet{})
+							Expect(errors.IsNotFound(err)).To(BeTrue())
+						})
+					})
+				})
 			})
 		})
 	})
@@ -0,0 +1,215 @@
+package boshdeployment
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	bdm "code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	esv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedsecret/v1alpha1"
+	estsv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedstatefulset/v1alpha1"
+	log "code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
+)
+
+// teardown drains and deletes the instance groups in reverse deploy order. Afterwards persistent
+// volume claims are deleted and generated variables are retained, as configured in the spec.
+// It returns false while instance groups are still being drained.
+func (r *ReconcileBOSHDeployment) teardown(ctx context.Context, instance *bdv1.BOSHDeployment) (bool, error) {
+	instance.Status.State = DeletingState
+
+	deploymentName := instance.GetName()
+	steps := [][]string{}
+	manifest := r.teardownManifest(ctx, instance)
+	if manifest != nil {
+		deploymentName = manifest.Name
+		steps = manifest.DeploymentSteps()
+	}
+
+	eStsList := &estsv1.ExtendedStatefulSetList{}
+	err := r.client.List(ctx, &client.ListOptions{Namespace: instance.GetNamespace(), LabelSelector: labels.Everything()}, eStsList)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to list ExtendedStatefulSets")
+	}
+
+	owned := map[string]*estsv1.ExtendedStatefulSet{}
+	for i := range eStsList.Items {
+		if metav1.IsControlledBy(&eStsList.Items[i], instance) {
+			owned[instanceGroupName(&eStsList.Items[i])] = &eStsList.Items[i]
+		}
+	}
+
+	drain := drainSteps(steps, owned)
+	for i, step := range drain {
+		remaining := []string{}
+		for _, igName := range step {
+			eSts, ok := owned[igName]
+			if !ok {
+				continue
+			}
+			remaining = append(remaining, igName)
+
+			if !eSts.GetDeletionTimestamp().IsZero() {
+				continue
+			}
+
+			// Foreground deletion keeps the ExtendedStatefulSet until its pods are drained and gone
+			log.Infof(ctx, "Draining instance group '%s' of BOSHDeployment '%s/%s'", igName, instance.GetNamespace(), instance.GetName())
+			err := r.client.Delete(ctx, eSts, client.PropagationPolicy(metav1.DeletePropagationForeground))
+			if err != nil && !apierrors.IsNotFound(err) {
+				return false, errors.Wrapf(err, "failed to delete ExtendedStatefulSet '%s'", eSts.GetName())
+			}
+		}
+
+		if len(remaining) > 0 {
+			instance.Status.Teardown = &bdv1.TeardownStatus{
+				Phase: bdv1.TeardownDraining,
+				Step: &bdv1.DeploymentStep{
+					Number:         i + 1,
+					Total:          len(drain),
+					InstanceGroups: step,
+				},
+				Message: "waiting for " + strings.Join(remaining, ", ") + " to be drained",
+			}
+			return false, nil
+		}
+	}
+
+	if instance.Spec.DeletionPolicy == bdv1.DeletionPolicyDelete {
+		instance.Status.Teardown = &bdv1.TeardownStatus{Phase: bdv1.TeardownDeletingVolumes}
+		err := r.deletePersistentVolumeClaims(ctx, instance.GetNamespace(), deploymentName)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if instance.Spec.KeepVariables {
+		instance.Status.Teardown = &bdv1.TeardownStatus{Phase: bdv1.TeardownRetainingVariables}
+		err := r.retainVariables(ctx, instance)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// teardownManifest returns the manifest which was deployed last, to know the deploy order
+// and the deployment name. It returns nil if the manifest is no longer available.
+func (r *ReconcileBOSHDeployment) teardownManifest(ctx context.Context, instance *bdv1.BOSHDeployment) *bdm.Manifest {
+	if instance.Status.CurrentRevision > 0 {
+		manifest, err := r.manifestRevision(ctx, instance, instance.Status.CurrentRevision)
+		if err == nil {
+			return manifest
+		}
+		log.Debugf(ctx, "Failed to load revision %d of BOSHDeployment '%s/%s': %v", instance.Status.CurrentRevision, instance.GetNamespace(), instance.GetName(), err)
+	}
+
+	manifest, err := r.resolver.ResolveManifest(instance, instance.GetNamespace())
+	if err != nil {
+		log.Infof(ctx, "Manifest of BOSHDeployment '%s/%s' is not available, instance groups are drained in parallel: %v", instance.GetNamespace(), instance.GetName(), err)
+		return nil
+	}
+
+	return manifest
+}
+
+// drainSteps reverses the deployment steps. Instance groups which are not part of the
+// manifest are drained first.
+func drainSteps(steps [][]string, owned map[string]*estsv1.ExtendedStatefulSet) [][]string {
+	known := map[string]bool{}
+	for _, step := range steps {
+		for _, igName := range step {
+			known[igName] = true
+		}
+	}
+
+	unknown := []string{}
+	for igName := range owned {
+		if !known[igName] {
+			unknown = append(unknown, igName)
+		}
+	}
+	sort.Strings(unknown)
+
+	result := [][]string{}
+	if len(unknown) > 0 {
+		result = append(result, unknown)
+	}
+	for i := len(steps) - 1; i >= 0; i-- {
+		result = append(result, steps[i])
+	}
+
+	return result
+}
+
+// deletePersistentVolumeClaims deletes the persistent volume claims of all instance groups
+func (r *ReconcileBOSHDeployment) deletePersistentVolumeClaims(ctx context.Context, namespace string, deploymentName string) error {
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	err := r.client.List(ctx, &client.ListOptions{Namespace: namespace, LabelSelector: labels.Everything()}, pvcs)
+	if err != nil {
+		return errors.Wrap(err, "failed to list PersistentVolumeClaims")
+	}
+
+	for i := range pvcs.Items {
+		// The StatefulSet controller copies the selector labels of the instance group to its claims
+		if pvcs.Items[i].GetLabels()[bdv1.LabelDeploymentName] != deploymentName {
+			continue
+		}
+
+		log.Infof(ctx, "Deleting PersistentVolumeClaim '%s/%s'", namespace, pvcs.Items[i].GetName())
+		err := r.client.Delete(ctx, &pvcs.Items[i])
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete PersistentVolumeClaim '%s'", pvcs.Items[i].GetName())
+		}
+	}
+
+	return nil
+}
+
+// retainVariables keeps the secrets generated for the deployment's variables from being garbage
+// collected. The ExtendedSecrets of a new deployment adopt them instead of generating new values.
+func (r *ReconcileBOSHDeployment) retainVariables(ctx context.Context, instance *bdv1.BOSHDeployment) error {
+	eSecrets := &esv1.ExtendedSecretList{}
+	err := r.client.List(ctx, &client.ListOptions{Namespace: instance.GetNamespace(), LabelSelector: labels.Everything()}, eSecrets)
+	if err != nil {
+		return errors.Wrap(err, "failed to list ExtendedSecrets")
+	}
+
+	for _, eSecret := range eSecrets.Items {
+		if !metav1.IsControlledBy(&eSecret, instance) {
+			continue
+		}
+
+		secret := &corev1.Secret{}
+		err := r.client.Get(ctx, types.NamespacedName{Namespace: eSecret.GetNamespace(), Name: eSecret.Spec.SecretName}, secret)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return errors.Wrapf(err, "failed to get secret '%s'", eSecret.Spec.SecretName)
+		}
+
+		if secret.GetLabels()[esv1.LabelKind] != esv1.GeneratedSecretKind {
+			continue
+		}
+
+		log.Debugf(ctx, "Retaining secret '%s/%s'", secret.GetNamespace(), secret.GetName())
+		secret.Labels[esv1.LabelKind] = esv1.RetainedSecretKind
+		secret.OwnerReferences = nil
+		err = r.client.Update(ctx, secret)
+		if err != nil {
+			return errors.Wrapf(err, "failed to retain secret '%s'", secret.GetName())
+		}
+	}
+
+	return nil
+}
@@ -6,10 +6,7 @@ import (
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	machinerytypes "k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -26,12 +23,15 @@ import (
 	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/extendedstatefulset"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/nslabel"
 )
 
 var addToManagerFuncs = []func(context.Context, *config.Config, manager.Manager) error{
 	boshdeployment.AddDeployment,
 	extendedjob.AddTrigger,
 	extendedjob.AddErrand,
+	extendedjob.AddSchedule,
+	extendedjob.AddConfigTrigger,
 	extendedjob.AddJob,
 	extendedjob.AddOwnership,
 	extendedsecret.Add,
@@ -47,15 +47,25 @@ var addToSchemes = runtime.SchemeBuilder{
 
 var addHookFuncs = []func(*zap.SugaredLogger, *config.Config, manager.Manager, *webhook.Server) (*admission.Webhook, error){
 	extendedstatefulset.AddPod,
+	extendedstatefulset.AddValidator,
+	extendedjob.AddValidator,
+	extendedsecret.AddValidator,
 }
 
-// AddToManager adds all Controllers to the Manager
+// AddToManager adds all Controllers and the pruner of versioned secrets to the Manager
 func AddToManager(ctx context.Context, config *config.Config, m manager.Manager) error {
 	for _, f := range addToManagerFuncs {
 		if err := f(ctx, config, m); err != nil {
 			return err
 		}
 	}
+
+	if config.VersionedSecretRetention > 0 {
+		ctxlog.Infof(ctx, "Pruning versioned secrets every %s, keeping %d versions", config.VersionedSecretPruneInterval, config.VersionedSecretRetention)
+		if err := m.Add(newVersionedSecretPruner(ctx, config, m.GetClient())); err != nil {
+			return errors.Wrap(err, "adding the versioned secret pruner")
+		}
+	}
 	return nil
 }
 
@@ -66,21 +76,26 @@ func AddToScheme(s *runtime.Scheme) error {
 
 // AddHooks adds all web hooks to the Manager
 func AddHooks(ctx context.Context, config *config.Config, m manager.Manager, generator credsgen.Generator) error {
-	ctxlog.Infof(ctx, "Setting up webhook server on %s:%d", config.WebhookServerHost, config.WebhookServerPort)
+	bootstrapOptions := &webhook.BootstrapOptions{}
+	if config.WebhookServiceName != "" {
+		ctxlog.Infof(ctx, "Setting up webhook server on port %d behind service %s/%s", config.WebhookServerPort, config.Namespace, config.WebhookServiceName)
+		bootstrapOptions.Service = &webhook.Service{Name: config.WebhookServiceName, Namespace: config.Namespace}
+	} else {
+		ctxlog.Infof(ctx, "Setting up webhook server on %s:%d", config.WebhookServerHost, config.WebhookServerPort)
+		bootstrapOptions.Host = &config.WebhookServerHost
+	}
 
-	webhookConfig := NewWebhookConfig(m.GetClient(), config, generator, "cf-operator-mutating-hook-"+config.Namespace)
+	webhookConfig := NewWebhookConfig(m.GetClient(), config, generator, "cf-operator-mutating-hook-"+config.Namespace, "cf-operator-validating-hook-"+config.Namespace)
+	bootstrapOptions.MutatingWebhookConfigName = webhookConfig.MutatingConfigName
+	bootstrapOptions.ValidatingWebhookConfigName = webhookConfig.ValidatingConfigName
 
+	// The webhook server only collects the webhooks, they are served by webhookServer
 	disableConfigInstaller := true
-	hookServer, err := webhook.NewServer("cf-operator", m, webhook.ServerOptions{
+	hookServer, err := webhook.NewServer("cf-operator", &webhookManager{Manager: m}, webhook.ServerOptions{
 		Port:                          config.WebhookServerPort,
 		CertDir:                       webhookConfig.CertDir,
 		DisableWebhookConfigInstaller: &disableConfigInstaller,
-		BootstrapOptions: &webhook.BootstrapOptions{
-			MutatingWebhookConfigName: webhookConfig.ConfigName,
-			Host:                      &config.WebhookServerHost,
-			// The user should probably be able to use a service instead.
-			// Service: ??
-		},
+		BootstrapOptions:              bootstrapOptions,
 	})
 
 	if err != nil {
@@ -111,32 +126,30 @@ func AddHooks(ctx context.Context, config *config.Config, m manager.Manager, gen
 		return errors.Wrap(err, "generating the webhook server configuration")
 	}
 
-	return err
-}
-
-func setOperatorNamespaceLabel(ctx context.Context, config *config.Config, c client.Client) error {
-	ns := &unstructured.Unstructured{}
-	ns.SetGroupVersionKind(schema.GroupVersionKind{
-		Group:   "",
-		Kind:    "Namespace",
-		Version: "v1",
-	})
-	err := c.Get(ctx, machinerytypes.NamespacedName{Name: config.Namespace}, ns)
-
+	err = m.Add(newWebhookServer(ctx, config.WebhookServerPort, webhookConfig, webhooks))
 	if err != nil {
-		return errors.Wrap(err, "getting the namespace object")
+		return errors.Wrap(err, "adding the webhook server")
 	}
 
-	labels := ns.GetLabels()
-	if labels == nil {
-		labels = map[string]string{}
-	}
-	labels["cf-operator-ns"] = config.Namespace
-	ns.SetLabels(labels)
-	err = c.Update(ctx, ns)
+	return nil
+}
 
-	if err != nil {
-		return errors.Wrap(err, "updating the namespace object")
+// setOperatorNamespaceLabel labels the operator namespace and all watched namespaces, so the
+// webhooks apply to them. If all namespaces are watched, the reconcilers label the namespaces
+// of the resources they handle.
+func setOperatorNamespaceLabel(ctx context.Context, config *config.Config, c client.Client) error {
+	namespaces := append([]string{config.Namespace}, config.WatchedNamespaces()...)
+	labeled := map[string]bool{}
+	for _, namespace := range namespaces {
+		if labeled[namespace] {
+			continue
+		}
+		labeled[namespace] = true
+
+		err := nslabel.Set(ctx, c, namespace, config.Namespace)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
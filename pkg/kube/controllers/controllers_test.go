@@ -3,22 +3,28 @@ package controllers_test
 import (
 	"context"
 	"encoding/base64"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/spf13/afero"
+	"go.uber.org/zap"
 
 	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
 	gfakes "code.cloudfoundry.org/cf-operator/pkg/credsgen/fakes"
+	inmemorygenerator "code.cloudfoundry.org/cf-operator/pkg/credsgen/in_memory_generator"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
 	cfakes "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/fakes"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
@@ -57,6 +63,9 @@ var _ = Describe("Controllers", func() {
 			client = &cfakes.FakeClient{}
 			restMapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{})
 			restMapper.Add(schema.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}, meta.RESTScopeNamespace)
+			restMapper.Add(schema.GroupVersionKind{Group: "fissile.cloudfoundry.org", Kind: "ExtendedStatefulSet", Version: "v1alpha1"}, meta.RESTScopeNamespace)
+			restMapper.Add(schema.GroupVersionKind{Group: "fissile.cloudfoundry.org", Kind: "ExtendedJob", Version: "v1alpha1"}, meta.RESTScopeNamespace)
+			restMapper.Add(schema.GroupVersionKind{Group: "fissile.cloudfoundry.org", Kind: "ExtendedSecret", Version: "v1alpha1"}, meta.RESTScopeNamespace)
 
 			manager = &cfakes.FakeManager{}
 			manager.GetSchemeReturns(scheme.Scheme)
@@ -92,6 +101,92 @@ var _ = Describe("Controllers", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("labels all watched namespaces", func() {
+			config.WatchNamespaces = []string{"cf-1", "cf-2"}
+
+			labeled := []string{}
+			client.UpdateCalls(func(_ context.Context, object runtime.Object) error {
+				ns := object.(*unstructured.Unstructured)
+				Expect(ns.GetLabels()["cf-operator-ns"]).To(Equal(config.Namespace))
+				labeled = append(labeled, ns.GetName())
+				return nil
+			})
+
+			client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+				switch object := object.(type) {
+				case *unstructured.Unstructured:
+					if object.GetKind() == "Namespace" {
+						object.SetName(nn.Name)
+						return nil
+					}
+				}
+				return apierrors.NewNotFound(schema.GroupResource{}, nn.Name)
+			})
+
+			err := controllers.AddHooks(ctx, config, manager, generator)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(labeled).To(ConsistOf("default", "cf-1", "cf-2"))
+		})
+
+		It("adds a single webhook server to the manager", func() {
+			client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+				kind := object.GetObjectKind()
+				if kind.GroupVersionKind().Kind == "Secret" {
+					return apierrors.NewNotFound(schema.GroupResource{}, nn.Name)
+				}
+				return nil
+			})
+
+			err := controllers.AddHooks(ctx, config, manager, generator)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(manager.AddCallCount()).To(Equal(1))
+		})
+
+		Context("if a webhook service is configured", func() {
+			BeforeEach(func() {
+				config.WebhookServiceName = "cf-operator-webhook"
+				client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+					kind := object.GetObjectKind()
+					if kind.GroupVersionKind().Kind == "Secret" {
+						return apierrors.NewNotFound(schema.GroupResource{}, nn.Name)
+					}
+					return nil
+				})
+			})
+
+			It("generates the certificate for the service's DNS name", func() {
+				err := controllers.AddHooks(ctx, config, manager, generator)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, request := generator.GenerateCertificateArgsForCall(1)
+				Expect(request.CommonName).To(Equal("cf-operator-webhook.default.svc"))
+				Expect(request.AlternativeNames).To(ConsistOf("cf-operator-webhook", "cf-operator-webhook.default"))
+			})
+
+			It("registers the webhooks through the service", func() {
+				client.CreateCalls(func(context context.Context, object runtime.Object) error {
+					webhooks := []admissionregistrationv1beta1.Webhook{}
+					switch config := object.(type) {
+					case *admissionregistrationv1beta1.MutatingWebhookConfiguration:
+						webhooks = config.Webhooks
+					case *admissionregistrationv1beta1.ValidatingWebhookConfiguration:
+						webhooks = config.Webhooks
+					}
+					for _, wh := range webhooks {
+						Expect(wh.ClientConfig.URL).To(BeNil())
+						Expect(wh.ClientConfig.Service.Namespace).To(Equal("default"))
+						Expect(wh.ClientConfig.Service.Name).To(Equal("cf-operator-webhook"))
+						Expect(*wh.ClientConfig.Service.Path).To(HavePrefix("/"))
+					}
+					return nil
+				})
+
+				err := controllers.AddHooks(ctx, config, manager, generator)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.CreateCallCount()).To(Equal(3))
+			})
+		})
+
 		Context("if there is no cert secret yet", func() {
 			It("generates and persists the certificates on disk and in a secret", func() {
 				Expect(afero.Exists(config.Fs, "/tmp/cf-operator-certs/key.pem")).To(BeFalse())
@@ -109,7 +204,44 @@ var _ = Describe("Controllers", func() {
 
 				Expect(afero.Exists(config.Fs, "/tmp/cf-operator-certs/key.pem")).To(BeTrue())
 				Expect(generator.GenerateCertificateCallCount()).To(Equal(2)) // Generate CA and certificate
-				Expect(client.CreateCallCount()).To(Equal(2))                 // Persist secret and the webhook config
+				Expect(client.CreateCallCount()).To(Equal(3))                 // Persist secret and the webhook configs
+			})
+		})
+
+		Context("if another replica persists the cert secret at the same time", func() {
+			It("uses the certificate of the other replica", func() {
+				created := false
+				client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+					u, ok := object.(*unstructured.Unstructured)
+					if !ok || u.GetKind() != "Secret" {
+						return nil
+					}
+					if !created {
+						return apierrors.NewNotFound(schema.GroupResource{}, nn.Name)
+					}
+					u.Object["data"] = map[string]interface{}{
+						"certificate":    base64.StdEncoding.EncodeToString([]byte("the-cert")),
+						"private_key":    base64.StdEncoding.EncodeToString([]byte("the-key")),
+						"ca_certificate": base64.StdEncoding.EncodeToString([]byte("the-ca-cert")),
+						"ca_private_key": base64.StdEncoding.EncodeToString([]byte("the-ca-key")),
+					}
+					return nil
+				})
+				client.CreateCalls(func(context context.Context, object runtime.Object) error {
+					switch object.(type) {
+					case *corev1.Secret:
+						created = true
+						return apierrors.NewAlreadyExists(schema.GroupResource{}, "cf-operator-webhook-server-cert")
+					}
+					return apierrors.NewAlreadyExists(schema.GroupResource{}, "webhook-config")
+				})
+
+				err := controllers.AddHooks(ctx, config, manager, generator)
+				Expect(err).ToNot(HaveOccurred())
+
+				cert, err := afero.ReadFile(config.Fs, "/tmp/cf-operator-certs/ca-cert.pem")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(cert)).To(Equal("the-ca-cert"))
 			})
 		})
 
@@ -142,20 +274,39 @@ var _ = Describe("Controllers", func() {
 			It("does not overwrite the existing secret", func() {
 				err := controllers.AddHooks(ctx, config, manager, generator)
 				Expect(err).ToNot(HaveOccurred())
-				Expect(client.CreateCallCount()).To(Equal(1)) // webhook config
+				Expect(client.CreateCallCount()).To(Equal(2)) // mutating and validating webhook config
 			})
 
 			It("generates the webhook configuration", func() {
 				client.CreateCalls(func(context context.Context, object runtime.Object) error {
-					config := object.(*admissionregistrationv1beta1.MutatingWebhookConfiguration)
-					Expect(config.Name).To(Equal("cf-operator-mutating-hook-" + config.Namespace))
-					Expect(len(config.Webhooks)).To(Equal(1))
-
-					wh := config.Webhooks[0]
-					Expect(wh.Name).To(Equal("mutatepods.example.com"))
-					Expect(*wh.ClientConfig.URL).To(Equal("https://foo.com:1234/mutate-pods"))
-					Expect(wh.ClientConfig.CABundle).To(ContainSubstring("the-ca-cert"))
-					Expect(*wh.FailurePolicy).To(Equal(admissionregistrationv1beta1.Fail))
+					switch config := object.(type) {
+					case *admissionregistrationv1beta1.MutatingWebhookConfiguration:
+						Expect(config.Name).To(Equal("cf-operator-mutating-hook-" + config.Namespace))
+						Expect(len(config.Webhooks)).To(Equal(1))
+
+						wh := config.Webhooks[0]
+						Expect(wh.Name).To(Equal("mutatepods.example.com"))
+						Expect(*wh.ClientConfig.URL).To(Equal("https://foo.com:1234/mutate-pods"))
+						Expect(wh.ClientConfig.CABundle).To(ContainSubstring("the-ca-cert"))
+						Expect(*wh.FailurePolicy).To(Equal(admissionregistrationv1beta1.Fail))
+					case *admissionregistrationv1beta1.ValidatingWebhookConfiguration:
+						Expect(config.Name).To(Equal("cf-operator-validating-hook-" + config.Namespace))
+						Expect(len(config.Webhooks)).To(Equal(3))
+
+						urls := []string{}
+						for _, wh := range config.Webhooks {
+							urls = append(urls, *wh.ClientConfig.URL)
+							Expect(wh.ClientConfig.CABundle).To(ContainSubstring("the-ca-cert"))
+							Expect(*wh.FailurePolicy).To(Equal(admissionregistrationv1beta1.Fail))
+						}
+						Expect(urls).To(ConsistOf(
+							"https://foo.com:1234/validate-extendedstatefulsets",
+							"https://foo.com:1234/validate-extendedjobs",
+							"https://foo.com:1234/validate-extendedsecrets",
+						))
+					default:
+						Fail("unexpected object created")
+					}
 					return nil
 				})
 				err := controllers.AddHooks(ctx, config, manager, generator)
@@ -163,4 +314,183 @@ var _ = Describe("Controllers", func() {
 			})
 		})
 	})
+
+	Describe("WebhookConfig", func() {
+		var (
+			client         *cfakes.FakeClient
+			ctx            context.Context
+			config         *config.Config
+			generator      *inmemorygenerator.InMemoryGenerator
+			webhookConfig  *controllers.WebhookConfig
+			secretData     map[string]interface{}
+			webhookConfigs map[string]runtime.Object
+			env            testing.Catalog
+		)
+
+		decode := func(value interface{}) string {
+			decoded, err := base64.StdEncoding.DecodeString(value.(string))
+			Expect(err).ToNot(HaveOccurred())
+			return string(decoded)
+		}
+
+		caBundles := func() []string {
+			bundles := []string{}
+			for _, config := range webhookConfigs {
+				switch c := config.(type) {
+				case *admissionregistrationv1beta1.MutatingWebhookConfiguration:
+					for _, wh := range c.Webhooks {
+						bundles = append(bundles, string(wh.ClientConfig.CABundle))
+					}
+				case *admissionregistrationv1beta1.ValidatingWebhookConfiguration:
+					for _, wh := range c.Webhooks {
+						bundles = append(bundles, string(wh.ClientConfig.CABundle))
+					}
+				}
+			}
+			return bundles
+		}
+
+		generateSecretData := func(expiry int) map[string]interface{} {
+			generator.Expiry = expiry
+			ca, err := generator.GenerateCertificate("ca", credsgen.CertificateGenerationRequest{CommonName: "ca", IsCA: true})
+			Expect(err).ToNot(HaveOccurred())
+			cert, err := generator.GenerateCertificate("cert", credsgen.CertificateGenerationRequest{CommonName: "foo.com", CA: ca})
+			Expect(err).ToNot(HaveOccurred())
+			generator.Expiry = 365
+
+			return map[string]interface{}{
+				"certificate":    base64.StdEncoding.EncodeToString(cert.Certificate),
+				"private_key":    base64.StdEncoding.EncodeToString(cert.PrivateKey),
+				"ca_certificate": base64.StdEncoding.EncodeToString(ca.Certificate),
+				"ca_private_key": base64.StdEncoding.EncodeToString(ca.PrivateKey),
+			}
+		}
+
+		BeforeEach(func() {
+			webhookConfigs = map[string]runtime.Object{
+				"MutatingWebhookConfiguration": &admissionregistrationv1beta1.MutatingWebhookConfiguration{
+					ObjectMeta: metav1.ObjectMeta{Name: "mutating"},
+					Webhooks:   []admissionregistrationv1beta1.Webhook{{Name: "mutate.example.com"}},
+				},
+				"ValidatingWebhookConfiguration": &admissionregistrationv1beta1.ValidatingWebhookConfiguration{
+					ObjectMeta: metav1.ObjectMeta{Name: "validating"},
+					Webhooks:   []admissionregistrationv1beta1.Webhook{{Name: "validate.example.com"}},
+				},
+			}
+
+			client = &cfakes.FakeClient{}
+			client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
+				u := object.(*unstructured.Unstructured)
+				if config, ok := webhookConfigs[u.GetKind()]; ok {
+					content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(config)
+					Expect(err).ToNot(HaveOccurred())
+					u.Object = content
+					return nil
+				}
+				u.Object["data"] = secretData
+				return nil
+			})
+			client.UpdateCalls(func(context context.Context, object runtime.Object) error {
+				switch config := object.(type) {
+				case *admissionregistrationv1beta1.MutatingWebhookConfiguration:
+					webhookConfigs["MutatingWebhookConfiguration"] = config
+				case *admissionregistrationv1beta1.ValidatingWebhookConfiguration:
+					webhookConfigs["ValidatingWebhookConfiguration"] = config
+				default:
+					secretData = object.(*unstructured.Unstructured).Object["data"].(map[string]interface{})
+				}
+				return nil
+			})
+			client.CreateCalls(func(context context.Context, object runtime.Object) error {
+				Fail("webhook configurations must be updated in place")
+				return nil
+			})
+			client.DeleteCalls(func(context context.Context, object runtime.Object, opts ...crc.DeleteOptionFunc) error {
+				Fail("webhook configurations must be updated in place")
+				return nil
+			})
+
+			config = env.DefaultConfig()
+			ctx = testing.NewContext()
+			generator = inmemorygenerator.NewInMemoryGenerator(zap.NewNop().Sugar())
+			webhookConfig = controllers.NewWebhookConfig(client, config, generator, "mutating", "validating")
+		})
+
+		Describe("RotateCertificate", func() {
+			Context("if the certificates are valid", func() {
+				BeforeEach(func() {
+					secretData = generateSecretData(365)
+				})
+
+				It("loads them once", func() {
+					changed, err := webhookConfig.RotateCertificate(ctx)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(changed).To(BeTrue())
+					Expect(base64.StdEncoding.EncodeToString(webhookConfig.Certificate)).To(Equal(secretData["certificate"]))
+
+					changed, err = webhookConfig.RotateCertificate(ctx)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(changed).To(BeFalse())
+					Expect(client.UpdateCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("if the certificates expire soon", func() {
+				var oldData map[string]interface{}
+
+				// settle pretends the last change of the CA bundle happened before the last check
+				settle := func() {
+					secretData["ca_bundle_updated_at"] = base64.StdEncoding.EncodeToString([]byte(time.Now().Add(-2 * time.Hour).Format(time.RFC3339)))
+				}
+
+				BeforeEach(func() {
+					secretData = generateSecretData(7)
+					oldData = secretData
+				})
+
+				It("publishes the new CA before it switches the serving certificate", func() {
+					By("adding the new CA to the CA bundle")
+					changed, err := webhookConfig.RotateCertificate(ctx)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(changed).To(BeTrue())
+					Expect(secretData["certificate"]).To(Equal(oldData["certificate"]))
+					Expect(secretData["ca_certificate"]).To(Equal(oldData["ca_certificate"]))
+					Expect(secretData).To(HaveKey("next_ca_certificate"))
+					Expect(caBundles()).To(ConsistOf(
+						decode(oldData["ca_certificate"])+decode(secretData["next_ca_certificate"]),
+						decode(oldData["ca_certificate"])+decode(secretData["next_ca_certificate"]),
+					))
+					nextCA := secretData["next_ca_certificate"]
+
+					By("waiting for the CA bundle to settle")
+					changed, err = webhookConfig.RotateCertificate(ctx)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(changed).To(BeFalse())
+					Expect(secretData["certificate"]).To(Equal(oldData["certificate"]))
+
+					By("switching the serving certificate to the new CA")
+					settle()
+					changed, err = webhookConfig.RotateCertificate(ctx)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(changed).To(BeTrue())
+					Expect(secretData["certificate"]).ToNot(Equal(oldData["certificate"]))
+					Expect(secretData["ca_certificate"]).To(Equal(nextCA))
+					Expect(secretData["previous_ca_certificate"]).To(Equal(oldData["ca_certificate"]))
+					Expect(secretData).ToNot(HaveKey("next_ca_certificate"))
+
+					cert, err := afero.ReadFile(config.Fs, "/tmp/cf-operator-certs/cert.pem")
+					Expect(err).ToNot(HaveOccurred())
+					Expect(cert).To(Equal(webhookConfig.Certificate))
+
+					By("removing the previous CA from the CA bundle later")
+					settle()
+					changed, err = webhookConfig.RotateCertificate(ctx)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(changed).To(BeTrue())
+					Expect(secretData).ToNot(HaveKey("previous_ca_certificate"))
+					Expect(caBundles()).To(ConsistOf(decode(nextCA), decode(nextCA)))
+				})
+			})
+		})
+	})
 })
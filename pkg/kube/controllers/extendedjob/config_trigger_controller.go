@@ -0,0 +1,88 @@
+package extendedjob
+
+import (
+	"context"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/backoff"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
+)
+
+// AddConfigTrigger creates two ExtendedJob controllers, which start jobs when secrets or
+// config maps are created or changed
+func AddConfigTrigger(ctx context.Context, config *config.Config, mgr manager.Manager) error {
+	config = config.ForController("ext-job-config-trigger")
+	f := controllerutil.SetControllerReference
+	ctx = ctxlog.NewContextWithRecorder(ctx, "ext-job-config-trigger-reconciler", mgr.GetRecorder("ext-job-config-trigger-recorder"))
+
+	kinds := []struct {
+		kind   string
+		object runtime.Object
+		data   func(runtime.Object) interface{}
+	}{
+		{
+			kind:   ejv1.ConfigKindSecret,
+			object: &corev1.Secret{},
+			data: func(o runtime.Object) interface{} {
+				s := o.(*corev1.Secret)
+				return []interface{}{s.Data, s.StringData}
+			},
+		},
+		{
+			kind:   ejv1.ConfigKindConfigMap,
+			object: &corev1.ConfigMap{},
+			data: func(o runtime.Object) interface{} {
+				cm := o.(*corev1.ConfigMap)
+				return []interface{}{cm.Data, cm.BinaryData}
+			},
+		},
+	}
+
+	for _, k := range kinds {
+		r := NewConfigTriggerReconciler(ctx, config, mgr, k.kind, f)
+		c, err := controller.New("ext-job-"+k.kind+"-trigger-controller", mgr, controller.Options{
+			Reconciler:              backoff.NewReconciler(ctx, r, config.BackoffMin, config.BackoffMax),
+			MaxConcurrentReconciles: config.MaxConcurrentReconciles,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Trigger when configs are created, or seen for the first time after a restart,
+		// and when their content changes. The reconciler skips configs it has seen before.
+		data := k.data
+		p := predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				return true
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return false
+			},
+			GenericFunc: func(e event.GenericEvent) bool {
+				return false
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return !reflect.DeepEqual(data(e.ObjectOld), data(e.ObjectNew))
+			},
+		}
+
+		err = c.Watch(&source.Kind{Type: k.object}, &handler.EnqueueRequestForObject{}, p)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package extendedjob
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/names"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/versionedsecretstore"
+)
+
+var _ reconcile.Reconciler = &ConfigTriggerReconciler{}
+
+// NewConfigTriggerReconciler returns a new reconciler to start jobs triggered by secrets
+// or config maps, depending on kind
+func NewConfigTriggerReconciler(
+	ctx context.Context,
+	config *config.Config,
+	mgr manager.Manager,
+	kind string,
+	f setOwnerReferenceFunc,
+) reconcile.Reconciler {
+	return &ConfigTriggerReconciler{
+		ctx:                     ctx,
+		client:                  mgr.GetClient(),
+		config:                  config,
+		scheme:                  mgr.GetScheme(),
+		kind:                    kind,
+		setOwnerReference:       f,
+		versionedSecretStore:    versionedsecretstore.NewVersionedSecretStore(mgr.GetClient()),
+		versionedConfigMapStore: versionedsecretstore.NewVersionedConfigMapStore(mgr.GetClient()),
+	}
+}
+
+// ConfigTriggerReconciler implements the Reconciler interface
+type ConfigTriggerReconciler struct {
+	ctx                     context.Context
+	client                  client.Client
+	config                  *config.Config
+	scheme                  *runtime.Scheme
+	kind                    string
+	setOwnerReference       setOwnerReferenceFunc
+	versionedSecretStore    versionedsecretstore.VersionedSecretStore
+	versionedConfigMapStore versionedsecretstore.VersionedConfigMapStore
+}
+
+// configObject is the part of a secret or config map the reconciler looks at
+type configObject struct {
+	meta metav1.Object
+	// name is the name extended jobs refer to, it is the prefix for versioned configs
+	name string
+	hash string
+}
+
+// Reconcile starts jobs for extended jobs with a config change trigger matching the
+// request's secret or config map. Every content of a config triggers a job only once.
+func (r *ConfigTriggerReconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	// Set the ctx to be Background, as the top-level context for incoming requests.
+	ctx, cancel := context.WithTimeout(r.ctx, r.config.CtxTimeOut)
+	defer cancel()
+	ctx = ctxlog.NewRequestContext(ctx, request.Namespace, request.Name)
+
+	obj, err := r.getConfig(ctx, request)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// do not requeue, config is probably deleted
+			ctxlog.Debugf(ctx, "Failed to find %s, not retrying: %s", r.kind, err)
+			return reconcile.Result{}, nil
+		}
+		ctxlog.Errorf(ctx, "Failed to get the %s: %s", r.kind, err)
+		return reconcile.Result{}, err
+	}
+	if obj == nil {
+		return reconcile.Result{}, nil
+	}
+
+	eJobs := &ejv1.ExtendedJobList{}
+	err = r.client.List(ctx, client.InNamespace(request.Namespace), eJobs)
+	if err != nil {
+		ctxlog.Errorf(ctx, "Failed to query extended jobs: %s", err)
+		return reconcile.Result{}, err
+	}
+
+	key := fmt.Sprintf("%s/%s", r.kind, obj.name)
+	for i := range eJobs.Items {
+		eJob := &eJobs.Items[i]
+		if eJob.Namespace != request.Namespace || !r.matches(eJob, obj) {
+			continue
+		}
+
+		last, seen := eJob.Status.Triggers[key]
+		if last == obj.hash {
+			ctxlog.Debugf(ctx, "Skip '%s': %s '%s' did not change", eJob.Name, r.kind, obj.name)
+			continue
+		}
+
+		// Configs which existed before the extended job only change its state
+		if seen || !obj.meta.GetCreationTimestamp().Time.Before(eJob.CreationTimestamp.Time) {
+			err = startJob(ctx, r.client, r.scheme, r.setOwnerReference, r.versionedSecretStore, eJob, fmt.Sprintf("%s '%s' changed", r.kind, obj.name))
+			if err != nil {
+				ctxlog.WithEvent(eJob, "CreateJobError").Errorf(ctx, "Failed to create job for '%s' via %s '%s': %s", eJob.Name, r.kind, obj.name, err)
+				return reconcile.Result{}, err
+			}
+			ctxlog.WithEvent(eJob, "CreateJob").Infof(ctx, "Created job for '%s' via %s '%s'", eJob.Name, r.kind, obj.name)
+		}
+
+		if eJob.Status.Triggers == nil {
+			eJob.Status.Triggers = map[string]string{}
+		}
+		eJob.Status.Triggers[key] = obj.hash
+		err = r.client.Update(ctx, eJob)
+		if err != nil {
+			ctxlog.WithEvent(eJob, "UpdateError").Errorf(ctx, "Failed to record trigger of '%s': %s", eJob.Name, err)
+			return reconcile.Result{}, err
+		}
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// matches returns true if the extended job is triggered by the config
+func (r *ConfigTriggerReconciler) matches(eJob *ejv1.ExtendedJob, obj *configObject) bool {
+	trigger := eJob.Spec.Trigger.Config
+	if eJob.Spec.Trigger.Strategy != ejv1.TriggerConfigChange || trigger == nil || trigger.Kind != r.kind {
+		return false
+	}
+	if trigger.Name != "" && trigger.Name != obj.name && trigger.Name != obj.meta.GetName() {
+		return false
+	}
+	return matchSelector(trigger.Selector, obj.meta.GetLabels())
+}
+
+// getConfig returns the secret or config map of the request. It returns nil for chunks
+// and versions which are not the latest of a versioned secret or config map.
+func (r *ConfigTriggerReconciler) getConfig(ctx context.Context, request reconcile.Request) (*configObject, error) {
+	obj := &configObject{name: request.Name}
+	data := map[string]string{}
+
+	switch r.kind {
+	case ejv1.ConfigKindSecret:
+		secret := &corev1.Secret{}
+		err := r.client.Get(ctx, request.NamespacedName, secret)
+		if err != nil {
+			return nil, err
+		}
+		obj.meta = secret
+
+		switch secret.GetLabels()[versionedsecretstore.LabelSecretKind] {
+		case versionedsecretstore.VersionSecretChunkKind:
+			return nil, nil
+		case versionedsecretstore.VersionSecretKind:
+			obj.name = names.GetPrefixFromVersionedSecretName(secret.Name)
+			latest, err := r.versionedSecretStore.Latest(ctx, secret.Namespace, obj.name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not get latest version of secret '%s'", obj.name)
+			}
+			if latest.Name != secret.Name {
+				return nil, nil
+			}
+		}
+
+		for k, v := range secret.Data {
+			data[k] = string(v)
+		}
+		for k, v := range secret.StringData {
+			data[k] = v
+		}
+	case ejv1.ConfigKindConfigMap:
+		configMap := &corev1.ConfigMap{}
+		err := r.client.Get(ctx, request.NamespacedName, configMap)
+		if err != nil {
+			return nil, err
+		}
+		obj.meta = configMap
+
+		if configMap.GetLabels()[versionedsecretstore.LabelSecretKind] == versionedsecretstore.VersionConfigMapKind {
+			obj.name = names.GetPrefixFromVersionedSecretName(configMap.Name)
+			latest, err := r.versionedConfigMapStore.Latest(ctx, configMap.Namespace, obj.name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not get latest version of config map '%s'", obj.name)
+			}
+			if latest.Name != configMap.Name {
+				return nil, nil
+			}
+		}
+
+		for k, v := range configMap.Data {
+			data[k] = v
+		}
+		for k, v := range configMap.BinaryData {
+			data[k] = string(v)
+		}
+	default:
+		return nil, errors.Errorf("unsupported config kind '%s'", r.kind)
+	}
+
+	// Map keys are sorted when marshalling, so the same data always has the same hash
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not marshal data of %s '%s'", r.kind, request.Name)
+	}
+	obj.hash = fmt.Sprintf("%x", sha1.Sum(dataBytes))
+
+	return obj, nil
+}
@@ -0,0 +1,209 @@
+package extendedjob_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
+	. "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/extendedjob"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/fakes"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/versionedsecretstore"
+	helper "code.cloudfoundry.org/cf-operator/pkg/testhelper"
+	"code.cloudfoundry.org/cf-operator/testing"
+)
+
+var _ = Describe("ConfigTriggerReconciler", func() {
+	var (
+		env        testing.Catalog
+		mgr        *fakes.FakeManager
+		client     crc.Client
+		reconciler reconcile.Reconciler
+		kind       string
+		eJob       ejv1.ExtendedJob
+		secret     *corev1.Secret
+		objects    []runtime.Object
+	)
+
+	setOwnerReference := func(owner, object metav1.Object, scheme *runtime.Scheme) error {
+		return nil
+	}
+
+	jobs := func() []batchv1.Job {
+		list := &batchv1.JobList{}
+		Expect(client.List(context.Background(), &crc.ListOptions{}, list)).To(Succeed())
+		return list.Items
+	}
+
+	updatedEJob := func() ejv1.ExtendedJob {
+		result := ejv1.ExtendedJob{}
+		Expect(client.Get(context.Background(), types.NamespacedName{Name: eJob.Name, Namespace: eJob.Namespace}, &result)).To(Succeed())
+		return result
+	}
+
+	act := func(name string) (reconcile.Result, error) {
+		return reconciler.Reconcile(reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: name, Namespace: "default"},
+		})
+	}
+
+	BeforeEach(func() {
+		controllers.AddToScheme(scheme.Scheme)
+		mgr = &fakes.FakeManager{}
+		mgr.GetSchemeReturns(scheme.Scheme)
+
+		kind = ejv1.ConfigKindSecret
+		eJob = env.ConfigChangeExtendedJob("fake-ejob", ejv1.ConfigKindSecret, "db-credentials")
+		eJob.Namespace = "default"
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-credentials", Namespace: "default"},
+			Data:       map[string][]byte{"password": []byte("secret")},
+		}
+		objects = []runtime.Object{}
+	})
+
+	JustBeforeEach(func() {
+		_, log := helper.NewTestLogger()
+		ctx := ctxlog.NewParentContext(log)
+
+		client = fake.NewFakeClient(append(objects, &eJob, secret)...)
+		mgr.GetClientReturns(client)
+		reconciler = NewConfigTriggerReconciler(ctx, &config.Config{CtxTimeOut: 10 * time.Second}, mgr, kind, setOwnerReference)
+	})
+
+	It("starts a job when the secret is created and records its content", func() {
+		_, err := act("db-credentials")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(jobs()).To(HaveLen(1))
+		Expect(jobs()[0].Labels).To(HaveKeyWithValue("ejob-name", eJob.Name))
+		Expect(updatedEJob().Status.Triggers).To(HaveKey("secret/db-credentials"))
+	})
+
+	It("starts another job only when the content changes", func() {
+		_, err := act("db-credentials")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = act("db-credentials")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(jobs()).To(HaveLen(1))
+
+		secret.Data["password"] = []byte("rotated")
+		Expect(client.Update(context.Background(), secret)).To(Succeed())
+		_, err = act("db-credentials")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(jobs()).To(HaveLen(2))
+	})
+
+	It("ignores other secrets", func() {
+		Expect(client.Create(context.Background(), &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}})).To(Succeed())
+		_, err := act("other")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(jobs()).To(BeEmpty())
+	})
+
+	Context("when the secret existed before the extended job", func() {
+		BeforeEach(func() {
+			secret.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+			eJob.CreationTimestamp = metav1.NewTime(time.Now())
+		})
+
+		It("only records its content", func() {
+			_, err := act("db-credentials")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(jobs()).To(BeEmpty())
+			Expect(updatedEJob().Status.Triggers).To(HaveKey("secret/db-credentials"))
+		})
+	})
+
+	Context("when the trigger uses a selector", func() {
+		BeforeEach(func() {
+			eJob.Spec.Trigger.Config.Name = ""
+			eJob.Spec.Trigger.Config.Selector = &ejv1.Selector{MatchLabels: &labels.Set{"rotate": "true"}}
+		})
+
+		It("starts a job for matching secrets only", func() {
+			_, err := act("db-credentials")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(jobs()).To(BeEmpty())
+
+			secret.Labels = map[string]string{"rotate": "true"}
+			Expect(client.Update(context.Background(), secret)).To(Succeed())
+			_, err = act("db-credentials")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(jobs()).To(HaveLen(1))
+		})
+	})
+
+	Context("when the secret is versioned", func() {
+		version := func(v string, password string) *corev1.Secret {
+			return &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "db-credentials-v" + v,
+					Namespace: "default",
+					Labels: map[string]string{
+						versionedsecretstore.LabelSecretKind: versionedsecretstore.VersionSecretKind,
+						versionedsecretstore.LabelVersion:    v,
+					},
+				},
+				Data: map[string][]byte{"password": []byte(password)},
+			}
+		}
+
+		BeforeEach(func() {
+			objects = append(objects, version("1", "old"), version("2", "new"))
+		})
+
+		It("starts a job for the latest version", func() {
+			_, err := act("db-credentials-v2")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(jobs()).To(HaveLen(1))
+			Expect(updatedEJob().Status.Triggers).To(HaveKey("secret/db-credentials"))
+		})
+
+		It("ignores older versions", func() {
+			_, err := act("db-credentials-v1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(jobs()).To(BeEmpty())
+		})
+	})
+
+	Context("when the reconciler watches config maps", func() {
+		BeforeEach(func() {
+			kind = ejv1.ConfigKindConfigMap
+			objects = append(objects, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "db-credentials", Namespace: "default"},
+				Data:       map[string]string{"host": "db"},
+			})
+		})
+
+		It("ignores extended jobs triggered by secrets", func() {
+			_, err := act("db-credentials")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(jobs()).To(BeEmpty())
+		})
+
+		It("starts jobs for extended jobs triggered by config maps", func() {
+			eJob.Spec.Trigger.Config.Kind = ejv1.ConfigKindConfigMap
+			Expect(client.Update(context.Background(), &eJob)).To(Succeed())
+			_, err := act("db-credentials")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(jobs()).To(HaveLen(1))
+			Expect(updatedEJob().Status.Triggers).To(HaveKey("configmap/db-credentials"))
+		})
+	})
+})
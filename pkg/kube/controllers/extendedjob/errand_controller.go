@@ -17,6 +17,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/backoff"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/names"
@@ -27,11 +28,15 @@ import (
 // AddErrand creates a new ExtendedJob controller to start errands when their
 // trigger strategy matches
 func AddErrand(ctx context.Context, config *config.Config, mgr manager.Manager) error {
+	config = config.ForController("ext-job-errand")
 	f := controllerutil.SetControllerReference
 	ctx = ctxlog.NewContextWithRecorder(ctx, "ext-job-errand-reconciler", mgr.GetRecorder("ext-job-errand-recorder"))
 	owner := owner.NewOwner(mgr.GetClient(), mgr.GetScheme())
 	r := NewErrandReconciler(ctx, config, mgr, f, owner)
-	c, err := controller.New("ext-job-errand-controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New("ext-job-errand-controller", mgr, controller.Options{
+		Reconciler:              backoff.NewReconciler(ctx, r, config.BackoffMin, config.BackoffMax),
+		MaxConcurrentReconciles: config.MaxConcurrentReconciles,
+	})
 	if err != nil {
 		return err
 	}
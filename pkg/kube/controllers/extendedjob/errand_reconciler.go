@@ -2,10 +2,12 @@ package extendedjob
 
 import (
 	"context"
+	"encoding/json"
 	"reflect"
 
 	"github.com/pkg/errors"
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -13,6 +15,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
 	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
@@ -63,6 +66,7 @@ func (r *ErrandReconciler) Reconcile(request reconcile.Request) (reconcile.Resul
 	// Set the ctx to be Background, as the top-level context for incoming requests.
 	ctx, cancel := context.WithTimeout(r.ctx, r.config.CtxTimeOut)
 	defer cancel()
+	ctx = ctxlog.NewRequestContext(ctx, request.Namespace, request.Name)
 
 	ctxlog.Info(ctx, "Reconciling errand job ", request.NamespacedName)
 	err := r.client.Get(ctx, request.NamespacedName, eJob)
@@ -77,8 +81,23 @@ func (r *ErrandReconciler) Reconcile(request reconcile.Request) (reconcile.Resul
 		ctxlog.Errorf(ctx, "Failed to get the extended job '%s': %s", request.NamespacedName, err)
 		return result, err
 	}
+	if deployment, ok := eJob.GetLabels()[bdv1.LabelDeploymentName]; ok {
+		ctx = ctxlog.WithFields(ctx, "deployment", deployment)
+	}
 
+	reason := string(eJob.Spec.Trigger.Strategy)
+	runID := ""
+	var overrides []ejv1.ContainerOverride
 	if eJob.Spec.Trigger.Strategy == ejv1.TriggerNow {
+		// The run ID and overrides only apply to this run
+		runID = eJob.GetAnnotations()[ejv1.AnnotationRunID]
+		var overridesErr error
+		overrides, overridesErr = runOverrides(eJob)
+		annotations := eJob.GetAnnotations()
+		delete(annotations, ejv1.AnnotationRunID)
+		delete(annotations, ejv1.AnnotationRunOverrides)
+		eJob.SetAnnotations(annotations)
+
 		// set Strategy back to manual for errand jobs
 		eJob.Spec.Trigger.Strategy = ejv1.TriggerManual
 		err = r.client.Update(ctx, eJob)
@@ -86,6 +105,12 @@ func (r *ErrandReconciler) Reconcile(request reconcile.Request) (reconcile.Resul
 			ctxlog.WithEvent(eJob, "UpdateError").Errorf(ctx, "Failed to revert to 'trigger.strategy=manual' on job '%s': %s", eJob.Name, err)
 			return result, err
 		}
+
+		if overridesErr != nil {
+			// do not requeue, the run can't be started with these overrides
+			ctxlog.WithEvent(eJob, "OverridesError").Errorf(ctx, "Skip run of '%s': %s", eJob.Name, overridesErr)
+			return result, nil
+		}
 	}
 
 	eJobCopy := eJob.DeepCopy()
@@ -98,7 +123,7 @@ func (r *ErrandReconciler) Reconcile(request reconcile.Request) (reconcile.Resul
 	if !reflect.DeepEqual(eJob, eJobCopy) {
 		err = r.client.Update(ctx, eJob)
 		if err != nil {
-			ctxlog.Errorf(ctx, "Could not update eJob '%s': ", eJob.GetName(), err)
+			ctxlog.Errorf(ctx, "Could not update eJob '%s': %s", eJob.GetName(), err)
 			return result, errors.Wrapf(err, "could not update eJob '%s'", eJob.GetName())
 		}
 	}
@@ -124,7 +149,26 @@ func (r *ErrandReconciler) Reconcile(request reconcile.Request) (reconcile.Resul
 		}
 	}
 
-	err = r.createJob(ctx, *eJob)
+	runEJob := eJob.DeepCopy()
+	if runID != "" {
+		annotations := runEJob.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[ejv1.AnnotationRunID] = runID
+		runEJob.SetAnnotations(annotations)
+	}
+	if len(overrides) > 0 {
+		err = applyOverrides(&runEJob.Spec.Template, overrides)
+		if err != nil {
+			// do not requeue, the run can't be started with these overrides
+			ctxlog.WithEvent(eJob, "OverridesError").Errorf(ctx, "Skip run of '%s': %s", eJob.Name, err)
+			return result, nil
+		}
+		ctxlog.Debugf(ctx, "Overriding %d containers for this run of '%s'", len(overrides), eJob.Name)
+	}
+
+	err = createErrandJob(ctx, r.client, r.scheme, r.setOwnerReference, *runEJob, reason)
 	if err != nil {
 		if apierrors.IsAlreadyExists(err) {
 			ctxlog.WithEvent(eJob, "AlreadyRunning").Infof(ctx, "Skip '%s' triggered manually: already running", eJob.Name)
@@ -150,37 +194,128 @@ func (r *ErrandReconciler) Reconcile(request reconcile.Request) (reconcile.Resul
 	return result, err
 }
 
-func (r *ErrandReconciler) createJob(ctx context.Context, eJob ejv1.ExtendedJob) error {
+// createErrandJob creates a job from the template of the extended job, it is used for
+// errands and all triggers which are not triggered by pods. The reason is recorded in
+// the run history.
+func createErrandJob(ctx context.Context, c client.Client, scheme *runtime.Scheme, setOwnerReference setOwnerReferenceFunc, eJob ejv1.ExtendedJob, reason string) error {
 	template := eJob.Spec.Template.DeepCopy()
 
+	annotations := map[string]string{ejv1.AnnotationTriggerReason: reason}
+	if runID, ok := eJob.GetAnnotations()[ejv1.AnnotationRunID]; ok {
+		annotations[ejv1.AnnotationRunID] = runID
+	}
+
 	if template.Labels == nil {
 		template.Labels = map[string]string{}
 	}
 	template.Labels["ejob-name"] = eJob.Name
 
+	if usesOutputFiles(eJob.Spec.Output) {
+		addOutputCollector(template)
+	}
+
 	name, err := names.JobName(eJob.Name, "", "")
 	if err != nil {
 		return errors.Wrapf(err, "could not generate job name for eJob '%s'", eJob.Name)
 	}
 	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: eJob.Namespace,
-			Labels:    map[string]string{"extendedjob": "true"},
+			Name:        name,
+			Namespace:   eJob.Namespace,
+			Labels:      map[string]string{"extendedjob": "true", "ejob-name": eJob.Name},
+			Annotations: annotations,
 		},
-		Spec: batchv1.JobSpec{Template: *template},
+		Spec: jobSpec(eJob, *template),
 	}
 
-	err = r.setOwnerReference(&eJob, job, r.scheme)
+	err = setOwnerReference(&eJob, job, scheme)
 	if err != nil {
 		ctxlog.WithEvent(&eJob, "SetOwnerReferenceError").Errorf(ctx, "failed to set owner reference on job for '%s': %s", eJob.Name, err)
 		return err
 	}
 
-	err = r.client.Create(ctx, job)
+	err = c.Create(ctx, job)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// runOverrides returns the container overrides for the next run of the errand
+func runOverrides(eJob *ejv1.ExtendedJob) ([]ejv1.ContainerOverride, error) {
+	value, ok := eJob.GetAnnotations()[ejv1.AnnotationRunOverrides]
+	if !ok {
+		return nil, nil
+	}
+
+	var overrides []ejv1.ContainerOverride
+	err := json.Unmarshal([]byte(value), &overrides)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid annotation '%s'", ejv1.AnnotationRunOverrides)
+	}
+	return overrides, nil
+}
+
+// applyOverrides changes the args and env of the template's containers
+func applyOverrides(template *corev1.PodTemplateSpec, overrides []ejv1.ContainerOverride) error {
+	for _, override := range overrides {
+		found := false
+		for i := range template.Spec.Containers {
+			c := &template.Spec.Containers[i]
+			if c.Name != override.Name {
+				continue
+			}
+			found = true
+
+			if override.Args != nil {
+				c.Args = override.Args
+			}
+			for _, env := range override.Env {
+				replaced := false
+				for j := range c.Env {
+					if c.Env[j].Name == env.Name {
+						c.Env[j] = env
+						replaced = true
+					}
+				}
+				if !replaced {
+					c.Env = append(c.Env, env)
+				}
+			}
+		}
+		if !found {
+			return errors.Errorf("container '%s' to override does not exist", override.Name)
+		}
+	}
+	return nil
+}
+
+// jobSpec returns the spec of a job running the template of the extended job
+func jobSpec(eJob ejv1.ExtendedJob, template corev1.PodTemplateSpec) batchv1.JobSpec {
+	return batchv1.JobSpec{
+		Template:              template,
+		BackoffLimit:          eJob.Spec.BackoffLimit,
+		ActiveDeadlineSeconds: eJob.Spec.ActiveDeadlineSeconds,
+		Parallelism:           eJob.Spec.Parallelism,
+		Completions:           eJob.Spec.Completions,
+	}
+}
+
+// startJob updates the references to versioned secrets in the template of the extended
+// job to their latest versions and creates a job from it
+func startJob(ctx context.Context, c client.Client, scheme *runtime.Scheme, setOwnerReference setOwnerReferenceFunc, store versionedsecretstore.VersionedSecretStore, eJob *ejv1.ExtendedJob, reason string) error {
+	eJobCopy := eJob.DeepCopy()
+	err := store.UpdateSecretReferences(ctx, eJob.GetNamespace(), &eJob.Spec.Template.Spec)
+	if err != nil {
+		return errors.Wrapf(err, "could not update secret references of eJob '%s'", eJob.Name)
+	}
+	if !reflect.DeepEqual(eJob, eJobCopy) {
+		err = c.Update(ctx, eJob)
+		if err != nil {
+			return errors.Wrapf(err, "could not update eJob '%s'", eJob.Name)
+		}
+	}
+
+	return createErrandJob(ctx, c, scheme, setOwnerReference, *eJob, reason)
+}
@@ -11,6 +11,7 @@ import (
 	"go.uber.org/zap/zaptest/observer"
 
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -212,6 +213,66 @@ var _ = Describe("ErrandReconciler", func() {
 				})
 			})
 
+			Context("and the run is requested with overrides", func() {
+				BeforeEach(func() {
+					eJob = env.ErrandExtendedJob("fake-pod")
+					eJob.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "MODE", Value: "full"}}
+					eJob.Annotations = map[string]string{
+						ejv1.AnnotationRunID:        "1234",
+						ejv1.AnnotationRunOverrides: `[{"name": "busybox", "args": ["--dry-run"], "env": [{"name": "MODE", "value": "quick"}, {"name": "DEBUG", "value": "1"}]}]`,
+					}
+					runtimeObjects = []runtime.Object{
+						&eJob,
+					}
+					client = fake.NewFakeClient(runtimeObjects...)
+					mgr.GetClientReturns(client)
+
+					request = newRequest(eJob)
+				})
+
+				It("creates the job with the overrides and the run ID", func() {
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+
+					obj := &batchv1.JobList{}
+					err = client.List(context.Background(), &crc.ListOptions{}, obj)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(obj.Items).To(HaveLen(1))
+
+					job := obj.Items[0]
+					Expect(job.Annotations).To(HaveKeyWithValue(ejv1.AnnotationRunID, "1234"))
+					container := job.Spec.Template.Spec.Containers[0]
+					Expect(container.Args).To(Equal([]string{"--dry-run"}))
+					Expect(container.Env).To(Equal([]corev1.EnvVar{{Name: "MODE", Value: "quick"}, {Name: "DEBUG", Value: "1"}}))
+				})
+
+				It("removes the overrides from the extended job", func() {
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+
+					updated := ejv1.ExtendedJob{}
+					err = client.Get(context.Background(), types.NamespacedName{Name: eJob.Name, Namespace: eJob.Namespace}, &updated)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(updated.Annotations).ToNot(HaveKey(ejv1.AnnotationRunID))
+					Expect(updated.Annotations).ToNot(HaveKey(ejv1.AnnotationRunOverrides))
+					Expect(updated.Spec.Template.Spec.Containers[0].Args).To(BeEmpty())
+				})
+
+				It("does not start the run if a container does not exist", func() {
+					eJob.Annotations[ejv1.AnnotationRunOverrides] = `[{"name": "missing", "args": ["--dry-run"]}]`
+					Expect(client.Update(context.Background(), &eJob)).To(Succeed())
+
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(logs.FilterMessageSnippet("container 'missing' to override does not exist").Len()).To(Equal(1))
+
+					obj := &batchv1.JobList{}
+					err = client.List(context.Background(), &crc.ListOptions{}, obj)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(obj.Items).To(BeEmpty())
+				})
+			})
+
 			Context("and the errand is an auto-errand", func() {
 				BeforeEach(func() {
 					eJob = env.AutoErrandExtendedJob("fake-pod")
@@ -247,6 +308,109 @@ var _ = Describe("ErrandReconciler", func() {
 				})
 			})
 
+			Context("and the errand limits retries and run time", func() {
+				BeforeEach(func() {
+					eJob = env.AutoErrandExtendedJob("fake-pod")
+					backoffLimit := int32(3)
+					deadline := int64(600)
+					eJob.Spec.BackoffLimit = &backoffLimit
+					eJob.Spec.ActiveDeadlineSeconds = &deadline
+					runtimeObjects = []runtime.Object{
+						&eJob,
+					}
+					client = fake.NewFakeClient(runtimeObjects...)
+					mgr.GetClientReturns(client)
+
+					request = newRequest(eJob)
+				})
+
+				It("creates the job with the limits and the trigger reason", func() {
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+
+					obj := &batchv1.JobList{}
+					err = client.List(context.Background(), &crc.ListOptions{}, obj)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(obj.Items).To(HaveLen(1))
+
+					job := obj.Items[0]
+					Expect(*job.Spec.BackoffLimit).To(Equal(int32(3)))
+					Expect(*job.Spec.ActiveDeadlineSeconds).To(Equal(int64(600)))
+					Expect(job.Annotations).To(HaveKeyWithValue(ejv1.AnnotationTriggerReason, "once"))
+				})
+			})
+
+			Context("and the errand runs several pods", func() {
+				BeforeEach(func() {
+					eJob = env.AutoErrandExtendedJob("fake-pod")
+					parallelism := int32(2)
+					completions := int32(4)
+					eJob.Spec.Parallelism = &parallelism
+					eJob.Spec.Completions = &completions
+					runtimeObjects = []runtime.Object{
+						&eJob,
+					}
+					client = fake.NewFakeClient(runtimeObjects...)
+					mgr.GetClientReturns(client)
+
+					request = newRequest(eJob)
+				})
+
+				It("creates the job with parallelism and completions", func() {
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+
+					obj := &batchv1.JobList{}
+					err = client.List(context.Background(), &crc.ListOptions{}, obj)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(obj.Items).To(HaveLen(1))
+
+					job := obj.Items[0]
+					Expect(*job.Spec.Parallelism).To(Equal(int32(2)))
+					Expect(*job.Spec.Completions).To(Equal(int32(4)))
+				})
+			})
+
+			Context("and the output is collected from files", func() {
+				BeforeEach(func() {
+					eJob = env.AutoErrandExtendedJob("fake-pod")
+					eJob.Spec.Output = &ejv1.Output{
+						NamePrefix: "fake-pod-output-",
+						Source:     ejv1.OutputSourceFiles,
+					}
+					runtimeObjects = []runtime.Object{
+						&eJob,
+					}
+					client = fake.NewFakeClient(runtimeObjects...)
+					mgr.GetClientReturns(client)
+
+					request = newRequest(eJob)
+				})
+
+				It("runs the containers before the output collector", func() {
+					_, err := act()
+					Expect(err).ToNot(HaveOccurred())
+
+					obj := &batchv1.JobList{}
+					err = client.List(context.Background(), &crc.ListOptions{}, obj)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(obj.Items).To(HaveLen(1))
+
+					spec := obj.Items[0].Spec.Template.Spec
+					Expect(spec.InitContainers).To(HaveLen(1))
+					Expect(spec.InitContainers[0].Name).To(Equal(eJob.Spec.Template.Spec.Containers[0].Name))
+					Expect(spec.InitContainers[0].Env).To(ContainElement(corev1.EnvVar{Name: OutputDirEnvName, Value: OutputDir}))
+					Expect(spec.InitContainers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+						Name:      "ejob-output",
+						MountPath: OutputDir,
+						SubPath:   spec.InitContainers[0].Name,
+					}))
+					Expect(spec.Containers).To(HaveLen(1))
+					Expect(spec.Containers[0].Name).To(Equal(OutputCollectorName))
+					Expect(spec.Containers[0].Args).To(ContainElement(ContainSubstring("output-collect")))
+				})
+			})
+
 			Context("and the auto-errand is updated on config change", func() {
 				BeforeEach(func() {
 					eJob = env.AutoErrandExtendedJob("fake-pod")
@@ -6,6 +6,8 @@ import (
 	"github.com/pkg/errors"
 
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -14,12 +16,15 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/backoff"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
 )
 
 // AddJob creates a new ExtendedJob controller and adds it to the Manager
 func AddJob(ctx context.Context, config *config.Config, mgr manager.Manager) error {
+	config = config.ForController("ext-job-job")
 	client, err := corev1client.NewForConfig(mgr.GetConfig())
 	if err != nil {
 		return errors.Wrap(err, "Could not get kube client")
@@ -27,13 +32,19 @@ func AddJob(ctx context.Context, config *config.Config, mgr manager.Manager) err
 	podLogGetter := NewPodLogGetter(client)
 	ctx = ctxlog.NewContextWithRecorder(ctx, "ext-job-job-reconciler", mgr.GetRecorder("ext-job-job-recorder"))
 	jobReconciler, err := NewJobReconciler(ctx, config, mgr, podLogGetter)
-	jobController, err := controller.New("ext-job-job-controller", mgr, controller.Options{Reconciler: jobReconciler})
+	jobController, err := controller.New("ext-job-job-controller", mgr, controller.Options{
+		Reconciler:              backoff.NewReconciler(ctx, jobReconciler, config.BackoffMin, config.BackoffMax),
+		MaxConcurrentReconciles: config.MaxConcurrentReconciles,
+	})
 	if err != nil {
 		return err
 	}
 	predicate := predicate.Funcs{
-		// We're only interested in Jobs going from Active to final state (Succeeded or Failed)
-		CreateFunc:  func(e event.CreateEvent) bool { return false },
+		// We're only interested in Jobs going from Active to final state (Succeeded or Failed),
+		// and in failed Jobs after a restart, which are deleted when their TTL expires
+		CreateFunc: func(e event.CreateEvent) bool {
+			return isEJobJob(e.Meta.GetLabels()) && jobOutcome(e.Object.(*batchv1.Job)) == ejv1.JobFailed
+		},
 		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
 		GenericFunc: func(e event.GenericEvent) bool { return false },
 		UpdateFunc: func(e event.UpdateEvent) bool {
@@ -43,7 +54,7 @@ func AddJob(ctx context.Context, config *config.Config, mgr manager.Manager) err
 			if !isEJobJob(e.MetaNew.GetLabels()) {
 				return false
 			}
-			return e.ObjectNew.(*batchv1.Job).Status.Succeeded == 1 || e.ObjectNew.(*batchv1.Job).Status.Failed == 1
+			return jobOutcome(e.ObjectNew.(*batchv1.Job)) != ""
 		},
 	}
 	return jobController.Watch(&source.Kind{Type: &batchv1.Job{}}, &handler.EnqueueRequestForObject{}, predicate)
@@ -56,3 +67,32 @@ func isEJobJob(labels map[string]string) bool {
 	}
 	return false
 }
+
+// jobOutcome returns whether the job succeeded or failed, or an empty outcome if it
+// is still running. Only the conditions set by the job controller are used, as a job
+// without active pods might just be waiting to retry a failed pod.
+func jobOutcome(job *batchv1.Job) ejv1.JobOutcome {
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			return ejv1.JobSucceeded
+		case batchv1.JobFailed:
+			return ejv1.JobFailed
+		}
+	}
+	return ""
+}
+
+// finishTime returns the time the job succeeded or failed, or nil if it is unknown
+func finishTime(job *batchv1.Job) *metav1.Time {
+	for _, c := range job.Status.Conditions {
+		if c.Status == corev1.ConditionTrue && (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) {
+			t := c.LastTransitionTime
+			return &t
+		}
+	}
+	return job.Status.CompletionTime
+}
@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -19,9 +22,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
 	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/metrics"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/versionedsecretstore"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -29,25 +34,28 @@ import (
 // NewJobReconciler returns a new Reconciler
 func NewJobReconciler(ctx context.Context, config *config.Config, mgr manager.Manager, podLogGetter PodLogGetter) (reconcile.Reconciler, error) {
 	versionedSecretStore := versionedsecretstore.NewVersionedSecretStore(mgr.GetClient())
+	versionedConfigMapStore := versionedsecretstore.NewVersionedConfigMapStore(mgr.GetClient())
 
 	return &ReconcileJob{
-		ctx:                  ctx,
-		config:               config,
-		client:               mgr.GetClient(),
-		podLogGetter:         podLogGetter,
-		scheme:               mgr.GetScheme(),
-		versionedSecretStore: versionedSecretStore,
+		ctx:                     ctx,
+		config:                  config,
+		client:                  mgr.GetClient(),
+		podLogGetter:            podLogGetter,
+		scheme:                  mgr.GetScheme(),
+		versionedSecretStore:    versionedSecretStore,
+		versionedConfigMapStore: versionedConfigMapStore,
 	}, nil
 }
 
 // ReconcileJob reconciles an Job object
 type ReconcileJob struct {
-	ctx                  context.Context
-	client               client.Client
-	podLogGetter         PodLogGetter
-	scheme               *runtime.Scheme
-	config               *config.Config
-	versionedSecretStore versionedsecretstore.VersionedSecretStore
+	ctx                     context.Context
+	client                  client.Client
+	podLogGetter            PodLogGetter
+	scheme                  *runtime.Scheme
+	config                  *config.Config
+	versionedSecretStore    versionedsecretstore.VersionedSecretStore
+	versionedConfigMapStore versionedsecretstore.VersionedConfigMapStore
 }
 
 // Reconcile reads that state of the cluster for a Job object that is owned by an ExtendedJob and
@@ -62,6 +70,7 @@ func (r *ReconcileJob) Reconcile(request reconcile.Request) (reconcile.Result, e
 	// Set the ctx to be Background, as the top-level context for incoming requests.
 	ctx, cancel := context.WithTimeout(r.ctx, r.config.CtxTimeOut)
 	defer cancel()
+	ctx = ctxlog.NewRequestContext(ctx, request.Namespace, request.Name)
 
 	ctxlog.Infof(ctx, "Reconciling job output '%s' in the ExtendedJob context", request.NamespacedName)
 	err := r.client.Get(ctx, request.NamespacedName, instance)
@@ -77,6 +86,9 @@ func (r *ReconcileJob) Reconcile(request reconcile.Request) (reconcile.Result, e
 		ctxlog.Info(ctx, "Error reading the object")
 		return reconcile.Result{}, err
 	}
+	if deployment, ok := instance.GetLabels()[bdv1.LabelDeploymentName]; ok {
+		ctx = ctxlog.WithFields(ctx, "deployment", deployment)
+	}
 
 	// Get the job's extended job parent
 	parentName := ""
@@ -96,24 +108,34 @@ func (r *ReconcileJob) Reconcile(request reconcile.Request) (reconcile.Result, e
 		return reconcile.Result{}, errors.Wrap(err, "getting parent ExtendedJob")
 	}
 
-	// Persist output if needed
-	if !reflect.DeepEqual(ejv1.Output{}, ej.Spec.Output) && ej.Spec.Output != nil {
-		if instance.Status.Succeeded == 1 || (instance.Status.Failed == 1 && ej.Spec.Output.WriteOnFailure) {
-			ctxlog.WithEvent(&ej, "PersistingOutput").Infof(ctx, "Persisting output of job '%s'", instance.Name)
-			err = r.persistOutput(ctx, instance, ej.Spec.Output)
-			if err != nil {
-				ctxlog.WithEvent(instance, "PersistOutputError").Errorf(ctx, "Could not persist output: '%s'", err)
-				return reconcile.Result{}, err
-			}
-		} else if instance.Status.Failed == 1 && !ej.Spec.Output.WriteOnFailure {
-			ctxlog.WithEvent(&ej, "FailedPersistingOutput").Infof(ctx, "Will not persist output of job '%s' because it failed", instance.Name)
-		} else {
-			ctxlog.WithEvent(instance, "StateError").Errorf(ctx, "Job is in an unexpected state: %#v", instance)
+	outcome := jobOutcome(instance)
+	if outcome == "" {
+		ctxlog.WithEvent(instance, "StateError").Errorf(ctx, "Job '%s' is in an unexpected state: %d active, %d succeeded, %d failed", instance.Name, instance.Status.Active, instance.Status.Succeeded, instance.Status.Failed)
+		return reconcile.Result{}, nil
+	}
+
+	// Failed jobs are reconciled again when they expire, their run is only handled once.
+	// The job is marked, as its run might be trimmed from the history in the meantime.
+	_, recorded := instance.GetAnnotations()[ejv1.AnnotationRunRecorded]
+	if !recorded && findRun(&ej, instance.Name) == nil {
+		err = r.finishRun(ctx, &ej, instance, outcome)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+	if !recorded {
+		err = r.markRunRecorded(ctx, &ej, instance)
+		if err != nil {
+			return reconcile.Result{}, err
 		}
 	}
 
+	if outcome == ejv1.JobFailed {
+		return r.expireFailedJob(ctx, &ej, instance)
+	}
+
 	// Delete Job if it succeeded
-	if instance.Status.Succeeded == 1 {
+	if outcome == ejv1.JobSucceeded {
 		ctxlog.WithEvent(&ej, "DeletingJob").Infof(ctx, "Deleting succeeded job '%s'", instance.Name)
 		err = r.client.Delete(ctx, instance)
 		if err != nil {
@@ -122,15 +144,18 @@ func (r *ReconcileJob) Reconcile(request reconcile.Request) (reconcile.Result, e
 
 		if d, ok := instance.Spec.Template.Labels["delete"]; ok {
 			if d == "pod" {
-				pod, err := r.jobPod(ctx, instance.Name, instance.GetNamespace())
+				pods, err := r.jobPods(ctx, instance.Name, instance.GetNamespace())
 				if err != nil {
-					ctxlog.WithEvent(instance, "NotFoundError").Errorf(ctx, "Cannot find job's pod: '%s'", err)
+					ctxlog.WithEvent(instance, "NotFoundError").Errorf(ctx, "Cannot find job's pods: '%s'", err)
 					return reconcile.Result{}, nil
 				}
-				ctxlog.WithEvent(&ej, "DeletingJobsPod").Infof(ctx, "Deleting succeeded job's pod '%s'", pod.Name)
-				err = r.client.Delete(ctx, pod)
-				if err != nil {
-					ctxlog.WithEvent(instance, "DeleteError").Errorf(ctx, "Cannot delete succeeded job's pod: '%s'", err)
+				for i := range pods {
+					pod := &pods[i]
+					ctxlog.WithEvent(&ej, "DeletingJobsPod").Infof(ctx, "Deleting succeeded job's pod '%s'", pod.Name)
+					err = r.client.Delete(ctx, pod)
+					if err != nil {
+						ctxlog.WithEvent(instance, "DeleteError").Errorf(ctx, "Cannot delete succeeded job's pod: '%s'", err)
+					}
 				}
 			}
 		}
@@ -139,8 +164,187 @@ func (r *ReconcileJob) Reconcile(request reconcile.Request) (reconcile.Result, e
 	return reconcile.Result{}, nil
 }
 
-// jobPod gets the job's pod. Only single-pod jobs are supported when persisting the output, so we just get the first one.
-func (r *ReconcileJob) jobPod(ctx context.Context, name string, namespace string) (*corev1.Pod, error) {
+// finishRun persists the output of the finished job and records the run in the status
+// of the extended job, before it triggers the extended jobs depending on it
+func (r *ReconcileJob) finishRun(ctx context.Context, ej *ejv1.ExtendedJob, job *batchv1.Job, outcome ejv1.JobOutcome) error {
+	pods, err := r.jobPods(ctx, job.Name, job.GetNamespace())
+	if err != nil {
+		ctxlog.WithEvent(job, "NotFoundError").Errorf(ctx, "Cannot find job's pods: '%s'", err)
+		return err
+	}
+
+	failedPods := []string{}
+	for _, pod := range podsInPhase(pods, corev1.PodFailed) {
+		failedPods = append(failedPods, pod.Name)
+	}
+	succeededPods := podsInPhase(pods, corev1.PodSucceeded)
+	if outcome == ejv1.JobFailed && len(succeededPods) > 0 {
+		ctxlog.WithEvent(ej, "PartialFailure").Infof(ctx, "Job '%s' failed partially, %d pods succeeded, failed pods: %s", job.Name, len(succeededPods), strings.Join(failedPods, ", "))
+	}
+
+	var outputs []string
+	if !reflect.DeepEqual(ejv1.Output{}, ej.Spec.Output) && ej.Spec.Output != nil {
+		if outcome == ejv1.JobSucceeded || ej.Spec.Output.WriteOnFailure {
+			ctxlog.WithEvent(ej, "PersistingOutput").Infof(ctx, "Persisting output of job '%s'", job.Name)
+			outputs, err = r.persistOutput(ctx, job, ej, outputPods(ej, pods, outcome))
+			if err != nil {
+				ctxlog.WithEvent(job, "PersistOutputError").Errorf(ctx, "Could not persist output: '%s'", err)
+				return err
+			}
+		} else {
+			ctxlog.WithEvent(ej, "FailedPersistingOutput").Infof(ctx, "Will not persist output of job '%s' because it failed", job.Name)
+		}
+	}
+
+	completionTime := metav1.Now()
+	if t := finishTime(job); t != nil {
+		completionTime = *t
+	}
+	run := ejv1.Run{
+		JobName:        job.Name,
+		StartTime:      job.Status.StartTime,
+		CompletionTime: &completionTime,
+		Result:         ejv1.RunResult(outcome),
+		Reason:         job.GetAnnotations()[ejv1.AnnotationTriggerReason],
+		Pod:            job.GetAnnotations()[ejv1.AnnotationTriggerPod],
+		Outputs:        outputs,
+		RunID:          job.GetAnnotations()[ejv1.AnnotationRunID],
+	}
+	if len(failedPods) > 0 {
+		run.FailedPods = failedPods
+	}
+
+	limit := ejv1.DefaultRunHistoryLimit
+	if ej.Spec.RunHistoryLimit != nil {
+		limit = int(*ej.Spec.RunHistoryLimit)
+	}
+	// The run is needed by clients waiting for it, e.g. the errand runner
+	if limit < 1 {
+		limit = 1
+	}
+	ej.Status.Runs = append([]ejv1.Run{run}, ej.Status.Runs...)
+	if len(ej.Status.Runs) > limit {
+		ej.Status.Runs = ej.Status.Runs[:limit]
+	}
+	err = r.client.Update(ctx, ej)
+	if err != nil {
+		ctxlog.WithEvent(ej, "UpdateError").Errorf(ctx, "Failed to record run of job '%s': %s", job.Name, err)
+		return err
+	}
+
+	metrics.ExtendedJobRuns.WithLabelValues(ej.GetNamespace(), ej.GetName(), string(outcome)).Inc()
+
+	return r.triggerDependents(ctx, ej, job, outcome)
+}
+
+// markRunRecorded annotates the job with the time its run was recorded
+func (r *ReconcileJob) markRunRecorded(ctx context.Context, ej *ejv1.ExtendedJob, job *batchv1.Job) error {
+	completionTime := metav1.Now()
+	if run := findRun(ej, job.Name); run != nil && run.CompletionTime != nil {
+		completionTime = *run.CompletionTime
+	}
+
+	annotations := job.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[ejv1.AnnotationRunRecorded] = completionTime.UTC().Format(time.RFC3339)
+	job.SetAnnotations(annotations)
+
+	err := r.client.Update(ctx, job)
+	if err != nil {
+		ctxlog.WithEvent(job, "UpdateError").Errorf(ctx, "Failed to mark the run of job '%s' as recorded: %s", job.Name, err)
+		return err
+	}
+	return nil
+}
+
+// expireFailedJob deletes the failed job with its pods once its TTL expired. Failed jobs
+// are kept for debugging until then.
+func (r *ReconcileJob) expireFailedJob(ctx context.Context, ej *ejv1.ExtendedJob, job *batchv1.Job) (reconcile.Result, error) {
+	if ej.Spec.FailedJobTTLSeconds == nil {
+		return reconcile.Result{}, nil
+	}
+
+	finishedAt, err := time.Parse(time.RFC3339, job.GetAnnotations()[ejv1.AnnotationRunRecorded])
+	if err != nil {
+		finishedAt = time.Time{}
+		if run := findRun(ej, job.Name); run != nil && run.CompletionTime != nil {
+			finishedAt = run.CompletionTime.Time
+		}
+	}
+	remaining := time.Until(finishedAt.Add(time.Duration(*ej.Spec.FailedJobTTLSeconds) * time.Second))
+	if remaining > 0 {
+		ctxlog.Debugf(ctx, "Keeping failed job '%s' for %s", job.Name, remaining)
+		return reconcile.Result{RequeueAfter: remaining}, nil
+	}
+
+	ctxlog.WithEvent(ej, "DeletingJob").Infof(ctx, "Deleting failed job '%s', its TTL expired", job.Name)
+	err = r.client.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if err != nil && !apierrors.IsNotFound(err) {
+		ctxlog.WithEvent(job, "DeleteError").Errorf(ctx, "Cannot delete failed job: '%s'", err)
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
+
+// findRun returns the run of the job from the history of the extended job, or nil
+func findRun(ej *ejv1.ExtendedJob, jobName string) *ejv1.Run {
+	for i := range ej.Status.Runs {
+		if ej.Status.Runs[i].JobName == jobName {
+			return &ej.Status.Runs[i]
+		}
+	}
+	return nil
+}
+
+// triggerDependents starts jobs for the extended jobs with a job completion trigger on the
+// finished job's extended job. Every job triggers its dependents only once.
+func (r *ReconcileJob) triggerDependents(ctx context.Context, ej *ejv1.ExtendedJob, job *batchv1.Job, outcome ejv1.JobOutcome) error {
+	eJobs := &ejv1.ExtendedJobList{}
+	err := r.client.List(ctx, client.InNamespace(ej.GetNamespace()), eJobs)
+	if err != nil {
+		return errors.Wrap(err, "listing extended jobs triggered by job completion")
+	}
+
+	key := fmt.Sprintf("extendedjob/%s", ej.GetName())
+	for i := range eJobs.Items {
+		dependent := &eJobs.Items[i]
+		trigger := dependent.Spec.Trigger.Job
+		if dependent.GetNamespace() != ej.GetNamespace() || dependent.Spec.Trigger.Strategy != ejv1.TriggerJobCompletion || trigger == nil || trigger.Name != ej.GetName() {
+			continue
+		}
+		if trigger.When != ejv1.JobFinished && trigger.When != outcome {
+			continue
+		}
+		if dependent.Status.Triggers[key] == job.GetName() {
+			ctxlog.Debugf(ctx, "Skip '%s': already triggered by job '%s'", dependent.Name, job.GetName())
+			continue
+		}
+
+		err = startJob(ctx, r.client, r.scheme, controllerutil.SetControllerReference, r.versionedSecretStore, dependent, fmt.Sprintf("job '%s' of '%s' %s", job.GetName(), ej.GetName(), outcome))
+		if err != nil {
+			ctxlog.WithEvent(dependent, "CreateJobError").Errorf(ctx, "Failed to create job for '%s' via job '%s' of '%s': %s", dependent.Name, job.GetName(), ej.GetName(), err)
+			return err
+		}
+		ctxlog.WithEvent(dependent, "CreateJob").Infof(ctx, "Created job for '%s' via job '%s' of '%s', which %s", dependent.Name, job.GetName(), ej.GetName(), outcome)
+
+		if dependent.Status.Triggers == nil {
+			dependent.Status.Triggers = map[string]string{}
+		}
+		dependent.Status.Triggers[key] = job.GetName()
+		err = r.client.Update(ctx, dependent)
+		if err != nil {
+			ctxlog.WithEvent(dependent, "UpdateError").Errorf(ctx, "Failed to record trigger of '%s': %s", dependent.Name, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// jobPods gets the job's pods, ordered by creation time
+func (r *ReconcileJob) jobPods(ctx context.Context, name string, namespace string) ([]corev1.Pod, error) {
 	selector, err := labels.Parse("job-name=" + name)
 	if err != nil {
 		return nil, err
@@ -157,67 +361,223 @@ func (r *ReconcileJob) jobPod(ctx context.Context, name string, namespace string
 	if err != nil {
 		return nil, errors.Wrap(err, "listing job's pods")
 	}
-	if len(list.Items) == 0 {
-		return nil, errors.Errorf("job does not own any pods?")
+
+	pods := list.Items
+	sort.SliceStable(pods, func(i, j int) bool {
+		ti, tj := pods[i].CreationTimestamp, pods[j].CreationTimestamp
+		if !ti.Equal(&tj) {
+			return ti.Before(&tj)
+		}
+		return pods[i].Name < pods[j].Name
+	})
+	return pods, nil
+}
+
+// isMultiPod returns true if the jobs of the extended job run more than one pod
+func isMultiPod(ej *ejv1.ExtendedJob) bool {
+	return (ej.Spec.Parallelism != nil && *ej.Spec.Parallelism > 1) ||
+		(ej.Spec.Completions != nil && *ej.Spec.Completions > 1)
+}
+
+// podsInPhase returns the pods which are in the given phase
+func podsInPhase(pods []corev1.Pod, phase corev1.PodPhase) []corev1.Pod {
+	result := []corev1.Pod{}
+	for _, pod := range pods {
+		if pod.Status.Phase == phase {
+			result = append(result, pod)
+		}
 	}
-	return &list.Items[0], nil
+	return result
 }
 
-func (r *ReconcileJob) persistOutput(ctx context.Context, instance *batchv1.Job, conf *ejv1.Output) error {
-	pod, err := r.jobPod(ctx, instance.GetName(), instance.GetNamespace())
-	if err != nil {
-		return errors.Wrap(err, "failed to persist output")
+// outputPods returns the pods to collect the output from. Single-pod jobs use their last
+// succeeded pod, or their last pod if none succeeded. Multi-pod jobs use every succeeded
+// pod and, if the job failed, the failed pods as well.
+func outputPods(ej *ejv1.ExtendedJob, pods []corev1.Pod, outcome ejv1.JobOutcome) []corev1.Pod {
+	if !isMultiPod(ej) {
+		succeeded := podsInPhase(pods, corev1.PodSucceeded)
+		if len(succeeded) > 0 {
+			return succeeded[len(succeeded)-1:]
+		}
+		if len(pods) > 0 {
+			return pods[len(pods)-1:]
+		}
+		return pods
 	}
 
-	// Iterate over the pod's containers and store the output
-	for _, c := range pod.Spec.Containers {
-		result, err := r.podLogGetter.Get(instance.GetNamespace(), pod.Name, c.Name)
+	result := []corev1.Pod{}
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodSucceeded || (outcome == ejv1.JobFailed && pod.Status.Phase == corev1.PodFailed) {
+			result = append(result, pod)
+		}
+	}
+	return result
+}
+
+// persistOutput stores the output of every container and returns the names of the
+// secrets or config maps it was written to. The output of multi-pod jobs is merged
+// into one secret or config map per container.
+func (r *ReconcileJob) persistOutput(ctx context.Context, instance *batchv1.Job, ej *ejv1.ExtendedJob, pods []corev1.Pod) ([]string, error) {
+	conf := ej.Spec.Output
+	if len(pods) == 0 {
+		return nil, errors.Errorf("failed to persist output: job has no pods to collect the output from")
+	}
+
+	// Collect the output of every container of every pod, in the order of the pods
+	containers := []string{}
+	podOutputs := map[string][]PodOutput{}
+	for _, pod := range pods {
+		output, err := r.podOutput(instance, conf, &pod)
 		if err != nil {
-			return errors.Wrap(err, "getting pod output")
+			return nil, errors.Wrapf(err, "failed to persist output of pod '%s'", pod.Name)
+		}
+		for _, c := range output {
+			if _, ok := podOutputs[c.name]; !ok {
+				containers = append(containers, c.name)
+			}
+			podOutputs[c.name] = append(podOutputs[c.name], PodOutput{Pod: pod.Name, Data: c.data})
+		}
+	}
+
+	outputs := []string{}
+	for _, containerName := range containers {
+		data := podOutputs[containerName][0].Data
+		if isMultiPod(ej) {
+			var err error
+			data, err = MergeOutput(conf.Merge, podOutputs[containerName])
+			if err != nil {
+				return nil, errors.Wrapf(err, "merging output of container '%s'", containerName)
+			}
 		}
 
-		var data map[string]string
-		err = json.Unmarshal(result, &data)
+		output, err := r.persistContainerOutput(ctx, instance, conf, containerName, data)
 		if err != nil {
-			return errors.Wrap(err, "invalid output format")
+			return nil, err
 		}
+		outputs = append(outputs, output)
+	}
 
-		// Create secret and persist the output
-		secretName := conf.NamePrefix + c.Name
-		secret := &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      secretName,
-				Namespace: instance.GetNamespace(),
-			},
+	return outputs, nil
+}
+
+// containerOutput is the output of a single container of a pod
+type containerOutput struct {
+	name string
+	data map[string]string
+}
+
+// podOutput reads the output of every container of the pod
+func (r *ReconcileJob) podOutput(instance *batchv1.Job, conf *ejv1.Output, pod *corev1.Pod) ([]containerOutput, error) {
+	outputs := []containerOutput{}
+
+	if usesOutputFiles(conf) {
+		// The collector prints the output files of all containers
+		result, err := r.podLogGetter.Get(instance.GetNamespace(), pod.Name, OutputCollectorName)
+		if err != nil {
+			return nil, errors.Wrap(err, "getting collected output")
 		}
 
-		if conf.Versioned {
-			secretLabels := conf.SecretLabels
-			if secretLabels == nil {
-				secretLabels = map[string]string{}
+		var output map[string]map[string]string
+		err = json.Unmarshal(result, &output)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid collected output")
+		}
+
+		for _, c := range pod.Spec.InitContainers {
+			data, ok := output[c.Name]
+			if !ok {
+				continue
 			}
+			outputs = append(outputs, containerOutput{name: c.Name, data: data})
+		}
+
+		return outputs, nil
+	}
+
+	// Iterate over the pod's containers and read the output
+	for _, c := range pod.Spec.Containers {
+		result, err := r.podLogGetter.Get(instance.GetNamespace(), pod.Name, c.Name)
+		if err != nil {
+			return nil, errors.Wrap(err, "getting pod output")
+		}
 
-			// Use secretName as versioned secret name prefix: <secretName>-v<version>
-			err = r.versionedSecretStore.Create(ctx, instance.GetNamespace(), secretName, data, secretLabels, "created by extendedJob")
+		data, err := parseOutput(conf.OutputType, result)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing output of container '%s'", c.Name)
+		}
+		outputs = append(outputs, containerOutput{name: c.Name, data: data})
+	}
+
+	return outputs, nil
+}
+
+// persistContainerOutput creates or updates the secret or config map holding the output of a container.
+// It returns the name of the secret or config map, which is the latest version for versioned ones.
+func (r *ReconcileJob) persistContainerOutput(ctx context.Context, instance *batchv1.Job, conf *ejv1.Output, containerName string, data map[string]string) (string, error) {
+	name := conf.NamePrefix + containerName
+
+	if conf.Versioned {
+		outputLabels := map[string]string{}
+		for k, v := range conf.SecretLabels {
+			outputLabels[k] = v
+		}
+
+		// Use name as versioned name prefix: <name>-v<version>
+		if conf.Target == ejv1.OutputTargetConfigMap {
+			version, err := r.versionedConfigMapStore.Create(ctx, instance.GetNamespace(), name, data, outputLabels, "created by extendedJob")
 			if err != nil {
-				return errors.Wrap(err, "could not create secret")
+				return "", errors.Wrap(err, "could not create config map")
 			}
-		} else {
-			_, err = controllerutil.CreateOrUpdate(ctx, r.client, secret, func(obj runtime.Object) error {
-				s, ok := obj.(*corev1.Secret)
-				if !ok {
-					return fmt.Errorf("object is not a Secret")
-				}
-				s.SetLabels(conf.SecretLabels)
-				s.StringData = data
-				return nil
-			})
-			if err != nil {
-				return errors.Wrapf(err, "creating or updating Secret '%s'", secret.Name)
+			return version, nil
+		}
+
+		version, err := r.versionedSecretStore.Create(ctx, instance.GetNamespace(), name, data, outputLabels, "created by extendedJob")
+		if err != nil {
+			return "", errors.Wrap(err, "could not create secret")
+		}
+		return version, nil
+	}
+
+	if conf.Target == ejv1.OutputTargetConfigMap {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: instance.GetNamespace(),
+			},
+		}
+		_, err := controllerutil.CreateOrUpdate(ctx, r.client, configMap, func(obj runtime.Object) error {
+			cm, ok := obj.(*corev1.ConfigMap)
+			if !ok {
+				return fmt.Errorf("object is not a ConfigMap")
 			}
+			cm.SetLabels(conf.SecretLabels)
+			cm.Data = data
+			return nil
+		})
+		if err != nil {
+			return "", errors.Wrapf(err, "creating or updating ConfigMap '%s'", configMap.Name)
 		}
+		return name, nil
+	}
 
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: instance.GetNamespace(),
+		},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.client, secret, func(obj runtime.Object) error {
+		s, ok := obj.(*corev1.Secret)
+		if !ok {
+			return fmt.Errorf("object is not a Secret")
+		}
+		s.SetLabels(conf.SecretLabels)
+		s.StringData = data
+		return nil
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "creating or updating Secret '%s'", secret.Name)
 	}
 
-	return nil
+	return name, nil
 }
@@ -2,6 +2,7 @@ package extendedjob_test
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -11,6 +12,7 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
@@ -41,14 +43,21 @@ var _ = Describe("ReconcileExtendedJob", func() {
 		ejob         *ejapi.ExtendedJob
 		job          *batchv1.Job
 		pod          *corev1.Pod
+		pods         []corev1.Pod
+		dependents   []ejapi.ExtendedJob
+		outputs      []runtime.Object
 		env          testing.Catalog
 	)
 
 	BeforeEach(func() {
 		controllers.AddToScheme(scheme.Scheme)
 		manager = &cfakes.FakeManager{}
+		manager.GetSchemeReturns(scheme.Scheme)
 		request = reconcile.Request{NamespacedName: types.NamespacedName{Name: "foo", Namespace: "default"}}
 		_, log = helper.NewTestLogger()
+		dependents = []ejapi.ExtendedJob{}
+		outputs = []runtime.Object{}
+		pods = nil
 
 		client = &cfakes.FakeClient{}
 		client.GetCalls(func(context context.Context, nn types.NamespacedName, object runtime.Object) error {
@@ -59,6 +68,20 @@ var _ = Describe("ReconcileExtendedJob", func() {
 			case *batchv1.Job:
 				job.DeepCopyInto(object.(*batchv1.Job))
 				return nil
+			case *corev1.Secret:
+				for _, o := range outputs {
+					if secret, ok := o.(*corev1.Secret); ok && secret.Name == nn.Name {
+						secret.DeepCopyInto(object.(*corev1.Secret))
+						return nil
+					}
+				}
+			case *corev1.ConfigMap:
+				for _, o := range outputs {
+					if configMap, ok := o.(*corev1.ConfigMap); ok && configMap.Name == nn.Name {
+						configMap.DeepCopyInto(object.(*corev1.ConfigMap))
+						return nil
+					}
+				}
 			}
 			return apierrors.NewNotFound(schema.GroupResource{}, nn.Name)
 		})
@@ -68,10 +91,29 @@ var _ = Describe("ReconcileExtendedJob", func() {
 				list := corev1.PodList{
 					Items: []corev1.Pod{*pod},
 				}
+				if pods != nil {
+					list.Items = pods
+				}
 				list.DeepCopyInto(object.(*corev1.PodList))
 			case *corev1.SecretList:
 				list := corev1.SecretList{}
+				for _, o := range outputs {
+					if secret, ok := o.(*corev1.Secret); ok {
+						list.Items = append(list.Items, *secret)
+					}
+				}
 				list.DeepCopyInto(object.(*corev1.SecretList))
+			case *corev1.ConfigMapList:
+				list := corev1.ConfigMapList{}
+				for _, o := range outputs {
+					if configMap, ok := o.(*corev1.ConfigMap); ok {
+						list.Items = append(list.Items, *configMap)
+					}
+				}
+				list.DeepCopyInto(object.(*corev1.ConfigMapList))
+			case *ejapi.ExtendedJobList:
+				list := ejapi.ExtendedJobList{Items: dependents}
+				list.DeepCopyInto(object.(*ejapi.ExtendedJobList))
 			}
 			return nil
 		})
@@ -87,6 +129,12 @@ var _ = Describe("ReconcileExtendedJob", func() {
 		ejob, job, pod = env.DefaultExtendedJobWithSucceededJob("foo")
 	})
 
+	updatedEJob := func() *ejapi.ExtendedJob {
+		Expect(client.UpdateCallCount()).To(BeNumerically(">", 0))
+		_, object := client.UpdateArgsForCall(0)
+		return object.(*ejapi.ExtendedJob)
+	}
+
 	Context("With a succeeded Job", func() {
 		It("deletes the job immediately", func() {
 			_, err := reconciler.Reconcile(request)
@@ -94,6 +142,78 @@ var _ = Describe("ReconcileExtendedJob", func() {
 			Expect(client.DeleteCallCount()).To(Equal(1))
 		})
 
+		It("deletes all pods of the job if requested", func() {
+			job.Spec.Template.Labels = map[string]string{"delete": "pod"}
+			second := pod.DeepCopy()
+			second.Name = "foo-pod-2"
+			pods = []corev1.Pod{*pod, *second}
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.DeleteCallCount()).To(Equal(3))
+		})
+
+		It("records the run in the status of the extended job", func() {
+			job.Annotations = map[string]string{
+				ejapi.AnnotationTriggerReason: "pod ready",
+				ejapi.AnnotationTriggerPod:    "foo-pod",
+				ejapi.AnnotationRunID:         "run-1",
+			}
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+
+			runs := updatedEJob().Status.Runs
+			Expect(runs).To(HaveLen(1))
+			Expect(runs[0].JobName).To(Equal("foo-job"))
+			Expect(runs[0].Result).To(Equal(ejapi.RunSucceeded))
+			Expect(runs[0].Reason).To(Equal("pod ready"))
+			Expect(runs[0].Pod).To(Equal("foo-pod"))
+			Expect(runs[0].RunID).To(Equal("run-1"))
+			Expect(runs[0].CompletionTime).ToNot(BeNil())
+		})
+
+		It("keeps only the latest runs", func() {
+			limit := int32(2)
+			ejob.Spec.RunHistoryLimit = &limit
+			ejob.Status.Runs = []ejapi.Run{{JobName: "foo-2"}, {JobName: "foo-1"}}
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+
+			runs := updatedEJob().Status.Runs
+			Expect(runs).To(HaveLen(2))
+			Expect(runs[0].JobName).To(Equal("foo-job"))
+			Expect(runs[1].JobName).To(Equal("foo-2"))
+		})
+
+		It("marks the job once its run is recorded", func() {
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.UpdateCallCount()).To(Equal(2))
+			_, object := client.UpdateArgsForCall(1)
+			Expect(object.(*batchv1.Job).Annotations).To(HaveKey(ejapi.AnnotationRunRecorded))
+		})
+
+		It("handles a run only once", func() {
+			ejob.Spec.Output = &ejapi.Output{NamePrefix: "foo-"}
+			ejob.Status.Runs = []ejapi.Run{{JobName: "foo-job"}}
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.CreateCallCount()).To(Equal(0))
+			Expect(client.UpdateCallCount()).To(Equal(1))
+			_, object := client.UpdateArgsForCall(0)
+			Expect(object).To(BeAssignableToTypeOf(&batchv1.Job{}))
+			Expect(client.DeleteCallCount()).To(Equal(1))
+		})
+
+		It("handles a run only once even if it was trimmed from the history", func() {
+			ejob.Spec.Output = &ejapi.Output{NamePrefix: "foo-"}
+			job.Annotations = map[string]string{ejapi.AnnotationRunRecorded: time.Now().UTC().Format(time.RFC3339)}
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.CreateCallCount()).To(Equal(0))
+			Expect(client.UpdateCallCount()).To(Equal(0))
+			Expect(client.DeleteCallCount()).To(Equal(1))
+		})
+
 		Context("when output persistence is not configured", func() {
 			It("does not persist output", func() {
 				result, err := reconciler.Reconcile(request)
@@ -145,11 +265,233 @@ var _ = Describe("ReconcileExtendedJob", func() {
 					Expect(secret.Labels).To(HaveKeyWithValue(versionedsecretstore.LabelSecretKind, "versionedSecret"))
 					Expect(secret.Labels).To(HaveKeyWithValue(versionedsecretstore.LabelVersion, "1"))
 					Expect(secretName).To(Equal("foo-busybox-v1"))
+					outputs = append(outputs, secret)
+					return nil
+				})
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.CreateCallCount()).To(Equal(1))
+				Expect(updatedEJob().Status.Runs[0].Outputs).To(ConsistOf("foo-busybox-v1"))
+			})
+
+			It("records the created version even if the cache does not know it yet", func() {
+				ejob.Spec.Output.Versioned = true
+				client.CreateCalls(func(context context.Context, object runtime.Object) error {
+					return nil
+				})
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.CreateCallCount()).To(Equal(1))
+				Expect(updatedEJob().Status.Runs[0].Outputs).To(ConsistOf("foo-busybox-v1"))
+			})
+
+			It("persists the output in a config map", func() {
+				ejob.Spec.Output.Target = ejapi.OutputTargetConfigMap
+				client.CreateCalls(func(context context.Context, object runtime.Object) error {
+					configMap := object.(*corev1.ConfigMap)
+					Expect(configMap.GetName()).To(Equal("foo-busybox"))
+					Expect(configMap.Labels).To(HaveKeyWithValue("key", "value"))
+					Expect(configMap.Data).To(Equal(map[string]string{"foo": "bar"}))
+					return nil
+				})
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.CreateCallCount()).To(Equal(1))
+			})
+
+			It("creates a versioned config map and persists the output", func() {
+				ejob.Spec.Output.Target = ejapi.OutputTargetConfigMap
+				ejob.Spec.Output.Versioned = true
+				client.CreateCalls(func(context context.Context, object runtime.Object) error {
+					configMap := object.(*corev1.ConfigMap)
+					Expect(configMap.GetName()).To(Equal("foo-busybox-v1"))
+					Expect(configMap.Labels).To(HaveKeyWithValue("key", "value"))
+					Expect(configMap.Labels).To(HaveKeyWithValue(versionedsecretstore.LabelSecretKind, versionedsecretstore.VersionConfigMapKind))
+					Expect(configMap.Labels).To(HaveKeyWithValue(versionedsecretstore.LabelVersion, "1"))
+					Expect(configMap.Data).To(Equal(map[string]string{"foo": "bar"}))
+					outputs = append(outputs, configMap)
+					return nil
+				})
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.CreateCallCount()).To(Equal(1))
+			})
+
+			It("fails if the output is not valid json", func() {
+				podLogGetter.GetReturns([]byte("starting\n{\"foo\": \"bar\"}"), nil)
+				_, err := reconciler.Reconcile(request)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("invalid json output"))
+				Expect(client.CreateCallCount()).To(Equal(0))
+			})
+
+			It("persists yaml output", func() {
+				ejob.Spec.Output.OutputType = ejapi.OutputTypeYAML
+				podLogGetter.GetReturns([]byte("foo: bar\ncount: 2\n"), nil)
+				client.CreateCalls(func(context context.Context, object runtime.Object) error {
+					secret := object.(*corev1.Secret)
+					Expect(secret.StringData).To(Equal(map[string]string{"foo": "bar", "count": "2"}))
+					return nil
+				})
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.CreateCallCount()).To(Equal(1))
+			})
+
+			It("persists raw output under a single key", func() {
+				ejob.Spec.Output.OutputType = ejapi.OutputTypeRaw
+				podLogGetter.GetReturns([]byte("line 1\nline 2\n"), nil)
+				client.CreateCalls(func(context context.Context, object runtime.Object) error {
+					secret := object.(*corev1.Secret)
+					Expect(secret.StringData).To(Equal(map[string]string{ejapi.RawOutputKey: "line 1\nline 2\n"}))
+					return nil
+				})
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.CreateCallCount()).To(Equal(1))
+			})
+
+			It("persists the files collected from the output directories", func() {
+				ejob.Spec.Output.Source = ejapi.OutputSourceFiles
+				pod.Spec.InitContainers = pod.Spec.Containers
+				pod.Spec.Containers = []corev1.Container{{Name: ej.OutputCollectorName}}
+				podLogGetter.GetReturns([]byte(`{"busybox": {"foo": "bar", "cert.pem": "---"}}`), nil)
+				client.CreateCalls(func(context context.Context, object runtime.Object) error {
+					secret := object.(*corev1.Secret)
+					Expect(secret.GetName()).To(Equal("foo-busybox"))
+					Expect(secret.StringData).To(Equal(map[string]string{"foo": "bar", "cert.pem": "---"}))
 					return nil
 				})
 				_, err := reconciler.Reconcile(request)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(client.CreateCallCount()).To(Equal(1))
+				_, _, containerName := podLogGetter.GetArgsForCall(0)
+				Expect(containerName).To(Equal(ej.OutputCollectorName))
+			})
+
+			Context("when the job runs several pods", func() {
+				JustBeforeEach(func() {
+					completions := int32(3)
+					ejob.Spec.Completions = &completions
+
+					pods = []corev1.Pod{}
+					for i, phase := range []corev1.PodPhase{corev1.PodSucceeded, corev1.PodFailed, corev1.PodSucceeded} {
+						p := pod.DeepCopy()
+						p.Name = fmt.Sprintf("foo-pod-%d", i)
+						p.CreationTimestamp = metav1.NewTime(time.Now().Add(time.Duration(i) * time.Second))
+						p.Status.Phase = phase
+						pods = append(pods, *p)
+					}
+					podLogGetter.GetCalls(func(namespace string, podName string, container string) ([]byte, error) {
+						return []byte(fmt.Sprintf(`{"name": "%s"}`, podName)), nil
+					})
+				})
+
+				It("merges the output of the succeeded pods into one secret keyed by pod name", func() {
+					client.CreateCalls(func(context context.Context, object runtime.Object) error {
+						secret := object.(*corev1.Secret)
+						Expect(secret.GetName()).To(Equal("foo-busybox"))
+						Expect(secret.StringData).To(Equal(map[string]string{
+							"foo-pod-0.name": "foo-pod-0",
+							"foo-pod-2.name": "foo-pod-2",
+						}))
+						return nil
+					})
+					_, err := reconciler.Reconcile(request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(client.CreateCallCount()).To(Equal(1))
+					Expect(podLogGetter.GetCallCount()).To(Equal(2))
+				})
+
+				It("keeps the keys of the other pods when a failed pod was retried", func() {
+					retried := pods[1].DeepCopy()
+					retried.Name = "foo-pod-3"
+					retried.CreationTimestamp = metav1.NewTime(pods[1].CreationTimestamp.Add(500 * time.Millisecond))
+					retried.Status.Phase = corev1.PodSucceeded
+					pods = []corev1.Pod{pods[0], pods[1], *retried, pods[2]}
+
+					client.CreateCalls(func(context context.Context, object runtime.Object) error {
+						secret := object.(*corev1.Secret)
+						Expect(secret.StringData).To(Equal(map[string]string{
+							"foo-pod-0.name": "foo-pod-0",
+							"foo-pod-3.name": "foo-pod-3",
+							"foo-pod-2.name": "foo-pod-2",
+						}))
+						return nil
+					})
+					_, err := reconciler.Reconcile(request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(client.CreateCallCount()).To(Equal(1))
+					Expect(updatedEJob().Status.Runs[0].FailedPods).To(ConsistOf("foo-pod-1"))
+				})
+
+				It("merges the output using the configured strategy", func() {
+					ejob.Spec.Output.Merge = ejapi.OutputMergeLast
+					client.CreateCalls(func(context context.Context, object runtime.Object) error {
+						secret := object.(*corev1.Secret)
+						Expect(secret.StringData).To(Equal(map[string]string{"name": "foo-pod-2"}))
+						return nil
+					})
+					_, err := reconciler.Reconcile(request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(client.CreateCallCount()).To(Equal(1))
+				})
+
+				It("fails if the pods' output conflicts", func() {
+					ejob.Spec.Output.Merge = ejapi.OutputMergeUnique
+					_, err := reconciler.Reconcile(request)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("different value for key 'name'"))
+					Expect(client.CreateCallCount()).To(Equal(0))
+				})
+
+				It("records the failed pods of the run", func() {
+					_, err := reconciler.Reconcile(request)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(updatedEJob().Status.Runs[0].FailedPods).To(ConsistOf("foo-pod-1"))
+				})
+			})
+		})
+
+		Context("when extended jobs are triggered by its completion", func() {
+			BeforeEach(func() {
+				dependents = []ejapi.ExtendedJob{
+					env.JobCompletionExtendedJob("on-success", "foo", ejapi.JobSucceeded),
+					env.JobCompletionExtendedJob("on-failure", "foo", ejapi.JobFailed),
+					env.JobCompletionExtendedJob("on-finish", "foo", ejapi.JobFinished),
+					env.JobCompletionExtendedJob("other", "bar", ejapi.JobFinished),
+				}
+			})
+
+			It("starts the jobs of the extended jobs waiting for success and records the trigger", func() {
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(client.CreateCallCount()).To(Equal(2))
+				started := []string{}
+				for i := 0; i < client.CreateCallCount(); i++ {
+					_, object := client.CreateArgsForCall(i)
+					started = append(started, object.(*batchv1.Job).Labels["ejob-name"])
+				}
+				Expect(started).To(ConsistOf("on-success", "on-finish"))
+
+				updated := map[string]*ejapi.ExtendedJob{}
+				for i := 0; i < client.UpdateCallCount(); i++ {
+					_, object := client.UpdateArgsForCall(i)
+					if eJob, ok := object.(*ejapi.ExtendedJob); ok {
+						updated[eJob.Name] = eJob
+					}
+				}
+				Expect(updated).To(HaveKey("on-success"))
+				Expect(updated["on-success"].Status.Triggers).To(HaveKeyWithValue("extendedjob/foo", "foo-job"))
+				Expect(updated).To(HaveKey("on-finish"))
+			})
+
+			It("doesn't start them again for the same job", func() {
+				dependents[0].Status.Triggers = map[string]string{"extendedjob/foo": "foo-job"}
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.CreateCallCount()).To(Equal(1))
 			})
 		})
 	})
@@ -158,12 +500,100 @@ var _ = Describe("ReconcileExtendedJob", func() {
 		JustBeforeEach(func() {
 			job.Status.Succeeded = 0
 			job.Status.Failed = 1
+			job.Status.Conditions = []batchv1.JobCondition{
+				{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, LastTransitionTime: metav1.Now()},
+			}
 		})
 
 		It("does not delete the job immediately", func() {
 			_, err := reconciler.Reconcile(request)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(client.DeleteCallCount()).To(Equal(0))
+			Expect(updatedEJob().Status.Runs[0].Result).To(Equal(ejapi.RunFailed))
+		})
+
+		It("ignores jobs which are still retrying", func() {
+			job.Status.Active = 1
+			job.Status.Conditions = nil
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.UpdateCallCount()).To(Equal(0))
+			Expect(client.DeleteCallCount()).To(Equal(0))
+		})
+
+		It("ignores jobs waiting for the backoff before retrying a failed pod", func() {
+			job.Status.Conditions = nil
+			_, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.UpdateCallCount()).To(Equal(0))
+			Expect(client.CreateCallCount()).To(Equal(0))
+			Expect(client.DeleteCallCount()).To(Equal(0))
+		})
+
+		Context("when only some pods failed", func() {
+			JustBeforeEach(func() {
+				completions := int32(2)
+				ejob.Spec.Completions = &completions
+				ejob.Spec.Output = &ejapi.Output{
+					NamePrefix:     "foo-",
+					WriteOnFailure: true,
+				}
+
+				succeeded := pod.DeepCopy()
+				succeeded.Name = "foo-pod-0"
+				succeeded.Status.Phase = corev1.PodSucceeded
+				failed := pod.DeepCopy()
+				failed.Name = "foo-pod-1"
+				failed.Status.Phase = corev1.PodFailed
+				pods = []corev1.Pod{*succeeded, *failed}
+			})
+
+			It("reports the failed pods and persists the output of all pods", func() {
+				client.CreateCalls(func(context context.Context, object runtime.Object) error {
+					secret := object.(*corev1.Secret)
+					Expect(secret.StringData).To(Equal(map[string]string{"foo-pod-0.foo": "bar", "foo-pod-1.foo": "bar"}))
+					return nil
+				})
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.CreateCallCount()).To(Equal(1))
+
+				run := updatedEJob().Status.Runs[0]
+				Expect(run.Result).To(Equal(ejapi.RunFailed))
+				Expect(run.FailedPods).To(Equal([]string{"foo-pod-1"}))
+			})
+		})
+
+		Context("when failed jobs have a TTL", func() {
+			JustBeforeEach(func() {
+				ttl := int64(3600)
+				ejob.Spec.FailedJobTTLSeconds = &ttl
+			})
+
+			It("keeps the job until the TTL expires", func() {
+				result, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.DeleteCallCount()).To(Equal(0))
+				Expect(result.RequeueAfter).To(BeNumerically("~", time.Hour, time.Minute))
+			})
+
+			It("deletes the job when the TTL expired", func() {
+				finished := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+				ejob.Status.Runs = []ejapi.Run{{JobName: "foo-job", CompletionTime: &finished}}
+				result, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.DeleteCallCount()).To(Equal(1))
+				Expect(result.RequeueAfter).To(BeZero())
+			})
+
+			It("keeps a job whose run was trimmed from the history without recording it again", func() {
+				job.Annotations = map[string]string{ejapi.AnnotationRunRecorded: time.Now().Add(-30 * time.Minute).UTC().Format(time.RFC3339)}
+				result, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.UpdateCallCount()).To(Equal(0))
+				Expect(client.DeleteCallCount()).To(Equal(0))
+				Expect(result.RequeueAfter).To(BeNumerically("~", 30*time.Minute, time.Minute))
+			})
 		})
 
 		Context("when WriteOnFailure is not set", func() {
@@ -196,5 +626,22 @@ var _ = Describe("ReconcileExtendedJob", func() {
 				Expect(reconcile.Result{}).To(Equal(result))
 			})
 		})
+
+		Context("when extended jobs are triggered by its completion", func() {
+			BeforeEach(func() {
+				dependents = []ejapi.ExtendedJob{
+					env.JobCompletionExtendedJob("on-success", "foo", ejapi.JobSucceeded),
+					env.JobCompletionExtendedJob("on-failure", "foo", ejapi.JobFailed),
+				}
+			})
+
+			It("starts the jobs of the extended jobs waiting for failure", func() {
+				_, err := reconciler.Reconcile(request)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(client.CreateCallCount()).To(Equal(1))
+				_, object := client.CreateArgsForCall(0)
+				Expect(object.(*batchv1.Job).Labels).To(HaveKeyWithValue("ejob-name", "on-failure"))
+			})
+		})
 	})
 })
@@ -0,0 +1,189 @@
+package extendedjob
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+
+	"code.cloudfoundry.org/cf-operator/pkg/bosh/manifest"
+	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+)
+
+const (
+	// OutputDir is where the containers of jobs with the files output source write their output to
+	OutputDir = "/mnt/output"
+	// OutputDirEnvName is the environment variable pointing the containers to their output directory
+	OutputDirEnvName = "EXTENDEDJOB_OUTPUT_DIR"
+	// OutputCollectorName is the name of the container collecting the output files
+	OutputCollectorName = "output-collector"
+
+	outputVolumeName = "ejob-output"
+)
+
+// usesOutputFiles returns true if the job's output is collected from files
+func usesOutputFiles(output *ejv1.Output) bool {
+	return output != nil && output.Source == ejv1.OutputSourceFiles
+}
+
+// addOutputCollector prepares the pod template of a job for collecting the output files.
+// Every container gets its own directory on a shared volume. The container becomes an init
+// container and the collector runs once it succeeded, which is why the validator only
+// allows a single container. The collector prints the files as JSON, so the output can be
+// read from its log without being mixed up with other log lines of the container.
+func addOutputCollector(template *corev1.PodTemplateSpec) {
+	spec := &template.Spec
+
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name:         outputVolumeName,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	})
+
+	for _, c := range spec.Containers {
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+			Name:      outputVolumeName,
+			MountPath: OutputDir,
+			SubPath:   c.Name,
+		})
+		c.Env = append(c.Env, corev1.EnvVar{Name: OutputDirEnvName, Value: OutputDir})
+		spec.InitContainers = append(spec.InitContainers, c)
+	}
+
+	spec.Containers = []corev1.Container{
+		{
+			Name:            OutputCollectorName,
+			Image:           manifest.GetOperatorDockerImage(),
+			ImagePullPolicy: manifest.DockerImagePullPolicy,
+			Command:         []string{"/bin/sh"},
+			Args:            []string{"-c", `cf-operator util output-collect`},
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      outputVolumeName,
+					MountPath: OutputDir,
+					ReadOnly:  true,
+				},
+			},
+			Env: []corev1.EnvVar{
+				{
+					Name:  "OUTPUT_FILES_DIR",
+					Value: OutputDir,
+				},
+			},
+		},
+	}
+}
+
+// CollectOutputFiles reads the output directories of all containers below dir. It returns
+// the content of the files by file name for every container.
+func CollectOutputFiles(dir string) (map[string]map[string]string, error) {
+	containerDirs, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading output directory '%s'", dir)
+	}
+
+	output := map[string]map[string]string{}
+	for _, containerDir := range containerDirs {
+		if !containerDir.IsDir() {
+			continue
+		}
+
+		files, err := ioutil.ReadDir(filepath.Join(dir, containerDir.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading output directory of container '%s'", containerDir.Name())
+		}
+
+		data := map[string]string{}
+		for _, file := range files {
+			// Skip directories and hidden files
+			if !file.Mode().IsRegular() || strings.HasPrefix(file.Name(), ".") {
+				continue
+			}
+
+			content, err := ioutil.ReadFile(filepath.Join(dir, containerDir.Name(), file.Name()))
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading output file '%s' of container '%s'", file.Name(), containerDir.Name())
+			}
+			data[file.Name()] = string(content)
+		}
+		output[containerDir.Name()] = data
+	}
+
+	return output, nil
+}
+
+// parseOutput converts the output a container wrote to stdout into the data of its secret
+func parseOutput(outputType string, output []byte) (map[string]string, error) {
+	var data map[string]string
+
+	switch outputType {
+	case "", ejv1.OutputTypeJSON:
+		err := json.Unmarshal(output, &data)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid json output")
+		}
+	case ejv1.OutputTypeYAML:
+		err := yaml.Unmarshal(output, &data)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid yaml output")
+		}
+	case ejv1.OutputTypeRaw:
+		data = map[string]string{ejv1.RawOutputKey: string(output)}
+	default:
+		return nil, errors.Errorf("unsupported output type '%s'", outputType)
+	}
+
+	return data, nil
+}
+
+// PodOutput is the output one container wrote in a pod
+type PodOutput struct {
+	Pod  string
+	Data map[string]string
+}
+
+// MergeOutput merges the output one container wrote in every pod of a multi-pod job into
+// the data of a single secret. The outputs are ordered by the creation time of the pods.
+func MergeOutput(merge string, outputs []PodOutput) (map[string]string, error) {
+	data := map[string]string{}
+
+	switch merge {
+	case "", ejv1.OutputMergePod:
+		// Pod names are unique, unlike positions they don't shift when pods are
+		// retried or did not write any output
+		for _, output := range outputs {
+			for k, v := range output.Data {
+				data[fmt.Sprintf("%s.%s", output.Pod, k)] = v
+			}
+		}
+	case ejv1.OutputMergeFirst:
+		for i := len(outputs) - 1; i >= 0; i-- {
+			for k, v := range outputs[i].Data {
+				data[k] = v
+			}
+		}
+	case ejv1.OutputMergeLast:
+		for _, output := range outputs {
+			for k, v := range output.Data {
+				data[k] = v
+			}
+		}
+	case ejv1.OutputMergeUnique:
+		for _, output := range outputs {
+			for k, v := range output.Data {
+				if existing, ok := data[k]; ok && existing != v {
+					return nil, errors.Errorf("pod '%s' wrote a different value for key '%s'", output.Pod, k)
+				}
+				data[k] = v
+			}
+		}
+	default:
+		return nil, errors.Errorf("unsupported merge strategy '%s'", merge)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,103 @@
+package extendedjob_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+	. "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/extendedjob"
+)
+
+var _ = Describe("CollectOutputFiles", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "ejob-output")
+		Expect(err).ToNot(HaveOccurred())
+
+		for container, files := range map[string]map[string]string{
+			"first":  {"password": "secret", "cert.pem": "-----BEGIN CERTIFICATE-----\n"},
+			"second": {".hidden": "ignored"},
+		} {
+			Expect(os.Mkdir(filepath.Join(dir, container), 0755)).To(Succeed())
+			for name, content := range files {
+				Expect(ioutil.WriteFile(filepath.Join(dir, container, name), []byte(content), 0644)).To(Succeed())
+			}
+		}
+		Expect(os.Mkdir(filepath.Join(dir, "first", "subdir"), 0755)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("returns the files of every container", func() {
+		output, err := CollectOutputFiles(dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(output).To(Equal(map[string]map[string]string{
+			"first":  {"password": "secret", "cert.pem": "-----BEGIN CERTIFICATE-----\n"},
+			"second": {},
+		}))
+	})
+
+	It("fails if the directory does not exist", func() {
+		_, err := CollectOutputFiles(filepath.Join(dir, "missing"))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("MergeOutput", func() {
+	var outputs []PodOutput
+
+	BeforeEach(func() {
+		outputs = []PodOutput{
+			{Pod: "job-abc12", Data: map[string]string{"password": "first", "host": "db"}},
+			{Pod: "job-def34", Data: map[string]string{"password": "second", "host": "db"}},
+		}
+	})
+
+	It("prefixes the keys with the name of the pod by default", func() {
+		data, err := MergeOutput("", outputs)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal(map[string]string{
+			"job-abc12.password": "first", "job-abc12.host": "db",
+			"job-def34.password": "second", "job-def34.host": "db",
+		}))
+	})
+
+	It("keeps the keys of a pod if the pods before it wrote no output", func() {
+		data, err := MergeOutput(ejv1.OutputMergePod, outputs[1:])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal(map[string]string{"job-def34.password": "second", "job-def34.host": "db"}))
+	})
+
+	It("keeps the value of the first pod", func() {
+		data, err := MergeOutput(ejv1.OutputMergeFirst, outputs)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal(map[string]string{"password": "first", "host": "db"}))
+	})
+
+	It("keeps the value of the last pod", func() {
+		data, err := MergeOutput(ejv1.OutputMergeLast, outputs)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal(map[string]string{"password": "second", "host": "db"}))
+	})
+
+	It("merges unique values", func() {
+		outputs[1].Data["password"] = "first"
+		data, err := MergeOutput(ejv1.OutputMergeUnique, outputs)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal(map[string]string{"password": "first", "host": "db"}))
+	})
+
+	It("fails if pods write different values for a unique key", func() {
+		_, err := MergeOutput(ejv1.OutputMergeUnique, outputs)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("pod 'job-def34' wrote a different value for key 'password'"))
+	})
+})
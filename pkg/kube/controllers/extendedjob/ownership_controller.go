@@ -18,6 +18,7 @@ import (
 
 	"code.cloudfoundry.org/cf-operator/pkg/kube/apis"
 	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/backoff"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/names"
@@ -34,10 +35,14 @@ type Owner interface {
 
 // AddOwnership creates a new ExtendedJob controller to update ownership on configs for auto errands.
 func AddOwnership(ctx context.Context, config *config.Config, mgr manager.Manager) error {
+	config = config.ForController("ext-job-owner")
 	ctx = ctxlog.NewContextWithRecorder(ctx, "ext-job-owner-reconciler", mgr.GetRecorder("ext-job-owner-recorder"))
 	owner := eowner.NewOwner(mgr.GetClient(), mgr.GetScheme())
 	r := NewOwnershipReconciler(ctx, config, mgr, controllerutil.SetControllerReference, owner)
-	c, err := controller.New("ext-job-owner-controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New("ext-job-owner-controller", mgr, controller.Options{
+		Reconciler:              backoff.NewReconciler(ctx, r, config.BackoffMin, config.BackoffMax),
+		MaxConcurrentReconciles: config.MaxConcurrentReconciles,
+	})
 	if err != nil {
 		return err
 	}
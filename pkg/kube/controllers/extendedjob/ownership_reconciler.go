@@ -12,6 +12,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
 	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
@@ -63,6 +64,7 @@ func (r *OwnershipReconciler) Reconcile(request reconcile.Request) (reconcile.Re
 	// Set the ctx to be Background, as the top-level context for incoming requests.
 	ctx, cancel := context.WithTimeout(r.ctx, r.config.CtxTimeOut)
 	defer cancel()
+	ctx = ctxlog.NewRequestContext(ctx, request.Namespace, request.Name)
 
 	ctxlog.Infof(ctx, "Reconciling EJob '%s' configs ownership", request.NamespacedName)
 	err := r.client.Get(ctx, request.NamespacedName, eJob)
@@ -77,6 +79,9 @@ func (r *OwnershipReconciler) Reconcile(request reconcile.Request) (reconcile.Re
 		ctxlog.Errorf(ctx, "Failed to get EJob '%s': %s", request.NamespacedName, err)
 		return result, err
 	}
+	if deployment, ok := eJob.GetLabels()[bdv1.LabelDeploymentName]; ok {
+		ctx = ctxlog.WithFields(ctx, "deployment", deployment)
+	}
 
 	eJobCopy := eJob.DeepCopy()
 	err = r.versionedSecretStore.UpdateSecretReferences(ctx, eJob.GetNamespace(), &eJob.Spec.Template.Spec)
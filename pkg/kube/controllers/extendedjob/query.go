@@ -29,27 +29,35 @@ func (q *QueryImpl) Match(eJob ejv1.ExtendedJob, pod corev1.Pod) bool {
 	if pod.Name == "" {
 		return false
 	}
-	if eJob.Spec.Trigger.PodState.Selector == nil {
+	return matchSelector(eJob.Spec.Trigger.PodState.Selector, pod.Labels)
+}
+
+// matchSelector returns true if the labels satisfy the requirements and contain the
+// labels of the selector. A missing selector matches all labels.
+func matchSelector(selector *ejv1.Selector, objectLabels map[string]string) bool {
+	if selector == nil {
 		return true
 	}
 
-	podLabelsSet := labels.Set(pod.Labels)
-	matchExpressions := eJob.Spec.Trigger.PodState.Selector.MatchExpressions
-	for _, exp := range matchExpressions {
+	labelsSet := labels.Set(objectLabels)
+	for _, exp := range selector.MatchExpressions {
+		if exp == nil {
+			continue
+		}
 		requirement, err := labels.NewRequirement(exp.Key, exp.Operator, exp.Values)
 		if err != nil {
-			// q.log.Errorf("Error converting requirement '%#v': %s", exp, err)
-		} else if !requirement.Matches(podLabelsSet) {
+			// Requirements are validated when the extended job is created
+			continue
+		}
+		if !requirement.Matches(labelsSet) {
 			return false
 		}
 	}
 
-	// TODO https://github.com/kubernetes/apimachinery/blob/master/pkg/labels/selector.go
-	matchLabels := eJob.Spec.Trigger.PodState.Selector.MatchLabels
-	if labels.AreLabelsInWhiteList(*matchLabels, pod.Labels) {
+	if selector.MatchLabels == nil {
 		return true
 	}
-	return false
+	return labels.SelectorFromSet(*selector.MatchLabels).Matches(labelsSet)
 }
 
 // MatchState checks pod state against state from extended job
@@ -0,0 +1,59 @@
+package extendedjob
+
+import (
+	"context"
+	"reflect"
+
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/backoff"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
+)
+
+// AddSchedule creates a new ExtendedJob controller to run jobs with a schedule trigger
+// periodically
+func AddSchedule(ctx context.Context, config *config.Config, mgr manager.Manager) error {
+	config = config.ForController("ext-job-schedule")
+	f := controllerutil.SetControllerReference
+	ctx = ctxlog.NewContextWithRecorder(ctx, "ext-job-schedule-reconciler", mgr.GetRecorder("ext-job-schedule-recorder"))
+	r := NewScheduleReconciler(ctx, config, mgr, f)
+	c, err := controller.New("ext-job-schedule-controller", mgr, controller.Options{
+		Reconciler:              backoff.NewReconciler(ctx, r, config.BackoffMin, config.BackoffMax),
+		MaxConcurrentReconciles: config.MaxConcurrentReconciles,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Trigger when
+	//  * scheduled jobs are created, or seen for the first time after a restart
+	//  * the trigger of a job changes
+	// The reconciler requeues the job until its next run is due.
+	p := predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			eJob := e.Object.(*ejv1.ExtendedJob)
+			return eJob.Spec.Trigger.Strategy == ejv1.TriggerSchedule
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			o := e.ObjectOld.(*ejv1.ExtendedJob)
+			n := e.ObjectNew.(*ejv1.ExtendedJob)
+			return n.Spec.Trigger.Strategy == ejv1.TriggerSchedule && !reflect.DeepEqual(o.Spec.Trigger, n.Spec.Trigger)
+		},
+	}
+
+	return c.Watch(&source.Kind{Type: &ejv1.ExtendedJob{}}, &handler.EnqueueRequestForObject{}, p)
+}
@@ -0,0 +1,205 @@
+package extendedjob
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
+	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/cron"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/versionedsecretstore"
+)
+
+var _ reconcile.Reconciler = &ScheduleReconciler{}
+
+// NewScheduleReconciler returns a new reconciler for scheduled jobs
+func NewScheduleReconciler(
+	ctx context.Context,
+	config *config.Config,
+	mgr manager.Manager,
+	f setOwnerReferenceFunc,
+) reconcile.Reconciler {
+	versionedSecretStore := versionedsecretstore.NewVersionedSecretStore(mgr.GetClient())
+
+	return &ScheduleReconciler{
+		ctx:                  ctx,
+		client:               mgr.GetClient(),
+		config:               config,
+		scheme:               mgr.GetScheme(),
+		setOwnerReference:    f,
+		versionedSecretStore: versionedSecretStore,
+	}
+}
+
+// ScheduleReconciler implements the Reconciler interface
+type ScheduleReconciler struct {
+	ctx                  context.Context
+	client               client.Client
+	config               *config.Config
+	scheme               *runtime.Scheme
+	setOwnerReference    setOwnerReferenceFunc
+	versionedSecretStore versionedsecretstore.VersionedSecretStore
+}
+
+// Reconcile starts a job for extended jobs with a schedule trigger, if a run is due. It
+// requeues the extended job until its next run.
+func (r *ScheduleReconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	eJob := &ejv1.ExtendedJob{}
+
+	// Set the ctx to be Background, as the top-level context for incoming requests.
+	ctx, cancel := context.WithTimeout(r.ctx, r.config.CtxTimeOut)
+	defer cancel()
+	ctx = ctxlog.NewRequestContext(ctx, request.Namespace, request.Name)
+
+	ctxlog.Info(ctx, "Reconciling scheduled job ", request.NamespacedName)
+	err := r.client.Get(ctx, request.NamespacedName, eJob)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// do not requeue, extended job is probably deleted
+			ctxlog.Infof(ctx, "Failed to find extended job '%s', not retrying: %s", request.NamespacedName, err)
+			return reconcile.Result{}, nil
+		}
+		ctxlog.Errorf(ctx, "Failed to get the extended job '%s': %s", request.NamespacedName, err)
+		return reconcile.Result{}, err
+	}
+	if deployment, ok := eJob.GetLabels()[bdv1.LabelDeploymentName]; ok {
+		ctx = ctxlog.WithFields(ctx, "deployment", deployment)
+	}
+
+	trigger := eJob.Spec.Trigger.Schedule
+	if eJob.Spec.Trigger.Strategy != ejv1.TriggerSchedule || trigger == nil {
+		ctxlog.Debugf(ctx, "Skip '%s': it has no schedule trigger", eJob.Name)
+		return reconcile.Result{}, nil
+	}
+
+	// The schedule is validated when the extended job is created, retrying won't help
+	schedule, err := cron.Parse(trigger.Cron)
+	if err != nil {
+		ctxlog.WithEvent(eJob, "InvalidSchedule").Errorf(ctx, "Invalid schedule of '%s': %s", eJob.Name, err)
+		return reconcile.Result{}, nil
+	}
+	location, err := time.LoadLocation(trigger.TimeZone)
+	if err != nil {
+		ctxlog.WithEvent(eJob, "InvalidSchedule").Errorf(ctx, "Invalid time zone of '%s': %s", eJob.Name, err)
+		return reconcile.Result{}, nil
+	}
+
+	now := time.Now().In(location)
+	scheduledTime := mostRecentRun(schedule, earliestRun(eJob, now), now)
+	if !scheduledTime.IsZero() {
+		err = r.run(ctx, eJob, scheduledTime)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	next := schedule.Next(now)
+	if next.IsZero() {
+		ctxlog.WithEvent(eJob, "InvalidSchedule").Infof(ctx, "Schedule '%s' of '%s' is never due", trigger.Cron, eJob.Name)
+		return reconcile.Result{}, nil
+	}
+	ctxlog.Debugf(ctx, "Next run of '%s' is at %s", eJob.Name, next)
+
+	return reconcile.Result{RequeueAfter: next.Sub(now)}, nil
+}
+
+// run starts a job for the run scheduled at the given time, applying the concurrency policy
+func (r *ScheduleReconciler) run(ctx context.Context, eJob *ejv1.ExtendedJob, scheduledTime time.Time) error {
+	running, err := r.runningJobs(ctx, eJob)
+	if err != nil {
+		return err
+	}
+
+	skip := false
+	if len(running) > 0 {
+		switch eJob.Spec.Trigger.Schedule.ConcurrencyPolicy {
+		case ejv1.ForbidConcurrent:
+			ctxlog.WithEvent(eJob, "SkipSchedule").Infof(ctx, "Skip run of '%s' scheduled at %s: a job is still running", eJob.Name, scheduledTime)
+			skip = true
+		case ejv1.ReplaceConcurrent:
+			for i := range running {
+				ctxlog.WithEvent(eJob, "ReplaceJob").Infof(ctx, "Deleting running job '%s' of '%s' for the run scheduled at %s", running[i].Name, eJob.Name, scheduledTime)
+				err := r.client.Delete(ctx, &running[i], client.PropagationPolicy(metav1.DeletePropagationBackground))
+				if err != nil && !apierrors.IsNotFound(err) {
+					return errors.Wrapf(err, "could not delete running job '%s'", running[i].Name)
+				}
+			}
+		}
+	}
+
+	if !skip {
+		err = startJob(ctx, r.client, r.scheme, r.setOwnerReference, r.versionedSecretStore, eJob, fmt.Sprintf("schedule %s", scheduledTime.Format(time.RFC3339)))
+		if err != nil {
+			ctxlog.WithEvent(eJob, "CreateJobError").Errorf(ctx, "Failed to create job '%s': %s", eJob.Name, err)
+			return err
+		}
+		ctxlog.WithEvent(eJob, "CreateJob").Infof(ctx, "Created job for '%s' scheduled at %s", eJob.Name, scheduledTime)
+	}
+
+	eJob.Status.LastScheduleTime = &metav1.Time{Time: scheduledTime}
+	err = r.client.Update(ctx, eJob)
+	if err != nil {
+		ctxlog.WithEvent(eJob, "UpdateError").Errorf(ctx, "Failed to record the last schedule time of '%s': %s", eJob.Name, err)
+		return err
+	}
+
+	return nil
+}
+
+// runningJobs returns the jobs of the extended job which have not finished yet
+func (r *ScheduleReconciler) runningJobs(ctx context.Context, eJob *ejv1.ExtendedJob) ([]batchv1.Job, error) {
+	jobs := &batchv1.JobList{}
+	err := r.client.List(ctx, client.InNamespace(eJob.Namespace).MatchingLabels(map[string]string{"ejob-name": eJob.Name}), jobs)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list jobs of eJob '%s'", eJob.Name)
+	}
+
+	running := []batchv1.Job{}
+	for _, job := range jobs.Items {
+		if job.GetLabels()["ejob-name"] != eJob.Name || !job.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		if jobOutcome(&job) == "" {
+			running = append(running, job)
+		}
+	}
+	return running, nil
+}
+
+// earliestRun returns the time after which runs are still to be started. Runs missed by
+// more than the starting deadline are not started anymore.
+func earliestRun(eJob *ejv1.ExtendedJob, now time.Time) time.Time {
+	earliest := eJob.CreationTimestamp.Time
+	if eJob.Status.LastScheduleTime != nil {
+		earliest = eJob.Status.LastScheduleTime.Time
+	}
+
+	if deadline := eJob.Spec.Trigger.Schedule.StartingDeadlineSeconds; deadline != nil {
+		if cutoff := now.Add(-time.Duration(*deadline) * time.Second); cutoff.After(earliest) {
+			earliest = cutoff
+		}
+	}
+	return earliest
+}
+
+// mostRecentRun returns the latest time the schedule was due after earliest, or the zero time
+// if it wasn't due. Only the latest of several missed runs is started.
+func mostRecentRun(schedule *cron.Schedule, earliest time.Time, now time.Time) time.Time {
+	var last time.Time
+	for t := schedule.Next(earliest.In(now.Location())); !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+		last = t
+	}
+	return last
+}
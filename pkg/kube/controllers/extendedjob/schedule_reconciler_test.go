@@ -0,0 +1,204 @@
+package extendedjob_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	crc "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
+	. "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/extendedjob"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/fakes"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
+	helper "code.cloudfoundry.org/cf-operator/pkg/testhelper"
+	"code.cloudfoundry.org/cf-operator/testing"
+)
+
+var _ = Describe("ScheduleReconciler", func() {
+	var (
+		env        testing.Catalog
+		mgr        *fakes.FakeManager
+		client     crc.Client
+		reconciler reconcile.Reconciler
+		eJob       ejv1.ExtendedJob
+		objects    []runtime.Object
+	)
+
+	setOwnerReference := func(owner, object metav1.Object, scheme *runtime.Scheme) error {
+		return nil
+	}
+
+	jobs := func() []batchv1.Job {
+		list := &batchv1.JobList{}
+		Expect(client.List(context.Background(), &crc.ListOptions{}, list)).To(Succeed())
+		return list.Items
+	}
+
+	updatedEJob := func() ejv1.ExtendedJob {
+		result := ejv1.ExtendedJob{}
+		Expect(client.Get(context.Background(), types.NamespacedName{Name: eJob.Name, Namespace: eJob.Namespace}, &result)).To(Succeed())
+		return result
+	}
+
+	runningJob := func(name string) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{"extendedjob": "true", "ejob-name": eJob.Name},
+			},
+			Status: batchv1.JobStatus{Active: 1},
+		}
+	}
+
+	// cronFor returns a cron expression which was due the given duration ago
+	cronFor := func(ago time.Duration) string {
+		t := time.Now().UTC().Add(-ago)
+		return fmt.Sprintf("%d %d %d %d *", t.Minute(), t.Hour(), t.Day(), t.Month())
+	}
+
+	BeforeEach(func() {
+		controllers.AddToScheme(scheme.Scheme)
+		mgr = &fakes.FakeManager{}
+		mgr.GetSchemeReturns(scheme.Scheme)
+
+		eJob = env.ScheduledExtendedJob("fake-ejob", cronFor(10*time.Minute))
+		eJob.Namespace = "default"
+		eJob.CreationTimestamp = metav1.NewTime(time.Now().Add(-24 * time.Hour))
+		objects = []runtime.Object{}
+	})
+
+	JustBeforeEach(func() {
+		_, log := helper.NewTestLogger()
+		ctx := ctxlog.NewParentContext(log)
+
+		client = fake.NewFakeClient(append(objects, &eJob)...)
+		mgr.GetClientReturns(client)
+		reconciler = NewScheduleReconciler(ctx, &config.Config{CtxTimeOut: 10 * time.Second}, mgr, setOwnerReference)
+	})
+
+	act := func() (reconcile.Result, error) {
+		return reconciler.Reconcile(reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: eJob.Name, Namespace: eJob.Namespace},
+		})
+	}
+
+	Context("when a run is due", func() {
+		It("creates a job and records the schedule time", func() {
+			result, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+
+			Expect(jobs()).To(HaveLen(1))
+			Expect(jobs()[0].Labels).To(HaveKeyWithValue("ejob-name", eJob.Name))
+			Expect(jobs()[0].Spec.Template.Labels).To(HaveKeyWithValue("ejob-name", eJob.Name))
+
+			lastScheduleTime := updatedEJob().Status.LastScheduleTime
+			Expect(lastScheduleTime).ToNot(BeNil())
+			Expect(lastScheduleTime.Time).To(BeTemporally("~", time.Now().Add(-10*time.Minute), time.Minute))
+		})
+
+		It("doesn't run it again", func() {
+			_, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(jobs()).To(HaveLen(1))
+		})
+	})
+
+	Context("when no run is due", func() {
+		BeforeEach(func() {
+			eJob.CreationTimestamp = metav1.NewTime(time.Now())
+		})
+
+		It("requeues until the next run", func() {
+			result, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(jobs()).To(BeEmpty())
+			Expect(result.RequeueAfter).To(BeNumerically("~", 365*24*time.Hour-10*time.Minute, 24*time.Hour))
+		})
+	})
+
+	Context("when the run was missed by more than the starting deadline", func() {
+		BeforeEach(func() {
+			deadline := int64(60)
+			eJob.Spec.Trigger.Schedule.StartingDeadlineSeconds = &deadline
+		})
+
+		It("skips the run", func() {
+			_, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(jobs()).To(BeEmpty())
+			Expect(updatedEJob().Status.LastScheduleTime).To(BeNil())
+		})
+	})
+
+	Context("when the schedule is evaluated in a time zone", func() {
+		BeforeEach(func() {
+			berlin, err := time.LoadLocation("Europe/Berlin")
+			Expect(err).ToNot(HaveOccurred())
+			t := time.Now().In(berlin).Add(-10 * time.Minute)
+			eJob.Spec.Trigger.Schedule.Cron = fmt.Sprintf("%d %d %d %d *", t.Minute(), t.Hour(), t.Day(), t.Month())
+			eJob.Spec.Trigger.Schedule.TimeZone = "Europe/Berlin"
+		})
+
+		It("runs at the time of the time zone", func() {
+			_, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(jobs()).To(HaveLen(1))
+		})
+	})
+
+	Context("when a job is still running", func() {
+		BeforeEach(func() {
+			objects = append(objects, runningJob("running"))
+		})
+
+		It("starts another job by default", func() {
+			_, err := act()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(jobs()).To(HaveLen(2))
+		})
+
+		Context("and concurrency is forbidden", func() {
+			BeforeEach(func() {
+				eJob.Spec.Trigger.Schedule.ConcurrencyPolicy = ejv1.ForbidConcurrent
+			})
+
+			It("skips the run", func() {
+				_, err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(jobs()).To(HaveLen(1))
+				Expect(jobs()[0].Name).To(Equal("running"))
+				Expect(updatedEJob().Status.LastScheduleTime).ToNot(BeNil())
+			})
+		})
+
+		Context("and running jobs are replaced", func() {
+			BeforeEach(func() {
+				eJob.Spec.Trigger.Schedule.ConcurrencyPolicy = ejv1.ReplaceConcurrent
+			})
+
+			It("deletes the running job and starts a new one", func() {
+				_, err := act()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(jobs()).To(HaveLen(1))
+				Expect(jobs()[0].Name).ToNot(Equal("running"))
+			})
+		})
+	})
+})
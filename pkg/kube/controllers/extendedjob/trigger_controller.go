@@ -12,17 +12,22 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/backoff"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
 )
 
 // AddTrigger creates a new ExtendedJob controller and adds it to the Manager
 func AddTrigger(ctx context.Context, config *config.Config, mgr manager.Manager) error {
+	config = config.ForController("ext-job-trigger")
 	query := NewQuery()
 	f := controllerutil.SetControllerReference
 	ctx = ctxlog.NewContextWithRecorder(ctx, "ext-job-trigger-reconciler", mgr.GetRecorder("ext-job-trigger-recorder"))
 	r := NewTriggerReconciler(ctx, config, mgr, query, f)
-	c, err := controller.New("ext-job-trigger-controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New("ext-job-trigger-controller", mgr, controller.Options{
+		Reconciler:              backoff.NewReconciler(ctx, r, config.BackoffMin, config.BackoffMax),
+		MaxConcurrentReconciles: config.MaxConcurrentReconciles,
+	})
 	if err != nil {
 		return err
 	}
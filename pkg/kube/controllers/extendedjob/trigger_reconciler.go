@@ -65,6 +65,7 @@ func (r *TriggerReconciler) Reconcile(request reconcile.Request) (result reconci
 	// Set the ctx to be Background, as the top-level context for incoming requests.
 	ctx, cancel := context.WithTimeout(r.ctx, r.config.CtxTimeOut)
 	defer cancel()
+	ctx = ctxlog.NewRequestContext(ctx, request.Namespace, request.Name)
 
 	err = r.client.Get(ctx, request.NamespacedName, pod)
 	if err != nil {
@@ -81,11 +82,7 @@ func (r *TriggerReconciler) Reconcile(request reconcile.Request) (result reconci
 
 	podState := InferPodState(*pod)
 	if podState == ejv1.PodStateUnknown {
-		ctxlog.Debugf(ctx,
-			"Failed to determine state %s: %#v",
-			PodStatusString(*pod),
-			pod.Status,
-		)
+		ctxlog.Debugf(ctx, "Failed to determine state of pod '%s': %s", podName, PodStatusString(*pod))
 		return
 	}
 
@@ -105,7 +102,7 @@ func (r *TriggerReconciler) Reconcile(request reconcile.Request) (result reconci
 
 	for _, eJob := range eJobs.Items {
 		if r.query.MatchState(eJob, podState) && r.query.Match(eJob, *pod) {
-			err := r.createJob(ctx, eJob, podName, pod.GetUID())
+			err := r.createJob(ctx, eJob, podName, pod.GetUID(), podState)
 			if err != nil {
 				if apierrors.IsAlreadyExists(err) {
 					ctxlog.Debugf(ctx, "Skip '%s' triggered by pod %s: already running", eJob.Name, podEvent)
@@ -120,7 +117,7 @@ func (r *TriggerReconciler) Reconcile(request reconcile.Request) (result reconci
 	return
 }
 
-func (r *TriggerReconciler) createJob(ctx context.Context, eJob ejv1.ExtendedJob, podName string, podUID types.UID) error {
+func (r *TriggerReconciler) createJob(ctx context.Context, eJob ejv1.ExtendedJob, podName string, podUID types.UID, podState ejv1.PodState) error {
 	template := eJob.Spec.Template.DeepCopy()
 
 	if template.Labels == nil {
@@ -128,6 +125,10 @@ func (r *TriggerReconciler) createJob(ctx context.Context, eJob ejv1.ExtendedJob
 	}
 	template.Labels["ejob-name"] = eJob.Name
 
+	if usesOutputFiles(eJob.Spec.Output) {
+		addOutputCollector(template)
+	}
+
 	name, err := names.JobName(eJob.Name, podName, podUID)
 	if err != nil {
 		return errors.Wrapf(err, "could not generate job name for eJob '%s'", eJob.Name)
@@ -137,8 +138,12 @@ func (r *TriggerReconciler) createJob(ctx context.Context, eJob ejv1.ExtendedJob
 			Name:      name,
 			Namespace: eJob.Namespace,
 			Labels:    map[string]string{"extendedjob": "true"},
+			Annotations: map[string]string{
+				ejv1.AnnotationTriggerReason: fmt.Sprintf("pod %s", podState),
+				ejv1.AnnotationTriggerPod:    podName,
+			},
 		},
-		Spec: batchv1.JobSpec{Template: *template},
+		Spec: jobSpec(eJob, *template),
 	}
 
 	err = r.setOwnerReference(&eJob, job, r.scheme)
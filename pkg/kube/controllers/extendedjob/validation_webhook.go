@@ -0,0 +1,45 @@
+package extendedjob
+
+import (
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/builder"
+
+	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/nslabel"
+)
+
+// AddValidator creates a new hook for validating ExtendedJobs and adds it to the Manager
+func AddValidator(log *zap.SugaredLogger, config *config.Config, mgr manager.Manager, hookServer *webhook.Server) (*admission.Webhook, error) {
+	log.Info("Setting up ExtendedJob validation webhook")
+
+	validatingWebhook, err := builder.NewWebhookBuilder().
+		Path("/validate-extendedjobs").
+		Validating().
+		NamespaceSelector(&metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				nslabel.LabelOperatorNamespace: config.Namespace,
+			},
+		}).
+		ForType(&ejv1.ExtendedJob{}).
+		Handlers(NewValidator(log)).
+		WithManager(mgr).
+		FailurePolicy(admissionregistrationv1beta1.Fail).
+		Build()
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't build a new webhook")
+	}
+
+	err = hookServer.Register(validatingWebhook)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to register the hook with the admission server")
+	}
+
+	return validatingWebhook, nil
+}
@@ -0,0 +1,235 @@
+package extendedjob
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+
+	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/cron"
+)
+
+// Validator rejects invalid ExtendedJob definitions
+type Validator struct {
+	log     *zap.SugaredLogger
+	decoder types.Decoder
+}
+
+// Implement admission.Handler so the controller can handle admission request.
+var _ admission.Handler = &Validator{}
+
+// NewValidator returns a new admission.Handler for ExtendedJobs
+func NewValidator(log *zap.SugaredLogger) admission.Handler {
+	validatorLog := log.Named("extendedjob-validator")
+	validatorLog.Info("Creating a validator for ExtendedJob")
+
+	return &Validator{
+		log: validatorLog,
+	}
+}
+
+// Handle rejects ExtendedJobs the controllers could not act on
+func (v *Validator) Handle(ctx context.Context, req types.Request) types.Response {
+	eJob := &ejv1.ExtendedJob{}
+
+	err := v.decoder.Decode(req, eJob)
+	if err != nil {
+		return admission.ErrorResponse(http.StatusBadRequest, err)
+	}
+
+	v.log.Debug("ExtendedJob validator handler ran for ", eJob.Name)
+
+	err = validate(eJob)
+	if err != nil {
+		v.log.Infof("Rejecting ExtendedJob '%s': %s", eJob.Name, err)
+		return admission.ErrorResponse(http.StatusUnprocessableEntity, err)
+	}
+
+	return admission.ValidationResponse(true, "")
+}
+
+// validate checks trigger and output of the ExtendedJob
+func validate(eJob *ejv1.ExtendedJob) error {
+	switch eJob.Spec.Trigger.Strategy {
+	// An empty strategy is never triggered, like a manual one
+	case "", ejv1.TriggerManual, ejv1.TriggerNow, ejv1.TriggerOnce, ejv1.TriggerDone, ejv1.TriggerPodState:
+	case ejv1.TriggerSchedule:
+		if eJob.Spec.Trigger.Schedule == nil {
+			return fmt.Errorf("trigger strategy 'schedule' requires a schedule")
+		}
+	case ejv1.TriggerConfigChange:
+		if eJob.Spec.Trigger.Config == nil {
+			return fmt.Errorf("trigger strategy 'configchange' requires a config")
+		}
+	case ejv1.TriggerJobCompletion:
+		if eJob.Spec.Trigger.Job == nil {
+			return fmt.Errorf("trigger strategy 'jobcompletion' requires a job")
+		}
+	default:
+		return fmt.Errorf("invalid trigger strategy '%s'", eJob.Spec.Trigger.Strategy)
+	}
+
+	if configTrigger := eJob.Spec.Trigger.Config; configTrigger != nil {
+		switch configTrigger.Kind {
+		case ejv1.ConfigKindSecret, ejv1.ConfigKindConfigMap:
+		default:
+			return fmt.Errorf("invalid config kind '%s' in trigger", configTrigger.Kind)
+		}
+		if configTrigger.Name == "" && configTrigger.Selector == nil {
+			return fmt.Errorf("config trigger requires a name or a selector")
+		}
+		if err := validateSelector(configTrigger.Selector); err != nil {
+			return err
+		}
+	}
+
+	if jobTrigger := eJob.Spec.Trigger.Job; jobTrigger != nil {
+		if jobTrigger.Name == "" {
+			return fmt.Errorf("job completion trigger is missing the name of the extended job")
+		}
+		if jobTrigger.Name == eJob.Name {
+			return fmt.Errorf("job completion trigger must not refer to the extended job itself")
+		}
+		switch jobTrigger.When {
+		case ejv1.JobSucceeded, ejv1.JobFailed, ejv1.JobFinished:
+		default:
+			return fmt.Errorf("invalid job outcome '%s' in trigger", jobTrigger.When)
+		}
+	}
+
+	if schedule := eJob.Spec.Trigger.Schedule; schedule != nil {
+		if _, err := cron.Parse(schedule.Cron); err != nil {
+			return fmt.Errorf("invalid schedule: %s", err)
+		}
+		if _, err := time.LoadLocation(schedule.TimeZone); err != nil {
+			return fmt.Errorf("invalid time zone '%s' in schedule: %s", schedule.TimeZone, err)
+		}
+		if schedule.StartingDeadlineSeconds != nil && *schedule.StartingDeadlineSeconds < 0 {
+			return fmt.Errorf("starting deadline of schedule must not be negative")
+		}
+		switch schedule.ConcurrencyPolicy {
+		case "", ejv1.AllowConcurrent, ejv1.ForbidConcurrent, ejv1.ReplaceConcurrent:
+		default:
+			return fmt.Errorf("invalid concurrency policy '%s' in schedule", schedule.ConcurrencyPolicy)
+		}
+	}
+
+	if podState := eJob.Spec.Trigger.PodState; podState != nil {
+		switch podState.When {
+		case ejv1.PodStateUnknown:
+			return fmt.Errorf("pod state trigger is missing 'when'")
+		case ejv1.PodStateReady, ejv1.PodStateCreated, ejv1.PodStateNotReady, ejv1.PodStateDeleted:
+		default:
+			return fmt.Errorf("invalid pod state '%s' in trigger", podState.When)
+		}
+
+		if err := validateSelector(podState.Selector); err != nil {
+			return err
+		}
+	}
+
+	if eJob.Spec.BackoffLimit != nil && *eJob.Spec.BackoffLimit < 0 {
+		return fmt.Errorf("backoff limit must not be negative")
+	}
+	if eJob.Spec.ActiveDeadlineSeconds != nil && *eJob.Spec.ActiveDeadlineSeconds <= 0 {
+		return fmt.Errorf("active deadline must be positive")
+	}
+	if eJob.Spec.FailedJobTTLSeconds != nil && *eJob.Spec.FailedJobTTLSeconds < 0 {
+		return fmt.Errorf("TTL of failed jobs must not be negative")
+	}
+	// Runs are recorded so every job is handled only once
+	if eJob.Spec.RunHistoryLimit != nil && *eJob.Spec.RunHistoryLimit < 1 {
+		return fmt.Errorf("run history limit must be at least 1")
+	}
+	if eJob.Spec.Parallelism != nil && *eJob.Spec.Parallelism < 1 {
+		return fmt.Errorf("parallelism must be at least 1")
+	}
+	if eJob.Spec.Completions != nil && *eJob.Spec.Completions < 1 {
+		return fmt.Errorf("completions must be at least 1")
+	}
+
+	overrides, err := runOverrides(eJob)
+	if err != nil {
+		return err
+	}
+	if err := applyOverrides(eJob.Spec.Template.DeepCopy(), overrides); err != nil {
+		return err
+	}
+
+	if output := eJob.Spec.Output; output != nil {
+		switch output.OutputType {
+		case "", ejv1.OutputTypeJSON, ejv1.OutputTypeYAML, ejv1.OutputTypeRaw:
+		default:
+			return fmt.Errorf("unsupported output type '%s'", output.OutputType)
+		}
+
+		switch output.Source {
+		case "", ejv1.OutputSourceStdout:
+		case ejv1.OutputSourceFiles:
+			if output.OutputType != "" {
+				return fmt.Errorf("output type '%s' cannot be used with the files output source, every file is stored as one key", output.OutputType)
+			}
+			// The container is run as an init container before the output collector,
+			// several of them would no longer run in parallel
+			if len(eJob.Spec.Template.Spec.Containers) > 1 {
+				return fmt.Errorf("the files output source only supports a single container, the template has %d", len(eJob.Spec.Template.Spec.Containers))
+			}
+			for _, c := range eJob.Spec.Template.Spec.Containers {
+				if c.Name == OutputCollectorName {
+					return fmt.Errorf("container name '%s' is reserved for the output collector", OutputCollectorName)
+				}
+			}
+		default:
+			return fmt.Errorf("unsupported output source '%s'", output.Source)
+		}
+
+		switch output.Target {
+		case "", ejv1.OutputTargetSecret, ejv1.OutputTargetConfigMap:
+		default:
+			return fmt.Errorf("unsupported output target '%s'", output.Target)
+		}
+
+		switch output.Merge {
+		case "", ejv1.OutputMergePod, ejv1.OutputMergeFirst, ejv1.OutputMergeLast, ejv1.OutputMergeUnique:
+		default:
+			return fmt.Errorf("unsupported output merge strategy '%s'", output.Merge)
+		}
+	}
+
+	return nil
+}
+
+// validateSelector checks the requirements of a trigger's selector
+func validateSelector(selector *ejv1.Selector) error {
+	if selector == nil {
+		return nil
+	}
+	for _, exp := range selector.MatchExpressions {
+		if exp == nil {
+			continue
+		}
+		_, err := labels.NewRequirement(exp.Key, exp.Operator, exp.Values)
+		if err != nil {
+			return fmt.Errorf("invalid selector requirement for key '%s': %s", exp.Key, err)
+		}
+	}
+	return nil
+}
+
+// Validator implements inject.Decoder.
+// A decoder will be automatically injected.
+var _ inject.Decoder = &Validator{}
+
+// InjectDecoder injects the decoder.
+func (v *Validator) InjectDecoder(d types.Decoder) error {
+	v.decoder = d
+	return nil
+}
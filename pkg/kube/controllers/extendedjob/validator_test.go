@@ -0,0 +1,321 @@
+package extendedjob_test
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+
+	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/client/clientset/versioned/scheme"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/extendedjob"
+	helper "code.cloudfoundry.org/cf-operator/pkg/testhelper"
+	"code.cloudfoundry.org/cf-operator/testing"
+)
+
+var _ = Describe("Validator", func() {
+	var (
+		env       testing.Catalog
+		log       *zap.SugaredLogger
+		validator admission.Handler
+		eJob      *ejv1.ExtendedJob
+	)
+
+	BeforeEach(func() {
+		controllers.AddToScheme(scheme.Scheme)
+		_, log = helper.NewTestLogger()
+		validator = extendedjob.NewValidator(log)
+		decoder, _ := admission.NewDecoder(scheme.Scheme)
+		validator.(inject.Decoder).InjectDecoder(decoder)
+		eJob = env.DefaultExtendedJob("foo")
+	})
+
+	act := func() types.Response {
+		eJob.TypeMeta.APIVersion = "fissile.cloudfoundry.org/v1alpha1"
+		eJob.TypeMeta.Kind = "ExtendedJob"
+		raw, err := json.Marshal(eJob)
+		Expect(err).ToNot(HaveOccurred())
+		return validator.Handle(context.Background(), types.Request{
+			AdmissionRequest: &admissionv1beta1.AdmissionRequest{
+				Object: runtime.RawExtension{Raw: raw},
+			},
+		})
+	}
+
+	It("allows a valid pod state triggered ExtendedJob", func() {
+		Expect(act().Response.Allowed).To(BeTrue())
+	})
+
+	It("allows an auto-errand without pod state trigger", func() {
+		job := env.AutoErrandExtendedJob("foo")
+		eJob = &job
+		Expect(act().Response.Allowed).To(BeTrue())
+	})
+
+	It("allows an empty strategy, which is treated as manual", func() {
+		job := env.ErrandExtendedJob("foo")
+		eJob = &job
+		eJob.Spec.Trigger.Strategy = ""
+		Expect(act().Response.Allowed).To(BeTrue())
+	})
+
+	It("rejects an unknown strategy", func() {
+		eJob.Spec.Trigger.Strategy = "sometimes"
+		resp := act()
+		Expect(resp.Response.Allowed).To(BeFalse())
+		Expect(resp.Response.Result.Message).To(ContainSubstring("invalid trigger strategy 'sometimes'"))
+	})
+
+	Context("when the job is scheduled", func() {
+		BeforeEach(func() {
+			job := env.ScheduledExtendedJob("foo", "0 3 * * *")
+			eJob = &job
+		})
+
+		It("allows a valid schedule", func() {
+			eJob.Spec.Trigger.Schedule.TimeZone = "Europe/Berlin"
+			eJob.Spec.Trigger.Schedule.ConcurrencyPolicy = ejv1.ForbidConcurrent
+			Expect(act().Response.Allowed).To(BeTrue())
+		})
+
+		It("rejects a schedule strategy without a schedule", func() {
+			eJob.Spec.Trigger.Schedule = nil
+			resp := act()
+			Expect(resp.Response.Allowed).To(BeFalse())
+			Expect(resp.Response.Result.Message).To(ContainSubstring("requires a schedule"))
+		})
+
+		It("rejects an invalid cron expression", func() {
+			eJob.Spec.Trigger.Schedule.Cron = "0 25 * * *"
+			resp := act()
+			Expect(resp.Response.Allowed).To(BeFalse())
+			Expect(resp.Response.Result.Message).To(ContainSubstring("invalid schedule: hour '25' out of range"))
+		})
+
+		It("rejects an unknown time zone", func() {
+			eJob.Spec.Trigger.Schedule.TimeZone = "Mars/Olympus_Mons"
+			resp := act()
+			Expect(resp.Response.Allowed).To(BeFalse())
+			Expect(resp.Response.Result.Message).To(ContainSubstring("invalid time zone 'Mars/Olympus_Mons'"))
+		})
+
+		It("rejects an unknown concurrency policy", func() {
+			eJob.Spec.Trigger.Schedule.ConcurrencyPolicy = "sometimes"
+			resp := act()
+			Expect(resp.Response.Allowed).To(BeFalse())
+			Expect(resp.Response.Result.Message).To(ContainSubstring("invalid concurrency policy 'sometimes'"))
+		})
+	})
+
+	Context("when the job is triggered by a config", func() {
+		BeforeEach(func() {
+			job := env.ConfigChangeExtendedJob("foo", ejv1.ConfigKindSecret, "db-credentials")
+			eJob = &job
+		})
+
+		It("allows a config trigger", func() {
+			Expect(act().Response.Allowed).To(BeTrue())
+		})
+
+		It("rejects a config change strategy without a config", func() {
+			eJob.Spec.Trigger.Config = nil
+			resp := act()
+			Expect(resp.Response.Allowed).To(BeFalse())
+			Expect(resp.Response.Result.Message).To(ContainSubstring("requires a config"))
+		})
+
+		It("rejects an unknown kind", func() {
+			eJob.Spec.Trigger.Config.Kind = "pod"
+			resp := act()
+			Expect(resp.Response.Allowed).To(BeFalse())
+			Expect(resp.Response.Result.Message).To(ContainSubstring("invalid config kind 'pod'"))
+		})
+
+		It("rejects a config trigger without name and selector", func() {
+			eJob.Spec.Trigger.Config.Name = ""
+			resp := act()
+			Expect(resp.Response.Allowed).To(BeFalse())
+			Expect(resp.Response.Result.Message).To(ContainSubstring("requires a name or a selector"))
+		})
+	})
+
+	Context("when the job is triggered by another job", func() {
+		BeforeEach(func() {
+			job := env.JobCompletionExtendedJob("foo", "migrate", ejv1.JobSucceeded)
+			eJob = &job
+		})
+
+		It("allows a job completion trigger", func() {
+			Expect(act().Response.Allowed).To(BeTrue())
+		})
+
+		It("rejects a job completion strategy without a job", func() {
+			eJob.Spec.Trigger.Job = nil
+			resp := act()
+			Expect(resp.Response.Allowed).To(BeFalse())
+			Expect(resp.Response.Result.Message).To(ContainSubstring("requires a job"))
+		})
+
+		It("rejects a trigger on the job itself", func() {
+			eJob.Spec.Trigger.Job.Name = "foo"
+			resp := act()
+			Expect(resp.Response.Allowed).To(BeFalse())
+			Expect(resp.Response.Result.Message).To(ContainSubstring("must not refer to the extended job itself"))
+		})
+
+		It("rejects an unknown outcome", func() {
+			eJob.Spec.Trigger.Job.When = "sometimes"
+			resp := act()
+			Expect(resp.Response.Allowed).To(BeFalse())
+			Expect(resp.Response.Result.Message).To(ContainSubstring("invalid job outcome 'sometimes'"))
+		})
+	})
+
+	It("allows limits for retries, run time and history", func() {
+		backoffLimit := int32(3)
+		deadline := int64(600)
+		ttl := int64(86400)
+		limit := int32(5)
+		parallelism := int32(2)
+		completions := int32(4)
+		eJob.Spec.BackoffLimit = &backoffLimit
+		eJob.Spec.ActiveDeadlineSeconds = &deadline
+		eJob.Spec.FailedJobTTLSeconds = &ttl
+		eJob.Spec.RunHistoryLimit = &limit
+		eJob.Spec.Parallelism = &parallelism
+		eJob.Spec.Completions = &completions
+		Expect(act().Response.Allowed).To(BeTrue())
+	})
+
+	It("rejects a negative backoff limit", func() {
+		backoffLimit := int32(-1)
+		eJob.Spec.BackoffLimit = &backoffLimit
+		resp := act()
+		Expect(resp.Response.Allowed).To(BeFalse())
+		Expect(resp.Response.Result.Message).To(ContainSubstring("backoff limit must not be negative"))
+	})
+
+	It("rejects jobs without pods", func() {
+		completions := int32(0)
+		eJob.Spec.Completions = &completions
+		resp := act()
+		Expect(resp.Response.Allowed).To(BeFalse())
+		Expect(resp.Response.Result.Message).To(ContainSubstring("completions must be at least 1"))
+	})
+
+	It("rejects a run history without runs", func() {
+		limit := int32(0)
+		eJob.Spec.RunHistoryLimit = &limit
+		resp := act()
+		Expect(resp.Response.Allowed).To(BeFalse())
+		Expect(resp.Response.Result.Message).To(ContainSubstring("run history limit must be at least 1"))
+	})
+
+	It("rejects a pod state trigger without 'when'", func() {
+		eJob.Spec.Trigger.PodState.When = ejv1.PodStateUnknown
+		resp := act()
+		Expect(resp.Response.Allowed).To(BeFalse())
+		Expect(resp.Response.Result.Message).To(ContainSubstring("missing 'when'"))
+	})
+
+	It("rejects selector requirements with an unknown operator", func() {
+		eJob.Spec.Trigger.PodState.Selector.MatchExpressions = []*ejv1.Requirement{
+			{Key: "env", Operator: "like", Values: []string{"production"}},
+		}
+		resp := act()
+		Expect(resp.Response.Allowed).To(BeFalse())
+		Expect(resp.Response.Result.Message).To(ContainSubstring("invalid selector requirement for key 'env'"))
+	})
+
+	It("rejects an unsupported output type", func() {
+		eJob = env.OutputExtendedJob("foo", env.DefaultPodTemplate("foo"))
+		eJob.Spec.Output.OutputType = "xml"
+		resp := act()
+		Expect(resp.Response.Allowed).To(BeFalse())
+		Expect(resp.Response.Result.Message).To(ContainSubstring("unsupported output type 'xml'"))
+	})
+
+	It("allows yaml and raw output types", func() {
+		eJob = env.OutputExtendedJob("foo", env.DefaultPodTemplate("foo"))
+		for _, outputType := range []string{ejv1.OutputTypeYAML, ejv1.OutputTypeRaw} {
+			eJob.Spec.Output.OutputType = outputType
+			Expect(act().Response.Allowed).To(BeTrue())
+		}
+	})
+
+	Context("when the output is collected from files", func() {
+		BeforeEach(func() {
+			eJob = env.OutputExtendedJob("foo", env.DefaultPodTemplate("foo"))
+			eJob.Spec.Output.OutputType = ""
+			eJob.Spec.Output.Source = ejv1.OutputSourceFiles
+		})
+
+		It("allows the job", func() {
+			Expect(act().Response.Allowed).To(BeTrue())
+		})
+
+		It("rejects an output type", func() {
+			eJob.Spec.Output.OutputType = ejv1.OutputTypeYAML
+			resp := act()
+			Expect(resp.Response.Allowed).To(BeFalse())
+			Expect(resp.Response.Result.Message).To(ContainSubstring("output type 'yaml' cannot be used with the files output source"))
+		})
+
+		It("rejects templates with several containers", func() {
+			eJob.Spec.Template.Spec.Containers = append(eJob.Spec.Template.Spec.Containers, corev1.Container{Name: "sidecar", Image: "busybox"})
+			resp := act()
+			Expect(resp.Response.Allowed).To(BeFalse())
+			Expect(resp.Response.Result.Message).To(ContainSubstring("the files output source only supports a single container, the template has 2"))
+		})
+
+		It("rejects a container named like the output collector", func() {
+			eJob.Spec.Template.Spec.Containers[0].Name = extendedjob.OutputCollectorName
+			resp := act()
+			Expect(resp.Response.Allowed).To(BeFalse())
+			Expect(resp.Response.Result.Message).To(ContainSubstring("reserved for the output collector"))
+		})
+	})
+
+	It("rejects an unsupported output source", func() {
+		eJob = env.OutputExtendedJob("foo", env.DefaultPodTemplate("foo"))
+		eJob.Spec.Output.Source = "network"
+		resp := act()
+		Expect(resp.Response.Allowed).To(BeFalse())
+		Expect(resp.Response.Result.Message).To(ContainSubstring("unsupported output source 'network'"))
+	})
+
+	It("rejects an unsupported output target", func() {
+		eJob = env.OutputExtendedJob("foo", env.DefaultPodTemplate("foo"))
+		eJob.Spec.Output.Target = "database"
+		resp := act()
+		Expect(resp.Response.Allowed).To(BeFalse())
+		Expect(resp.Response.Result.Message).To(ContainSubstring("unsupported output target 'database'"))
+	})
+
+	It("rejects run overrides for containers which do not exist", func() {
+		eJob.Annotations = map[string]string{
+			ejv1.AnnotationRunOverrides: `[{"name": "missing", "args": ["--dry-run"]}]`,
+		}
+		resp := act()
+		Expect(resp.Response.Allowed).To(BeFalse())
+		Expect(resp.Response.Result.Message).To(ContainSubstring("container 'missing' to override does not exist"))
+	})
+
+	It("rejects an unsupported output merge strategy", func() {
+		eJob = env.OutputExtendedJob("foo", env.DefaultPodTemplate("foo"))
+		eJob.Spec.Output.Merge = "random"
+		resp := act()
+		Expect(resp.Response.Allowed).To(BeFalse())
+		Expect(resp.Response.Result.Message).To(ContainSubstring("unsupported output merge strategy 'random'"))
+	})
+})
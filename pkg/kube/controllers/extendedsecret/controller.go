@@ -11,18 +11,23 @@ import (
 
 	credsgen "code.cloudfoundry.org/cf-operator/pkg/credsgen/in_memory_generator"
 	es "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedsecret/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/backoff"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
 )
 
 // Add creates a new ExtendedSecrets Controller and adds it to the Manager
 func Add(ctx context.Context, config *config.Config, mgr manager.Manager) error {
+	config = config.ForController("extendedsecret")
 	ctx = ctxlog.NewContextWithRecorder(ctx, "ext-secret-reconciler", mgr.GetRecorder("ext-secret-recorder"))
 	log := ctxlog.ExtractLogger(ctx)
 	r := NewReconciler(ctx, config, mgr, credsgen.NewInMemoryGenerator(log), controllerutil.SetControllerReference)
 
 	// Create a new controller
-	c, err := controller.New("extendedsecret-controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New("extendedsecret-controller", mgr, controller.Options{
+		Reconciler:              backoff.NewReconciler(ctx, r, config.BackoffMin, config.BackoffMax),
+		MaxConcurrentReconciles: config.MaxConcurrentReconciles,
+	})
 	if err != nil {
 		return err
 	}
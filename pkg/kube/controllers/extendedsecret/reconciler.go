@@ -18,9 +18,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
 	esv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedsecret/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/metrics"
 )
 
 type setReferenceFunc func(owner, object metav1.Object, scheme *runtime.Scheme) error
@@ -58,6 +60,7 @@ func (r *ReconcileExtendedSecret) Reconcile(request reconcile.Request) (reconcil
 	// Set the ctx to be Background, as the top-level context for incoming requests.
 	ctx, cancel := context.WithTimeout(r.ctx, r.config.CtxTimeOut)
 	defer cancel()
+	ctx = ctxlog.NewRequestContext(ctx, request.Namespace, request.Name)
 
 	ctxlog.Infof(ctx, "Reconciling ExtendedSecret %s", request.NamespacedName)
 	err := r.client.Get(ctx, request.NamespacedName, instance)
@@ -67,12 +70,28 @@ func (r *ReconcileExtendedSecret) Reconcile(request reconcile.Request) (reconcil
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
 			ctxlog.Info(ctx, "Skip reconcile: CRD not found")
+			metrics.CertificateExpiry.Delete(request.NamespacedName)
+			ctxlog.RemoveSecretValues(fmt.Sprintf("ExtendedSecret/%s", request.NamespacedName))
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		ctxlog.Info(ctx, "Error reading the object")
 		return reconcile.Result{}, err
 	}
+	if deployment, ok := instance.GetLabels()[bdv1.LabelDeploymentName]; ok {
+		ctx = ctxlog.WithFields(ctx, "deployment", deployment)
+	}
+
+	// Reuse a secret which was retained when its previous owner was deleted
+	adopted, err := r.adoptRetainedSecret(ctx, instance)
+	if err != nil {
+		ctxlog.Errorf(ctx, "Error adopting the secret: %v", err.Error())
+		return reconcile.Result{}, err
+	}
+	if adopted {
+		ctxlog.WithEvent(instance, "SecretAdopted").Infof(ctx, "Skip reconcile: retained secret '%s' has been adopted", instance.Spec.SecretName)
+		return reconcile.Result{}, nil
+	}
 
 	// Check if secret could be generated when secret was already created
 	canBeGenerated, err := r.canBeGenerated(ctx, instance)
@@ -233,6 +252,10 @@ func (r *ReconcileExtendedSecret) createCertificateSecret(ctx context.Context, i
 	if err != nil {
 		return err
 	}
+	err = metrics.CertificateExpiry.Set(types.NamespacedName{Namespace: instance.GetNamespace(), Name: instance.GetName()}, cert.Certificate)
+	if err != nil {
+		ctxlog.Debugf(ctx, "Not reporting the expiry of certificate '%s': %v", instance.GetName(), err)
+	}
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      instance.Spec.SecretName,
@@ -265,10 +288,48 @@ func (r *ReconcileExtendedSecret) canBeGenerated(ctx context.Context, instance *
 		secretLabels = map[string]string{}
 	}
 
-	if secretLabels[esv1.LabelKind] != "generated" {
+	// The values of existing secrets are masked in logs, too
+	ctxlog.AddSecretData(secretScope(instance), existingSecret.Data)
+
+	if secretLabels[esv1.LabelKind] != esv1.GeneratedSecretKind {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// adoptRetainedSecret makes the ExtendedSecret the owner of a retained secret, which then counts as generated again
+func (r *ReconcileExtendedSecret) adoptRetainedSecret(ctx context.Context, instance *esv1.ExtendedSecret) (bool, error) {
+	secret := &corev1.Secret{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: instance.Spec.SecretName, Namespace: instance.GetNamespace()}, secret)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "could not get secret")
+	}
+
+	if secret.GetLabels()[esv1.LabelKind] != esv1.RetainedSecretKind {
 		return false, nil
 	}
 
+	secret.Labels[esv1.LabelKind] = esv1.GeneratedSecretKind
+	if err := r.setReference(instance, secret, r.scheme); err != nil {
+		return false, errors.Wrapf(err, "error setting owner for secret '%s' to ExtendedSecret '%s' in namespace '%s'", secret.GetName(), instance.GetName(), instance.GetNamespace())
+	}
+
+	err = r.client.Update(ctx, secret)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not update secret '%s'", secret.GetName())
+	}
+
+	if instance.Spec.Type == esv1.Certificate {
+		err = metrics.CertificateExpiry.Set(types.NamespacedName{Namespace: instance.GetNamespace(), Name: instance.GetName()}, secret.Data["certificate"])
+		if err != nil {
+			ctxlog.Debugf(ctx, "Not reporting the expiry of certificate '%s': %v", instance.GetName(), err)
+		}
+	}
+
 	return true, nil
 }
 
@@ -279,10 +340,19 @@ func (r *ReconcileExtendedSecret) createSecret(ctx context.Context, instance *es
 		secretLabels = map[string]string{}
 	}
 
-	secretLabels[esv1.LabelKind] = "generated"
+	secretLabels[esv1.LabelKind] = esv1.GeneratedSecretKind
 
 	secret.SetLabels(secretLabels)
 
+	values := make([]string, 0, len(secret.Data)+len(secret.StringData))
+	for _, value := range secret.Data {
+		values = append(values, string(value))
+	}
+	for _, value := range secret.StringData {
+		values = append(values, value)
+	}
+	ctxlog.AddSecretValues(secretScope(instance), values...)
+
 	if err := r.setReference(instance, secret, r.scheme); err != nil {
 		return errors.Wrapf(err, "error setting owner for secret '%s' to ExtendedSecret '%s' in namespace '%s'", secret.GetName(), instance.GetName(), instance.GetNamespace())
 	}
@@ -301,3 +371,8 @@ func (r *ReconcileExtendedSecret) createSecret(ctx context.Context, instance *es
 
 	return nil
 }
+
+// secretScope identifies the values of an ExtendedSecret's secret, which are masked in logs
+func secretScope(instance *esv1.ExtendedSecret) string {
+	return fmt.Sprintf("ExtendedSecret/%s/%s", instance.GetNamespace(), instance.GetName())
+}
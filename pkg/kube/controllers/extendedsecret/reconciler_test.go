@@ -317,5 +317,24 @@ var _ = Describe("ReconcileExtendedSecret", func() {
 			Expect(client.UpdateCallCount()).To(Equal(1))
 			Expect(reconcile.Result{}).To(Equal(result))
 		})
+
+		It("Adopts a retained secret instead of regenerating it", func() {
+			secret.Labels = map[string]string{
+				esv1.LabelKind: esv1.RetainedSecretKind,
+			}
+
+			client.UpdateCalls(func(context context.Context, object runtime.Object) error {
+				secret := object.(*corev1.Secret)
+				Expect(secret.StringData["password"]).To(Equal("securepassword"))
+				Expect(secret.GetLabels()).To(HaveKeyWithValue(esv1.LabelKind, esv1.GeneratedSecretKind))
+				return nil
+			})
+
+			result, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.UpdateCallCount()).To(Equal(1))
+			Expect(generator.GeneratePasswordCallCount()).To(Equal(0))
+			Expect(reconcile.Result{}).To(Equal(result))
+		})
 	})
 })
@@ -0,0 +1,45 @@
+package extendedsecret
+
+import (
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/builder"
+
+	esv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedsecret/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/nslabel"
+)
+
+// AddValidator creates a new hook for validating ExtendedSecrets and adds it to the Manager
+func AddValidator(log *zap.SugaredLogger, config *config.Config, mgr manager.Manager, hookServer *webhook.Server) (*admission.Webhook, error) {
+	log.Info("Setting up ExtendedSecret validation webhook")
+
+	validatingWebhook, err := builder.NewWebhookBuilder().
+		Path("/validate-extendedsecrets").
+		Validating().
+		NamespaceSelector(&metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				nslabel.LabelOperatorNamespace: config.Namespace,
+			},
+		}).
+		ForType(&esv1.ExtendedSecret{}).
+		Handlers(NewValidator(log)).
+		WithManager(mgr).
+		FailurePolicy(admissionregistrationv1beta1.Fail).
+		Build()
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't build a new webhook")
+	}
+
+	err = hookServer.Register(validatingWebhook)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to register the hook with the admission server")
+	}
+
+	return validatingWebhook, nil
+}
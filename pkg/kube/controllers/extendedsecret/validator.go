@@ -0,0 +1,82 @@
+package extendedsecret
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+
+	esv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedsecret/v1alpha1"
+)
+
+// Validator rejects invalid ExtendedSecret definitions
+type Validator struct {
+	log     *zap.SugaredLogger
+	decoder types.Decoder
+}
+
+// Implement admission.Handler so the controller can handle admission request.
+var _ admission.Handler = &Validator{}
+
+// NewValidator returns a new admission.Handler for ExtendedSecrets
+func NewValidator(log *zap.SugaredLogger) admission.Handler {
+	validatorLog := log.Named("extendedsecret-validator")
+	validatorLog.Info("Creating a validator for ExtendedSecret")
+
+	return &Validator{
+		log: validatorLog,
+	}
+}
+
+// Handle rejects ExtendedSecrets the controller could not generate
+func (v *Validator) Handle(ctx context.Context, req types.Request) types.Response {
+	extendedSecret := &esv1.ExtendedSecret{}
+
+	err := v.decoder.Decode(req, extendedSecret)
+	if err != nil {
+		return admission.ErrorResponse(http.StatusBadRequest, err)
+	}
+
+	v.log.Debug("ExtendedSecret validator handler ran for ", extendedSecret.Name)
+
+	err = validate(extendedSecret)
+	if err != nil {
+		v.log.Infof("Rejecting ExtendedSecret '%s': %s", extendedSecret.Name, err)
+		return admission.ErrorResponse(http.StatusUnprocessableEntity, err)
+	}
+
+	return admission.ValidationResponse(true, "")
+}
+
+// validate checks that certificates which are not a CA reference the CA to sign them
+func validate(extendedSecret *esv1.ExtendedSecret) error {
+	if extendedSecret.Spec.Type != esv1.Certificate {
+		return nil
+	}
+
+	request := extendedSecret.Spec.Request.CertificateRequest
+	if request.IsCA {
+		return nil
+	}
+
+	if request.CARef.Name == "" || request.CARef.Key == "" {
+		return fmt.Errorf("certificate '%s' is not a CA and needs a CARef with name and key", extendedSecret.Spec.SecretName)
+	}
+
+	return nil
+}
+
+// Validator implements inject.Decoder.
+// A decoder will be automatically injected.
+var _ inject.Decoder = &Validator{}
+
+// InjectDecoder injects the decoder.
+func (v *Validator) InjectDecoder(d types.Decoder) error {
+	v.decoder = d
+	return nil
+}
@@ -0,0 +1,79 @@
+package extendedsecret_test
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+
+	esv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedsecret/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/client/clientset/versioned/scheme"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
+	escontroller "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/extendedsecret"
+	helper "code.cloudfoundry.org/cf-operator/pkg/testhelper"
+	"code.cloudfoundry.org/cf-operator/testing"
+)
+
+var _ = Describe("Validator", func() {
+	var (
+		env       testing.Catalog
+		log       *zap.SugaredLogger
+		validator admission.Handler
+		es        esv1.ExtendedSecret
+	)
+
+	BeforeEach(func() {
+		controllers.AddToScheme(scheme.Scheme)
+		_, log = helper.NewTestLogger()
+		validator = escontroller.NewValidator(log)
+		decoder, _ := admission.NewDecoder(scheme.Scheme)
+		validator.(inject.Decoder).InjectDecoder(decoder)
+		es = env.DefaultExtendedSecret("foo")
+	})
+
+	act := func() types.Response {
+		es.TypeMeta.APIVersion = "fissile.cloudfoundry.org/v1alpha1"
+		es.TypeMeta.Kind = "ExtendedSecret"
+		raw, err := json.Marshal(es)
+		Expect(err).ToNot(HaveOccurred())
+		return validator.Handle(context.Background(), types.Request{
+			AdmissionRequest: &admissionv1beta1.AdmissionRequest{
+				Object: runtime.RawExtension{Raw: raw},
+			},
+		})
+	}
+
+	It("allows a password", func() {
+		Expect(act().Response.Allowed).To(BeTrue())
+	})
+
+	Context("when generating certificates", func() {
+		BeforeEach(func() {
+			es.Spec.Type = esv1.Certificate
+		})
+
+		It("allows a CA without CARef", func() {
+			es.Spec.Request.CertificateRequest.IsCA = true
+			Expect(act().Response.Allowed).To(BeTrue())
+		})
+
+		It("allows a certificate referencing its CA", func() {
+			es.Spec.Request.CertificateRequest.CARef = esv1.SecretReference{Name: "mysecret", Key: "ca"}
+			Expect(act().Response.Allowed).To(BeTrue())
+		})
+
+		It("rejects a certificate without CARef", func() {
+			resp := act()
+			Expect(resp.Response.Allowed).To(BeFalse())
+			Expect(resp.Response.Result.Message).To(ContainSubstring("needs a CARef"))
+		})
+	})
+})
@@ -17,6 +17,7 @@ import (
 
 	"code.cloudfoundry.org/cf-operator/pkg/kube/apis"
 	essv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedstatefulset/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/backoff"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/names"
@@ -26,11 +27,15 @@ import (
 
 // Add creates a new ExtendedStatefulSet controller and adds it to the Manager
 func Add(ctx context.Context, config *config.Config, mgr manager.Manager) error {
+	config = config.ForController("extendedstatefulset")
 	ctx = ctxlog.NewContextWithRecorder(ctx, "ext-statefulset-reconciler", mgr.GetRecorder("ext-statefulset-recorder"))
 	r := NewReconciler(ctx, config, mgr, controllerutil.SetControllerReference)
 
 	// Create a new controller
-	c, err := controller.New("extendedstatefulset-controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New("extendedstatefulset-controller", mgr, controller.Options{
+		Reconciler:              backoff.NewReconciler(ctx, r, config.BackoffMin, config.BackoffMax),
+		MaxConcurrentReconciles: config.MaxConcurrentReconciles,
+	})
 	if err != nil {
 		return err
 	}
@@ -41,11 +46,13 @@ func Add(ctx context.Context, config *config.Config, mgr manager.Manager) error
 		return err
 	}
 
-	// Watch ConfigMaps owned by resource ExtendedStatefulSet
-	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestForOwner{
-		IsController: false,
-		OwnerType:    &essv1.ExtendedStatefulSet{},
+	mapConfigMaps := handler.ToRequestsFunc(func(a handler.MapObject) []reconcile.Request {
+		configMap := a.Object.(*corev1.ConfigMap)
+		return reconcilesForConfigMap(ctx, mgr, *configMap)
 	})
+
+	// Watch ConfigMaps owned by resource ExtendedStatefulSet or referenced by resource ExtendedStatefulSet
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestsFromMapFunc{ToRequests: mapConfigMaps})
 	if err != nil {
 		return err
 	}
@@ -65,11 +72,27 @@ func Add(ctx context.Context, config *config.Config, mgr manager.Manager) error
 }
 
 func reconcilesForSecret(ctx context.Context, mgr manager.Manager, secret corev1.Secret) []reconcile.Request {
+	return reconcilesForConfig(ctx, mgr, &secret, versionedsecretstore.VersionSecretKind, func(spec corev1.PodSpec) map[string]struct{} {
+		_, secrets := owner.GetConfigNamesFromSpec(spec)
+		return secrets
+	})
+}
+
+func reconcilesForConfigMap(ctx context.Context, mgr manager.Manager, configMap corev1.ConfigMap) []reconcile.Request {
+	return reconcilesForConfig(ctx, mgr, &configMap, versionedsecretstore.VersionConfigMapKind, func(spec corev1.PodSpec) map[string]struct{} {
+		configMaps, _ := owner.GetConfigNamesFromSpec(spec)
+		return configMaps
+	})
+}
+
+// reconcilesForConfig returns requests for the ExtendedStatefulSets owning the config and for the ones
+// referencing a version of it, if it is a versioned secret or config map of the given kind
+func reconcilesForConfig(ctx context.Context, mgr manager.Manager, config apis.Object, versionedKind string, referencedNames func(corev1.PodSpec) map[string]struct{}) []reconcile.Request {
 	reconciles := []reconcile.Request{}
 
-	// add requests for the ExtendedStatefulSet owning the secret
+	// add requests for the ExtendedStatefulSet owning the config
 	exStsKind := essv1.ExtendedStatefulSet{}.Kind
-	for _, ref := range secret.GetOwnerReferences() {
+	for _, ref := range config.GetOwnerReferences() {
 		refGV, err := schema.ParseGroupVersion(ref.APIVersion)
 		if err != nil {
 			return nil
@@ -77,28 +100,28 @@ func reconcilesForSecret(ctx context.Context, mgr manager.Manager, secret corev1
 
 		if ref.Kind == exStsKind && refGV.Group == apis.GroupName {
 			reconciles = append(reconciles, reconcile.Request{NamespacedName: types.NamespacedName{
-				Namespace: secret.GetNamespace(),
+				Namespace: config.GetNamespace(),
 				Name:      ref.Name,
 			}})
 		}
 	}
 
-	// add requests for the ExtendedStatefulSet referencing the versioned secret
-	secretLabels := secret.GetLabels()
-	if secretLabels == nil {
+	// add requests for the ExtendedStatefulSet referencing the versioned config
+	configLabels := config.GetLabels()
+	if configLabels == nil {
 		return reconciles
 	}
 
-	secretKind, ok := secretLabels[versionedsecretstore.LabelSecretKind]
+	kind, ok := configLabels[versionedsecretstore.LabelSecretKind]
 	if !ok {
 		return reconciles
 	}
-	if secretKind != versionedsecretstore.VersionSecretKind {
+	if kind != versionedKind {
 		return reconciles
 	}
 
-	referencedSecretName := names.GetPrefixFromVersionedSecretName(secret.GetName())
-	if referencedSecretName == "" {
+	referencedName := names.GetPrefixFromVersionedSecretName(config.GetName())
+	if referencedName == "" {
 		return reconciles
 	}
 
@@ -109,8 +132,8 @@ func reconcilesForSecret(ctx context.Context, mgr manager.Manager, secret corev1
 	}
 
 	for _, exStatefulSet := range exStatefulSets.Items {
-		_, referencedSecrets := owner.GetConfigNamesFromSpec(exStatefulSet.Spec.Template.Spec.Template.Spec)
-		if _, ok := referencedSecrets[referencedSecretName]; ok {
+		referenced := referencedNames(exStatefulSet.Spec.Template.Spec.Template.Spec)
+		if _, ok := referenced[referencedName]; ok {
 			reconciles = append(reconciles, reconcile.Request{
 				NamespacedName: types.NamespacedName{
 					Name:      exStatefulSet.GetName(),
@@ -119,9 +142,9 @@ func reconcilesForSecret(ctx context.Context, mgr manager.Manager, secret corev1
 			})
 		}
 
-		// add requests for the ExtendedStatefulSet referencing the versioned secret when a new ExtendedStatefulSet template updated
-		for secretName := range referencedSecrets {
-			if strings.HasPrefix(secretName, referencedSecretName) {
+		// add requests for the ExtendedStatefulSet referencing the versioned config when a new ExtendedStatefulSet template updated
+		for name := range referenced {
+			if strings.HasPrefix(name, referencedName) {
 				reconciles = append(reconciles, reconcile.Request{
 					NamespacedName: types.NamespacedName{
 						Name:      exStatefulSet.GetName(),
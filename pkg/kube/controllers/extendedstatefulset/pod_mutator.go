@@ -66,6 +66,11 @@ func (m *PodMutator) Handle(ctx context.Context, req types.Request) types.Respon
 		return admission.ErrorResponse(http.StatusBadRequest, err)
 	}
 
+	// The namespace of a pod is not set yet, if it is created by a controller
+	if pod.Namespace == "" && req.AdmissionRequest != nil {
+		pod.Namespace = req.AdmissionRequest.Namespace
+	}
+
 	updatedPod := pod.DeepCopy()
 
 	// TODO :- send pod instead of annotations.
@@ -89,13 +94,13 @@ func (m *PodMutator) mutatePodsFn(ctx context.Context, pod *corev1.Pod) error {
 	if !isVolumeManagementStatefulSetPod(pod.Name) {
 
 		// Fetch extendedStatefulSet
-		statefulSet, err := m.fetchStatefulset(ctx, pod.Name)
+		statefulSet, err := m.fetchStatefulset(ctx, pod.Namespace, pod.Name)
 		if err != nil {
 			return errors.Wrapf(err, "Couldn't fetch Statefulset")
 		}
 
 		// Fetch extendedStatefulSet
-		extendedStatefulSet, err := m.fetchExtendedStatefulset(ctx, pod.Name)
+		extendedStatefulSet, err := m.fetchExtendedStatefulset(ctx, pod.Namespace, pod.Name)
 		if err != nil {
 			return errors.Wrapf(err, "Couldn't fetch ExtendedStatefulset")
 		}
@@ -115,7 +120,7 @@ func (m *PodMutator) mutatePodsFn(ctx context.Context, pod *corev1.Pod) error {
 func (m *PodMutator) addPersistentVolumeClaims(ctx context.Context, statefulSet *v1beta2.StatefulSet, extendedStatefulSet *essv1a1.ExtendedStatefulSet, pod *corev1.Pod) error {
 
 	// Get persistentVolumeClaims list
-	opts := client.InNamespace(m.config.Namespace)
+	opts := client.InNamespace(pod.Namespace)
 	persistentVolumeClaimList := &corev1.PersistentVolumeClaimList{}
 	err := m.client.List(ctx, opts, persistentVolumeClaimList)
 	if err != nil {
@@ -206,10 +211,10 @@ func isVolumeManagementStatefulSetPod(podName string) bool {
 }
 
 // fetchExtendedStatefulset fetches the extendedstatefulset of the pod
-func (m *PodMutator) fetchStatefulset(ctx context.Context, podName string) (*v1beta2.StatefulSet, error) {
+func (m *PodMutator) fetchStatefulset(ctx context.Context, namespace string, podName string) (*v1beta2.StatefulSet, error) {
 	statefulSet := &v1beta2.StatefulSet{}
 	statefulSetName := getNameWithOutVersion(podName, 1)
-	key := mTypes.NamespacedName{Namespace: m.config.Namespace, Name: statefulSetName}
+	key := mTypes.NamespacedName{Namespace: namespace, Name: statefulSetName}
 	err := m.client.Get(ctx, key, statefulSet)
 	if err != nil {
 		return &v1beta2.StatefulSet{}, err
@@ -218,10 +223,10 @@ func (m *PodMutator) fetchStatefulset(ctx context.Context, podName string) (*v1b
 }
 
 // fetchExtendedStatefulset fetches the extendedstatefulset of the pod
-func (m *PodMutator) fetchExtendedStatefulset(ctx context.Context, podName string) (*essv1a1.ExtendedStatefulSet, error) {
+func (m *PodMutator) fetchExtendedStatefulset(ctx context.Context, namespace string, podName string) (*essv1a1.ExtendedStatefulSet, error) {
 	extendedStatefulSet := &essv1a1.ExtendedStatefulSet{}
 	extendedStatefulSetName := getNameWithOutVersion(podName, 2)
-	key := mTypes.NamespacedName{Namespace: m.config.Namespace, Name: extendedStatefulSetName}
+	key := mTypes.NamespacedName{Namespace: namespace, Name: extendedStatefulSetName}
 	err := m.client.Get(ctx, key, extendedStatefulSet)
 	if err != nil {
 		return &essv1a1.ExtendedStatefulSet{}, err
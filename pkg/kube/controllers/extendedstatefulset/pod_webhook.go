@@ -2,6 +2,7 @@ package extendedstatefulset
 
 import (
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/nslabel"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
@@ -26,7 +27,7 @@ func AddPod(log *zap.SugaredLogger, config *config.Config, mgr manager.Manager,
 		Mutating().
 		NamespaceSelector(&metav1.LabelSelector{
 			MatchLabels: map[string]string{
-				"cf-operator-ns": config.Namespace,
+				nslabel.LabelOperatorNamespace: config.Namespace,
 			},
 		}).
 		ForType(&corev1.Pod{}).
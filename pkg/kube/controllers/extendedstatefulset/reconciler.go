@@ -25,10 +25,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"code.cloudfoundry.org/cf-operator/pkg/kube/apis"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
 	essv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedstatefulset/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/finalizer"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/metrics"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/owner"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/versionedsecretstore"
 )
@@ -64,27 +66,30 @@ type Owner interface {
 // NewReconciler returns a new reconcile.Reconciler
 func NewReconciler(ctx context.Context, config *config.Config, mgr manager.Manager, srf setReferenceFunc) reconcile.Reconciler {
 	versionedSecretStore := versionedsecretstore.NewVersionedSecretStore(mgr.GetClient())
+	versionedConfigMapStore := versionedsecretstore.NewVersionedConfigMapStore(mgr.GetClient())
 
 	return &ReconcileExtendedStatefulSet{
-		ctx:                  ctx,
-		config:               config,
-		client:               mgr.GetClient(),
-		scheme:               mgr.GetScheme(),
-		setReference:         srf,
-		owner:                owner.NewOwner(mgr.GetClient(), mgr.GetScheme()),
-		versionedSecretStore: versionedSecretStore,
+		ctx:                     ctx,
+		config:                  config,
+		client:                  mgr.GetClient(),
+		scheme:                  mgr.GetScheme(),
+		setReference:            srf,
+		owner:                   owner.NewOwner(mgr.GetClient(), mgr.GetScheme()),
+		versionedSecretStore:    versionedSecretStore,
+		versionedConfigMapStore: versionedConfigMapStore,
 	}
 }
 
 // ReconcileExtendedStatefulSet reconciles an ExtendedStatefulSet object
 type ReconcileExtendedStatefulSet struct {
-	ctx                  context.Context
-	client               client.Client
-	scheme               *runtime.Scheme
-	setReference         setReferenceFunc
-	config               *config.Config
-	owner                Owner
-	versionedSecretStore versionedsecretstore.VersionedSecretStore
+	ctx                     context.Context
+	client                  client.Client
+	scheme                  *runtime.Scheme
+	setReference            setReferenceFunc
+	config                  *config.Config
+	owner                   Owner
+	versionedSecretStore    versionedsecretstore.VersionedSecretStore
+	versionedConfigMapStore versionedsecretstore.VersionedConfigMapStore
 }
 
 // Reconcile reads that state of the cluster for a ExtendedStatefulSet object
@@ -100,6 +105,7 @@ func (r *ReconcileExtendedStatefulSet) Reconcile(request reconcile.Request) (rec
 	// Set the ctx to be Background, as the top-level context for incoming requests.
 	ctx, cancel := context.WithTimeout(r.ctx, r.config.CtxTimeOut)
 	defer cancel()
+	ctx = ctxlog.NewRequestContext(ctx, request.Namespace, request.Name)
 
 	ctxlog.Info(ctx, "Reconciling ExtendedStatefulSet ", request.NamespacedName)
 	err := r.client.Get(ctx, request.NamespacedName, exStatefulSet)
@@ -115,6 +121,9 @@ func (r *ReconcileExtendedStatefulSet) Reconcile(request reconcile.Request) (rec
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
+	if deployment, ok := exStatefulSet.GetLabels()[bdv1.LabelDeploymentName]; ok {
+		ctx = ctxlog.WithFields(ctx, "deployment", deployment)
+	}
 
 	// Clean up exStatefulSet
 	if exStatefulSet.ToBeDeleted() {
@@ -129,14 +138,18 @@ func (r *ReconcileExtendedStatefulSet) Reconcile(request reconcile.Request) (rec
 		return reconcile.Result{}, err
 	}
 
-	// TODO: generate an ID for the request
-
 	err = r.versionedSecretStore.UpdateSecretReferences(ctx, exStatefulSet.GetNamespace(), &exStatefulSet.Spec.Template.Spec.Template.Spec)
 	if err != nil {
 		ctxlog.Error(ctx, "Could not update versioned secrets of ExtendedStatefulSet '", request.NamespacedName, "': ", err)
 		return reconcile.Result{}, err
 	}
 
+	err = r.versionedConfigMapStore.UpdateConfigMapReferences(ctx, exStatefulSet.GetNamespace(), &exStatefulSet.Spec.Template.Spec.Template.Spec)
+	if err != nil {
+		ctxlog.Error(ctx, "Could not update versioned config maps of ExtendedStatefulSet '", request.NamespacedName, "': ", err)
+		return reconcile.Result{}, err
+	}
+
 	// Get the actual StatefulSet
 	actualStatefulSet, actualVersion, err := r.getActualStatefulSet(ctx, exStatefulSet)
 	if err != nil {
@@ -221,6 +234,8 @@ func (r *ReconcileExtendedStatefulSet) Reconcile(request reconcile.Request) (rec
 		}
 	}
 
+	metrics.ExtendedStatefulSetVersions.WithLabelValues(exStatefulSet.GetNamespace(), exStatefulSet.GetName()).Set(float64(len(statefulSetVersions)))
+
 	if !statefulSetVersions[desiredVersion] {
 		ctxlog.Debug(ctx, "Waiting for the desired version to become available for ExtendedStatefulSet ", request.NamespacedName)
 		return reconcile.Result{Requeue: true, RequeueAfter: 5 * time.Second}, nil
@@ -617,6 +632,7 @@ func (r *ReconcileExtendedStatefulSet) updateConfigSHA1(ctx context.Context, act
 // and object's Finalizers
 func (r *ReconcileExtendedStatefulSet) handleDelete(ctx context.Context, exStatefulSet *essv1a1.ExtendedStatefulSet) (reconcile.Result, error) {
 	ctxlog.Debug(ctx, "Considering existing Owner References of ExtendedStatefulSet '", exStatefulSet.Name, "'.")
+	metrics.ExtendedStatefulSetVersions.DeleteLabelValues(exStatefulSet.GetNamespace(), exStatefulSet.GetName())
 
 	// Fetch all ConfigMaps and Secrets with an OwnerReference pointing to the object
 	existingConfigs, err := r.owner.ListConfigsOwnedBy(ctx, exStatefulSet)
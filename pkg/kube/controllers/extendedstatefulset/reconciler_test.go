@@ -8,6 +8,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 	"k8s.io/api/apps/v1beta2"
 	corev1 "k8s.io/api/core/v1"
 
@@ -23,6 +24,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"code.cloudfoundry.org/cf-operator/pkg/kube/apis"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
 	exss "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedstatefulset/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
 	exssc "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/extendedstatefulset"
@@ -41,6 +43,7 @@ var _ = Describe("ReconcileExtendedStatefulSet", func() {
 		request    reconcile.Request
 		ctx        context.Context
 		log        *zap.SugaredLogger
+		logs       *observer.ObservedLogs
 		config     *cfcfg.Config
 	)
 
@@ -51,7 +54,7 @@ var _ = Describe("ReconcileExtendedStatefulSet", func() {
 
 		request = reconcile.Request{NamespacedName: types.NamespacedName{Name: "foo", Namespace: "default"}}
 		config = &cfcfg.Config{CtxTimeOut: 10 * time.Second}
-		_, log = helper.NewTestLogger()
+		logs, log = helper.NewTestLogger()
 		ctx = ctxlog.NewParentContext(log)
 	})
 
@@ -192,6 +195,34 @@ var _ = Describe("ReconcileExtendedStatefulSet", func() {
 				Expect(metav1.IsControlledBy(ss, ess)).To(BeTrue())
 			})
 
+			Context("when the ExtendedStatefulSet belongs to a BOSH deployment", func() {
+				BeforeEach(func() {
+					desiredExtendedStatefulSet.Labels = map[string]string{bdv1.LabelDeploymentName: "foo-deployment"}
+					client = fake.NewFakeClient(desiredExtendedStatefulSet)
+					manager.GetClientReturns(client)
+				})
+
+				It("logs with the request ID, the requested object and the deployment", func() {
+					_, err := reconciler.Reconcile(request)
+					Expect(err).ToNot(HaveOccurred())
+					_, err = reconciler.Reconcile(request)
+					Expect(err).ToNot(HaveOccurred())
+
+					requestIDs := map[interface{}]bool{}
+					for _, entry := range logs.All() {
+						fields := entry.ContextMap()
+						Expect(fields["namespace"]).To(Equal("default"))
+						Expect(fields["name"]).To(Equal("foo"))
+						Expect(fields["request-id"]).ToNot(BeEmpty())
+						requestIDs[fields["request-id"]] = true
+					}
+					Expect(requestIDs).To(HaveLen(2))
+
+					deploymentLogs := logs.FilterField(zap.String("deployment", "foo-deployment"))
+					Expect(deploymentLogs.Len()).To(BeNumerically(">", 0))
+				})
+			})
+
 			It("updates existing statefulSet", func() {
 				ess := &exss.ExtendedStatefulSet{}
 				err := client.Get(context.Background(), types.NamespacedName{Name: "foo", Namespace: "default"}, ess)
@@ -0,0 +1,94 @@
+package extendedstatefulset
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+
+	essv1a1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedstatefulset/v1alpha1"
+)
+
+// Validator rejects invalid ExtendedStatefulSet definitions
+type Validator struct {
+	log     *zap.SugaredLogger
+	decoder types.Decoder
+}
+
+// Implement admission.Handler so the controller can handle admission request.
+var _ admission.Handler = &Validator{}
+
+// NewValidator returns a new admission.Handler for ExtendedStatefulSets
+func NewValidator(log *zap.SugaredLogger) admission.Handler {
+	validatorLog := log.Named("extendedstatefulset-validator")
+	validatorLog.Info("Creating a validator for ExtendedStatefulSet")
+
+	return &Validator{
+		log: validatorLog,
+	}
+}
+
+// Handle rejects ExtendedStatefulSets the controller could not act on
+func (v *Validator) Handle(ctx context.Context, req types.Request) types.Response {
+	exStatefulSet := &essv1a1.ExtendedStatefulSet{}
+
+	err := v.decoder.Decode(req, exStatefulSet)
+	if err != nil {
+		return admission.ErrorResponse(http.StatusBadRequest, err)
+	}
+
+	v.log.Debug("ExtendedStatefulSet validator handler ran for ", exStatefulSet.Name)
+
+	err = validate(exStatefulSet)
+	if err != nil {
+		v.log.Infof("Rejecting ExtendedStatefulSet '%s': %s", exStatefulSet.Name, err)
+		return admission.ErrorResponse(http.StatusUnprocessableEntity, err)
+	}
+
+	return admission.ValidationResponse(true, "")
+}
+
+// validate checks the zones of the ExtendedStatefulSet, every zone needs a
+// name that can be used as the value of the zone node label
+func validate(exStatefulSet *essv1a1.ExtendedStatefulSet) error {
+	if len(exStatefulSet.Spec.Zones) == 0 {
+		return nil
+	}
+
+	// An empty zone node label falls back to the default, see calculateDesiredStatefulSets
+	zoneNodeLabel := exStatefulSet.Spec.ZoneNodeLabel
+	if zoneNodeLabel == "" {
+		zoneNodeLabel = essv1a1.DefaultZoneNodeLabel
+	}
+	if errs := validation.IsQualifiedName(zoneNodeLabel); len(errs) > 0 {
+		return fmt.Errorf("invalid zone node label '%s': %s", zoneNodeLabel, strings.Join(errs, ", "))
+	}
+
+	for zoneIndex, zoneName := range exStatefulSet.Spec.Zones {
+		if zoneName == "" {
+			return fmt.Errorf("zone %d has no value for zone node label '%s'", zoneIndex, zoneNodeLabel)
+		}
+		if errs := validation.IsValidLabelValue(zoneName); len(errs) > 0 {
+			return fmt.Errorf("invalid zone '%s': %s", zoneName, strings.Join(errs, ", "))
+		}
+	}
+
+	return nil
+}
+
+// Validator implements inject.Decoder.
+// A decoder will be automatically injected.
+var _ inject.Decoder = &Validator{}
+
+// InjectDecoder injects the decoder.
+func (v *Validator) InjectDecoder(d types.Decoder) error {
+	v.decoder = d
+	return nil
+}
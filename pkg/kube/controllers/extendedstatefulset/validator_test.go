@@ -0,0 +1,77 @@
+package extendedstatefulset_test
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission/types"
+
+	essv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedstatefulset/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/client/clientset/versioned/scheme"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/controllers/extendedstatefulset"
+	helper "code.cloudfoundry.org/cf-operator/pkg/testhelper"
+	"code.cloudfoundry.org/cf-operator/testing"
+)
+
+var _ = Describe("Validator", func() {
+	var (
+		env           testing.Catalog
+		log           *zap.SugaredLogger
+		validator     admission.Handler
+		exStatefulSet essv1.ExtendedStatefulSet
+	)
+
+	BeforeEach(func() {
+		controllers.AddToScheme(scheme.Scheme)
+		_, log = helper.NewTestLogger()
+		validator = extendedstatefulset.NewValidator(log)
+		decoder, _ := admission.NewDecoder(scheme.Scheme)
+		validator.(inject.Decoder).InjectDecoder(decoder)
+		exStatefulSet = env.DefaultExtendedStatefulSet("foo")
+	})
+
+	act := func() types.Response {
+		exStatefulSet.TypeMeta.APIVersion = "fissile.cloudfoundry.org/v1alpha1"
+		exStatefulSet.TypeMeta.Kind = "ExtendedStatefulSet"
+		raw, err := json.Marshal(exStatefulSet)
+		Expect(err).ToNot(HaveOccurred())
+		return validator.Handle(context.Background(), types.Request{
+			AdmissionRequest: &admissionv1beta1.AdmissionRequest{
+				Object: runtime.RawExtension{Raw: raw},
+			},
+		})
+	}
+
+	It("allows an ExtendedStatefulSet without zones", func() {
+		Expect(act().Response.Allowed).To(BeTrue())
+	})
+
+	It("allows zones using the default zone node label", func() {
+		exStatefulSet.Spec.Zones = []string{"z1", "z2"}
+		Expect(act().Response.Allowed).To(BeTrue())
+	})
+
+	It("rejects a zone without a value for the zone node label", func() {
+		exStatefulSet.Spec.Zones = []string{"z1", ""}
+		resp := act()
+		Expect(resp.Response.Allowed).To(BeFalse())
+		Expect(resp.Response.Result.Message).To(ContainSubstring("zone 1 has no value"))
+	})
+
+	It("rejects an invalid zone node label", func() {
+		exStatefulSet.Spec.Zones = []string{"z1"}
+		exStatefulSet.Spec.ZoneNodeLabel = "not a label"
+		resp := act()
+		Expect(resp.Response.Allowed).To(BeFalse())
+		Expect(resp.Response.Result.Message).To(ContainSubstring("invalid zone node label"))
+	})
+})
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/versionedsecretstore"
+)
+
+// versionedSecretPruner periodically deletes old versions of versioned secrets in the watched
+// namespaces, see VersionedSecretStore.Prune
+type versionedSecretPruner struct {
+	ctx    context.Context
+	config *config.Config
+	client client.Client
+	store  versionedsecretstore.VersionedSecretStore
+}
+
+// newVersionedSecretPruner returns a pruner using the retention of the config
+func newVersionedSecretPruner(ctx context.Context, config *config.Config, client client.Client) *versionedSecretPruner {
+	return &versionedSecretPruner{
+		ctx:    ctxlog.WithFields(ctx, "controller", "versioned-secret-pruner"),
+		config: config,
+		client: client,
+		store:  versionedsecretstore.NewVersionedSecretStore(client),
+	}
+}
+
+// Start prunes the versioned secrets on every interval until the stop channel is closed
+func (p *versionedSecretPruner) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(p.config.VersionedSecretPruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.prune()
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// prune prunes the versioned secrets of all watched namespaces. Failures are logged, the next
+// interval tries again.
+func (p *versionedSecretPruner) prune() {
+	ctx, cancel := context.WithTimeout(p.ctx, p.config.CtxTimeOut)
+	defer cancel()
+
+	namespaces := p.config.WatchedNamespaces()
+	if namespaces == nil {
+		// All namespaces are watched, prune the ones holding versioned secrets
+		secrets := &corev1.SecretList{}
+		err := p.client.List(ctx, &client.ListOptions{
+			LabelSelector: labels.Set{versionedsecretstore.LabelSecretKind: versionedsecretstore.VersionSecretKind}.AsSelector(),
+		}, secrets)
+		if err != nil {
+			ctxlog.Errorf(ctx, "Failed to list versioned secrets: %s", err)
+			return
+		}
+
+		seen := map[string]struct{}{}
+		for _, secret := range secrets.Items {
+			if _, ok := seen[secret.GetNamespace()]; !ok {
+				seen[secret.GetNamespace()] = struct{}{}
+				namespaces = append(namespaces, secret.GetNamespace())
+			}
+		}
+	}
+
+	for _, namespace := range namespaces {
+		err := p.store.Prune(ctx, namespace, p.config.VersionedSecretRetention)
+		if err != nil {
+			ctxlog.Errorf(ctx, "Failed to prune versioned secrets in namespace %s: %s", namespace, err)
+		}
+	}
+}
@@ -1,13 +1,19 @@
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
 	"fmt"
 	"net"
 	"net/url"
 	"path"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/afero"
@@ -16,91 +22,83 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	machinerytypes "k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	webhooktypes "sigs.k8s.io/controller-runtime/pkg/webhook/types"
 
 	"code.cloudfoundry.org/cf-operator/pkg/credsgen"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
 )
 
+const (
+	// webhookSecretName is the name of the secret holding the webhook server certificates
+	webhookSecretName = "cf-operator-webhook-server-cert"
+	// webhookCertRenewBefore is the remaining validity at which certificates are renewed
+	webhookCertRenewBefore = 30 * 24 * time.Hour
+)
+
 // WebhookConfig generates certificates and the configuration for the webhook server
 type WebhookConfig struct {
-	ConfigName    string
-	CertDir       string
-	Certificate   []byte
-	Key           []byte
-	CaCertificate []byte
-	CaKey         []byte
+	MutatingConfigName   string
+	ValidatingConfigName string
+	CertDir              string
+	Certificate          []byte
+	Key                  []byte
+	CaCertificate        []byte
+	CaKey                []byte
+	// NextCaCertificate is added to the CA bundle before the serving certificate is
+	// switched to one signed by it
+	NextCaCertificate []byte
+	// PreviousCaCertificate is kept in the CA bundle after the CA was renewed, until all
+	// replicas serve a certificate signed by the new CA
+	PreviousCaCertificate []byte
 
 	client    client.Client
 	config    *config.Config
 	generator credsgen.Generator
+	webhooks  []*admission.Webhook
+
+	// mu guards the certificates, which are replaced while the server is serving them
+	mu          sync.RWMutex
+	servingCert *tls.Certificate
 }
 
 // NewWebhookConfig returns a new WebhookConfig
-func NewWebhookConfig(c client.Client, config *config.Config, generator credsgen.Generator, configName string) *WebhookConfig {
+func NewWebhookConfig(c client.Client, config *config.Config, generator credsgen.Generator, mutatingConfigName string, validatingConfigName string) *WebhookConfig {
 	return &WebhookConfig{
-		ConfigName: configName,
-		CertDir:    "/tmp/cf-operator-certs",
-		client:     c,
-		config:     config,
-		generator:  generator,
+		MutatingConfigName:   mutatingConfigName,
+		ValidatingConfigName: validatingConfigName,
+		CertDir:              "/tmp/cf-operator-certs",
+		client:               c,
+		config:               config,
+		generator:            generator,
 	}
 }
 
 // SetupCertificate ensures that a CA and a certificate is available for the
 // webhook server
 func (f *WebhookConfig) setupCertificate(ctx context.Context) error {
-	secretNamespacedName := machinerytypes.NamespacedName{
-		Name:      "cf-operator-webhook-server-cert",
-		Namespace: f.config.Namespace,
-	}
-
-	// We have to query for the Secret using an unstructured object because the cache for the structured
-	// client is not initialized yet at this point in time. See https://github.com/kubernetes-sigs/controller-runtime/issues/180
-	secret := &unstructured.Unstructured{}
-	secret.SetGroupVersionKind(schema.GroupVersionKind{
-		Group:   "",
-		Kind:    "Secret",
-		Version: "v1",
-	})
-
-	err := f.client.Get(ctx, secretNamespacedName, secret)
+	secret, err := f.getSecret(ctx)
 	if err != nil && apierrors.IsNotFound(err) {
 		ctxlog.Info(ctx, "Creating webhook server certificate")
 
-		// Generate CA
-		caRequest := credsgen.CertificateGenerationRequest{
-			CommonName: "SCF CA",
-			IsCA:       true,
-		}
-		caCert, err := f.generator.GenerateCertificate("webhook-server-ca", caRequest)
+		caCert, err := f.generateCA()
 		if err != nil {
 			return err
 		}
-
-		// Generate Certificate
-		request := credsgen.CertificateGenerationRequest{
-			IsCA:       false,
-			CommonName: f.config.WebhookServerHost,
-			CA: credsgen.Certificate{
-				IsCA:        true,
-				PrivateKey:  caCert.PrivateKey,
-				Certificate: caCert.Certificate,
-			},
-		}
-		cert, err := f.generator.GenerateCertificate("webhook-server-cert", request)
+		cert, err := f.generateCertificate(caCert)
 		if err != nil {
 			return err
 		}
 
 		newSecret := &corev1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      secretNamespacedName.Name,
-				Namespace: secretNamespacedName.Namespace,
+				Name:      webhookSecretName,
+				Namespace: f.config.Namespace,
 			},
 			Data: map[string][]byte{
 				"certificate":    cert.Certificate,
@@ -110,91 +108,451 @@ func (f *WebhookConfig) setupCertificate(ctx context.Context) error {
 			},
 		}
 		err = f.client.Create(ctx, newSecret)
+		if apierrors.IsAlreadyExists(err) {
+			// Another replica of the operator created the certificate in the meantime
+			return f.setupCertificate(ctx)
+		}
 		if err != nil {
 			return err
 		}
 
-		f.CaKey = caCert.PrivateKey
-		f.CaCertificate = caCert.Certificate
-		f.Key = cert.PrivateKey
-		f.Certificate = cert.Certificate
+		f.setCertificates(newSecret.Data)
 	} else {
 		ctxlog.Info(ctx, "Not creating the webhook server certificate because it already exists")
-		data := secret.Object["data"].(map[string]interface{})
-		caKey, err := base64.StdEncoding.DecodeString(data["ca_private_key"].(string))
+		data, err := decodeSecretData(secret)
 		if err != nil {
 			return err
 		}
-		caCert, err := base64.StdEncoding.DecodeString(data["ca_certificate"].(string))
+
+		f.setCertificates(data)
+	}
+
+	err = f.writeSecretFiles()
+	if err != nil {
+		return errors.Wrap(err, "writing webhook certificate files to disk")
+	}
+
+	return nil
+}
+
+// RotateCertificate renews the serving certificate before it expires. If the CA expires
+// soon, too, it is renewed in steps, so the API server trusts the serving certificates
+// of all replicas at any time:
+//
+//  1. the new CA is added to the CA bundle of the webhook configurations
+//  2. once the bundle settled, the serving certificate is switched to one signed by the new CA
+//  3. once the certificate settled, the previous CA is removed from the bundle
+//
+// Each step is taken on a separate check, after the previous one is older than the
+// check interval. If another replica renewed the certificates already, they are adopted.
+// It returns true if the certificates changed.
+func (f *WebhookConfig) RotateCertificate(ctx context.Context) (bool, error) {
+	secret, err := f.getSecret(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "getting the webhook server certificate secret")
+	}
+	data, err := decodeSecretData(secret)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	renewBefore := now.Add(webhookCertRenewBefore)
+	settled := caBundleSettled(data, now)
+	caBundleChanged := false
+
+	switch {
+	case len(data["next_ca_certificate"]) > 0 && settled:
+		ctxlog.Info(ctx, "Switching the webhook server certificate to the renewed CA")
+		// The bundle published on the previous step contains the new CA, make sure it
+		// is in place before serving a certificate signed by it
+		err = f.updateCABundle(ctx, bundleOf(data["ca_certificate"], data["next_ca_certificate"], data["previous_ca_certificate"]))
 		if err != nil {
-			return err
+			return false, err
 		}
-		key, err := base64.StdEncoding.DecodeString(data["private_key"].(string))
+
+		data["previous_ca_certificate"] = data["ca_certificate"]
+		data["ca_certificate"] = data["next_ca_certificate"]
+		data["ca_private_key"] = data["next_ca_private_key"]
+		delete(data, "next_ca_certificate")
+		delete(data, "next_ca_private_key")
+		err = f.renewCertificate(data)
 		if err != nil {
-			return err
+			return false, err
 		}
-		cert, err := base64.StdEncoding.DecodeString(data["certificate"].(string))
+		data["ca_bundle_updated_at"] = []byte(now.Format(time.RFC3339))
+	case len(data["next_ca_certificate"]) == 0 && expiresBefore(data["ca_certificate"], renewBefore):
+		ctxlog.Info(ctx, "Renewing the webhook server CA")
+		caCert, err := f.generateCA()
 		if err != nil {
-			return err
+			return false, err
+		}
+		data["next_ca_certificate"] = caCert.Certificate
+		data["next_ca_private_key"] = caCert.PrivateKey
+		data["ca_bundle_updated_at"] = []byte(now.Format(time.RFC3339))
+		caBundleChanged = true
+	case len(data["previous_ca_certificate"]) > 0 && settled:
+		ctxlog.Info(ctx, "Removing the previous webhook server CA from the CA bundle")
+		delete(data, "previous_ca_certificate")
+		delete(data, "ca_bundle_updated_at")
+		caBundleChanged = true
+	case expiresBefore(data["certificate"], renewBefore) && len(data["next_ca_certificate"]) == 0:
+		ctxlog.Info(ctx, "Renewing webhook server certificate")
+		err = f.renewCertificate(data)
+		if err != nil {
+			return false, err
 		}
+	default:
+		if !f.certificatesChanged(data) {
+			return false, nil
+		}
+
+		ctxlog.Info(ctx, "Loading the webhook server certificate renewed by another replica")
+		f.setCertificates(data)
+		return true, f.writeSecretFiles()
+	}
 
-		f.CaKey = caKey
-		f.CaCertificate = caCert
-		f.Key = key
-		f.Certificate = cert
+	encoded := map[string]interface{}{}
+	for key, value := range data {
+		encoded[key] = base64.StdEncoding.EncodeToString(value)
+	}
+	secret.Object["data"] = encoded
+	err = f.client.Update(ctx, secret)
+	if apierrors.IsConflict(err) {
+		// Another replica of the operator renewed the certificate in the meantime
+		return f.RotateCertificate(ctx)
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "updating the webhook server certificate secret")
 	}
 
+	f.setCertificates(data)
 	err = f.writeSecretFiles()
 	if err != nil {
-		return errors.Wrap(err, "writing webhook certificate files to disk")
+		return false, errors.Wrap(err, "writing webhook certificate files to disk")
 	}
 
+	if caBundleChanged {
+		err = f.updateCABundle(ctx, f.caBundle())
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// renewCertificate replaces the serving certificate in the secret data with a new one,
+// signed by the CA in the data
+func (f *WebhookConfig) renewCertificate(data map[string][]byte) error {
+	cert, err := f.generateCertificate(credsgen.Certificate{
+		IsCA:        true,
+		Certificate: data["ca_certificate"],
+		PrivateKey:  data["ca_private_key"],
+	})
+	if err != nil {
+		return err
+	}
+	data["certificate"] = cert.Certificate
+	data["private_key"] = cert.PrivateKey
 	return nil
 }
 
+// certificatesChanged returns true if the secret data holds other certificates than the
+// ones in use
+func (f *WebhookConfig) certificatesChanged(data map[string][]byte) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return !bytes.Equal(data["certificate"], f.Certificate) ||
+		!bytes.Equal(data["ca_certificate"], f.CaCertificate) ||
+		!bytes.Equal(data["next_ca_certificate"], f.NextCaCertificate) ||
+		!bytes.Equal(data["previous_ca_certificate"], f.PreviousCaCertificate)
+}
+
+// caBundleSettled returns true if the last change of the CA bundle is older than the
+// interval in which replicas check the certificates, i.e. every replica and the API
+// server had a chance to pick it up
+func caBundleSettled(data map[string][]byte, now time.Time) bool {
+	updatedAt, err := time.Parse(time.RFC3339, string(data["ca_bundle_updated_at"]))
+	if err != nil {
+		return true
+	}
+	return now.Sub(updatedAt) >= webhookCertCheckInterval
+}
+
+// getCertificate returns the current serving certificate for a TLS handshake
+func (f *WebhookConfig) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	f.mu.RLock()
+	cert := f.servingCert
+	f.mu.RUnlock()
+	if cert != nil {
+		return cert, nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.servingCert == nil {
+		keyPair, err := tls.X509KeyPair(f.Certificate, f.Key)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading the webhook server certificate")
+		}
+		f.servingCert = &keyPair
+	}
+	return f.servingCert, nil
+}
+
+// getSecret gets the secret holding the webhook server certificates
+func (f *WebhookConfig) getSecret(ctx context.Context) (*unstructured.Unstructured, error) {
+	// We have to query for the Secret using an unstructured object because the cache for the structured
+	// client is not initialized yet at this point in time. See https://github.com/kubernetes-sigs/controller-runtime/issues/180
+	secret := &unstructured.Unstructured{}
+	secret.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "",
+		Kind:    "Secret",
+		Version: "v1",
+	})
+
+	err := f.client.Get(ctx, machinerytypes.NamespacedName{Name: webhookSecretName, Namespace: f.config.Namespace}, secret)
+	return secret, err
+}
+
+// setCertificates replaces the certificates with the ones from the secret data
+func (f *WebhookConfig) setCertificates(data map[string][]byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.CaKey = data["ca_private_key"]
+	f.CaCertificate = data["ca_certificate"]
+	f.NextCaCertificate = data["next_ca_certificate"]
+	f.PreviousCaCertificate = data["previous_ca_certificate"]
+	f.Key = data["private_key"]
+	f.Certificate = data["certificate"]
+	f.servingCert = nil
+}
+
+func (f *WebhookConfig) generateCA() (credsgen.Certificate, error) {
+	request := credsgen.CertificateGenerationRequest{
+		CommonName: "SCF CA",
+		IsCA:       true,
+	}
+	return f.generator.GenerateCertificate("webhook-server-ca", request)
+}
+
+func (f *WebhookConfig) generateCertificate(caCert credsgen.Certificate) (credsgen.Certificate, error) {
+	request := credsgen.CertificateGenerationRequest{
+		IsCA:       false,
+		CommonName: f.config.WebhookServerHost,
+		CA: credsgen.Certificate{
+			IsCA:        true,
+			PrivateKey:  caCert.PrivateKey,
+			Certificate: caCert.Certificate,
+		},
+	}
+	if f.config.WebhookServiceName != "" {
+		// The API server verifies the certificate against the service's DNS name
+		service := f.config.WebhookServiceName
+		namespace := f.config.Namespace
+		request.CommonName = fmt.Sprintf("%s.%s.svc", service, namespace)
+		request.AlternativeNames = []string{service, fmt.Sprintf("%s.%s", service, namespace)}
+	}
+	return f.generator.GenerateCertificate("webhook-server-cert", request)
+}
+
+// caBundle returns the CA certificates the API server trusts for the webhook server
+func (f *WebhookConfig) caBundle() []byte {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return bundleOf(f.CaCertificate, f.NextCaCertificate, f.PreviousCaCertificate)
+}
+
+// bundleOf concatenates the PEM encoded CA certificates, skipping missing and expired ones.
+// The first certificate is always included.
+func bundleOf(caCert []byte, otherCACerts ...[]byte) []byte {
+	bundle := append([]byte{}, caCert...)
+	for _, other := range otherCACerts {
+		if len(other) == 0 || expiresBefore(other, time.Now()) {
+			continue
+		}
+		if len(bundle) > 0 && bundle[len(bundle)-1] != '\n' {
+			bundle = append(bundle, '\n')
+		}
+		bundle = append(bundle, other...)
+	}
+	return bundle
+}
+
+// decodeSecretData returns the base64 decoded data of an unstructured secret
+func decodeSecretData(secret *unstructured.Unstructured) (map[string][]byte, error) {
+	data, ok := secret.Object["data"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("secret '%s' has no data", secret.GetName())
+	}
+
+	result := map[string][]byte{}
+	for key, value := range data {
+		encoded, _ := value.(string)
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.Wrapf(err, "decoding key '%s' of secret '%s'", key, secret.GetName())
+		}
+		result[key] = decoded
+	}
+	return result, nil
+}
+
+// expiresBefore returns true if the PEM encoded certificate expires before the given time.
+// Certificates which can not be parsed are treated as expired.
+func expiresBefore(pemCert []byte, t time.Time) bool {
+	block, _ := pem.Decode(pemCert)
+	if block == nil {
+		return true
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return true
+	}
+	return cert.NotAfter.Before(t)
+}
+
 func (f *WebhookConfig) generateWebhookServerConfig(ctx context.Context, webhooks []*admission.Webhook) error {
-	if len(f.CaCertificate) == 0 {
+	caBundle := f.caBundle()
+	if len(caBundle) == 0 {
 		return fmt.Errorf("can not create a webhook server config with an empty ca certificate")
 	}
+	f.webhooks = webhooks
 
-	config := &admissionregistrationv1beta1.MutatingWebhookConfiguration{
+	mutatingConfig := &admissionregistrationv1beta1.MutatingWebhookConfiguration{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      f.ConfigName,
+			Name:      f.MutatingConfigName,
+			Namespace: f.config.Namespace,
+		},
+	}
+	validatingConfig := &admissionregistrationv1beta1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      f.ValidatingConfigName,
 			Namespace: f.config.Namespace,
 		},
 	}
 
 	for _, webhook := range webhooks {
-		url := url.URL{
-			Scheme: "https",
-			Host:   net.JoinHostPort(f.config.WebhookServerHost, strconv.Itoa(int(f.config.WebhookServerPort))),
-			Path:   webhook.Path,
-		}
-		urlString := url.String()
 		wh := admissionregistrationv1beta1.Webhook{
 			Name:              webhook.GetName(),
 			Rules:             webhook.Rules,
 			FailurePolicy:     webhook.FailurePolicy,
 			NamespaceSelector: webhook.NamespaceSelector,
-			ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{
-				CABundle: f.CaCertificate,
-				URL:      &urlString,
-			},
+			ClientConfig:      f.clientConfig(webhook.Path, caBundle),
 		}
 
-		config.Webhooks = append(config.Webhooks, wh)
+		switch webhook.Type {
+		case webhooktypes.WebhookTypeMutating:
+			mutatingConfig.Webhooks = append(mutatingConfig.Webhooks, wh)
+		case webhooktypes.WebhookTypeValidating:
+			validatingConfig.Webhooks = append(validatingConfig.Webhooks, wh)
+		default:
+			return fmt.Errorf("webhook '%s' has an unknown type", webhook.GetName())
+		}
 	}
 
-	f.client.Delete(ctx, config)
-	err := f.client.Create(ctx, config)
-	if err != nil {
-		return errors.Wrap(err, "generating the webhook configuration")
+	if len(mutatingConfig.Webhooks) > 0 {
+		f.client.Delete(ctx, mutatingConfig)
+		err := f.client.Create(ctx, mutatingConfig)
+		// Every replica of the operator generates the same configuration
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return errors.Wrap(err, "generating the mutating webhook configuration")
+		}
+	}
+
+	if len(validatingConfig.Webhooks) > 0 {
+		f.client.Delete(ctx, validatingConfig)
+		err := f.client.Create(ctx, validatingConfig)
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return errors.Wrap(err, "generating the validating webhook configuration")
+		}
+	}
+
+	return nil
+}
+
+// updateCABundle updates the CA bundle of the existing webhook configurations in place,
+// so the webhooks stay registered while the certificates are rotated
+func (f *WebhookConfig) updateCABundle(ctx context.Context, caBundle []byte) error {
+	configs := []struct {
+		name   string
+		kind   string
+		object runtime.Object
+	}{
+		{f.MutatingConfigName, "MutatingWebhookConfiguration", &admissionregistrationv1beta1.MutatingWebhookConfiguration{}},
+		{f.ValidatingConfigName, "ValidatingWebhookConfiguration", &admissionregistrationv1beta1.ValidatingWebhookConfiguration{}},
+	}
+
+	for _, config := range configs {
+		// Read without the cache, like the secret, which doesn't watch webhook configurations
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(admissionregistrationv1beta1.SchemeGroupVersion.WithKind(config.kind))
+		err := f.client.Get(ctx, machinerytypes.NamespacedName{Name: config.name}, u)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "getting the webhook configuration '%s'", config.name)
+		}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, config.object)
+		if err != nil {
+			return errors.Wrapf(err, "converting the webhook configuration '%s'", config.name)
+		}
+
+		switch c := config.object.(type) {
+		case *admissionregistrationv1beta1.MutatingWebhookConfiguration:
+			for i := range c.Webhooks {
+				c.Webhooks[i].ClientConfig.CABundle = caBundle
+			}
+		case *admissionregistrationv1beta1.ValidatingWebhookConfiguration:
+			for i := range c.Webhooks {
+				c.Webhooks[i].ClientConfig.CABundle = caBundle
+			}
+		}
+
+		err = f.client.Update(ctx, config.object)
+		if err != nil {
+			return errors.Wrapf(err, "updating the CA bundle of the webhook configuration '%s'", config.name)
+		}
 	}
 
 	return nil
 }
 
+// clientConfig returns how the API server reaches a webhook, either through the webhook
+// service or by URL
+func (f *WebhookConfig) clientConfig(path string, caBundle []byte) admissionregistrationv1beta1.WebhookClientConfig {
+	if f.config.WebhookServiceName != "" {
+		return admissionregistrationv1beta1.WebhookClientConfig{
+			CABundle: caBundle,
+			Service: &admissionregistrationv1beta1.ServiceReference{
+				Namespace: f.config.Namespace,
+				Name:      f.config.WebhookServiceName,
+				Path:      &path,
+			},
+		}
+	}
+
+	url := url.URL{
+		Scheme: "https",
+		Host:   net.JoinHostPort(f.config.WebhookServerHost, strconv.Itoa(int(f.config.WebhookServerPort))),
+		Path:   path,
+	}
+	urlString := url.String()
+	return admissionregistrationv1beta1.WebhookClientConfig{
+		CABundle: caBundle,
+		URL:      &urlString,
+	}
+}
+
 func (f *WebhookConfig) writeSecretFiles() error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
 	if exists, _ := afero.DirExists(f.config.Fs, f.CertDir); !exists {
 		err := f.config.Fs.Mkdir(f.CertDir, 0700)
 		if err != nil {
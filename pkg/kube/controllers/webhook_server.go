@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
+)
+
+// webhookCertCheckInterval is how often the webhook server certificate is checked for renewal
+const webhookCertCheckInterval = time.Hour
+
+// webhookManager is passed to the controller-runtime webhook server. The webhook server adds
+// itself to the manager for every webhook it registers, but only to get its handlers injected.
+// It is not started, webhookServer serves the handlers instead.
+type webhookManager struct {
+	manager.Manager
+}
+
+// Add injects the dependencies into the webhook server, other runnables are added to the manager
+func (m *webhookManager) Add(r manager.Runnable) error {
+	if _, ok := r.(*webhook.Server); ok {
+		return m.SetFields(r)
+	}
+	return m.Manager.Add(r)
+}
+
+// webhookServer serves the admission webhooks. The controller-runtime webhook server reads its
+// certificate from disk once, this server uses the current certificate of the webhook config
+// for every TLS handshake. It renews the certificate before it expires.
+type webhookServer struct {
+	ctx           context.Context
+	port          int32
+	mux           *http.ServeMux
+	webhookConfig *WebhookConfig
+}
+
+// newWebhookServer returns a webhook server for the given webhooks
+func newWebhookServer(ctx context.Context, port int32, webhookConfig *WebhookConfig, webhooks []*admission.Webhook) *webhookServer {
+	mux := http.NewServeMux()
+	for _, wh := range webhooks {
+		mux.Handle(wh.GetPath(), wh.Handler())
+	}
+
+	return &webhookServer{
+		ctx:           ctx,
+		port:          port,
+		mux:           mux,
+		webhookConfig: webhookConfig,
+	}
+}
+
+// Start serves the webhooks until the stop channel is closed
+func (s *webhookServer) Start(stop <-chan struct{}) error {
+	server := &http.Server{
+		Addr:      fmt.Sprintf(":%d", s.port),
+		Handler:   s.mux,
+		TLSConfig: &tls.Config{GetCertificate: s.webhookConfig.getCertificate},
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		ctxlog.Infof(s.ctx, "Starting the webhook server on port %d", s.port)
+		errCh <- server.ListenAndServeTLS("", "")
+	}()
+
+	ticker := time.NewTicker(webhookCertCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			changed, err := s.webhookConfig.RotateCertificate(s.ctx)
+			if err != nil {
+				// The certificate is still valid for a while, try again on the next check
+				ctxlog.Errorf(s.ctx, "Failed to renew the webhook server certificate: %s", err)
+				continue
+			}
+			if changed {
+				ctxlog.Info(s.ctx, "Webhook server certificate renewed")
+			}
+		case <-stop:
+			return server.Shutdown(context.Background())
+		case err := <-errCh:
+			return err
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package errand
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/retry"
+
+	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/client/clientset/versioned"
+)
+
+// LogStreamer follows the log of a container until the container terminates
+type LogStreamer func(namespace, podName, containerName string) (io.ReadCloser, error)
+
+// NewLogStreamer returns a LogStreamer which follows the logs via the Kubernetes API
+func NewLogStreamer(client corev1client.CoreV1Interface) LogStreamer {
+	return func(namespace, podName, containerName string) (io.ReadCloser, error) {
+		options := corev1.PodLogOptions{
+			Container: containerName,
+			Follow:    true,
+		}
+		return client.Pods(namespace).GetLogs(podName, &options).Stream()
+	}
+}
+
+// Runner runs errands, i.e. extended jobs with the manual trigger strategy, and follows
+// them until they finished, like `bosh run-errand`
+type Runner struct {
+	clientset          kubernetes.Interface
+	versionedClientset versioned.Interface
+	streamLogs         LogStreamer
+	pollInterval       time.Duration
+	timeout            time.Duration
+	out                io.Writer
+	errOut             io.Writer
+}
+
+// NewRunner returns a new errand runner. The logs of the errand are written to out, progress
+// messages to errOut. The timeout applies to every wait, i.e. for the job, for each
+// container to start and for the result. A timeout of 0 waits forever.
+func NewRunner(
+	clientset kubernetes.Interface,
+	versionedClientset versioned.Interface,
+	streamLogs LogStreamer,
+	pollInterval time.Duration,
+	timeout time.Duration,
+	out io.Writer,
+	errOut io.Writer,
+) *Runner {
+	return &Runner{
+		clientset:          clientset,
+		versionedClientset: versionedClientset,
+		streamLogs:         streamLogs,
+		pollInterval:       pollInterval,
+		timeout:            timeout,
+		out:                out,
+		errOut:             errOut,
+	}
+}
+
+// Run starts a run of the errand, streams the logs of its containers and waits until the
+// run finished. It returns the exit code of the errand.
+func (r *Runner) Run(namespace string, name string, overrides []ejv1.ContainerOverride) (int, error) {
+	eJob, runID, err := r.Start(namespace, name, overrides)
+	if err != nil {
+		return 0, err
+	}
+	fmt.Fprintf(r.errOut, "Requested run '%s' of errand '%s'\n", runID, name)
+
+	job, err := r.WaitForJob(namespace, name, runID)
+	if err != nil {
+		return 0, err
+	}
+	fmt.Fprintf(r.errOut, "Started job '%s'\n", job.Name)
+
+	containers := []string{}
+	for _, c := range eJob.Spec.Template.Spec.Containers {
+		containers = append(containers, c.Name)
+	}
+	err = r.FollowLogs(namespace, job.Name, containers)
+	if err != nil {
+		return 0, err
+	}
+
+	run, err := r.WaitForRun(namespace, name, job.Name)
+	if err != nil {
+		return 0, err
+	}
+	fmt.Fprintf(r.errOut, "Errand '%s' %s\n", name, run.Result)
+
+	if run.Result == ejv1.RunSucceeded {
+		return 0, nil
+	}
+	return r.exitCode(namespace, job.Name), nil
+}
+
+// Start requests a run of the errand by setting its trigger strategy to 'now'. The
+// overrides only apply to this run, overrides without a name apply to the first
+// container. It returns the ID of the run, which the operator adds to the job it creates.
+func (r *Runner) Start(namespace string, name string, overrides []ejv1.ContainerOverride) (*ejv1.ExtendedJob, string, error) {
+	client := r.versionedClientset.ExtendedjobV1alpha1().ExtendedJobs(namespace)
+	runID := strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	var eJob *ejv1.ExtendedJob
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		eJob, err = client.Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		switch eJob.Spec.Trigger.Strategy {
+		case ejv1.TriggerManual:
+		case ejv1.TriggerNow:
+			return errors.Errorf("a run of errand '%s' was requested already", name)
+		default:
+			return errors.Errorf("'%s' is not an errand, its trigger strategy is '%s'", name, eJob.Spec.Trigger.Strategy)
+		}
+
+		// Overrides without a container name apply to the first container
+		for i := range overrides {
+			if overrides[i].Name == "" && len(eJob.Spec.Template.Spec.Containers) > 0 {
+				overrides[i].Name = eJob.Spec.Template.Spec.Containers[0].Name
+			}
+			if !hasContainer(eJob, overrides[i].Name) {
+				return errors.Errorf("errand '%s' has no container '%s'", name, overrides[i].Name)
+			}
+		}
+		overridesJSON, err := json.Marshal(overrides)
+		if err != nil {
+			return errors.Wrap(err, "could not marshal overrides")
+		}
+
+		annotations := eJob.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[ejv1.AnnotationRunID] = runID
+		delete(annotations, ejv1.AnnotationRunOverrides)
+		if len(overrides) > 0 {
+			annotations[ejv1.AnnotationRunOverrides] = string(overridesJSON)
+		}
+		eJob.SetAnnotations(annotations)
+		eJob.Spec.Trigger.Strategy = ejv1.TriggerNow
+
+		eJob, err = client.Update(eJob)
+		return err
+	})
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "could not start errand '%s'", name)
+	}
+
+	return eJob, runID, nil
+}
+
+// WaitForJob waits until the operator created the job of the run
+func (r *Runner) WaitForJob(namespace string, name string, runID string) (*batchv1.Job, error) {
+	var job *batchv1.Job
+	err := r.poll(func() (bool, error) {
+		jobs, err := r.clientset.BatchV1().Jobs(namespace).List(metav1.ListOptions{
+			LabelSelector: "ejob-name=" + name,
+		})
+		if err != nil {
+			return false, errors.Wrapf(err, "could not list jobs of errand '%s'", name)
+		}
+		for i := range jobs.Items {
+			if jobs.Items[i].GetAnnotations()[ejv1.AnnotationRunID] == runID {
+				job = &jobs.Items[i]
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "waiting for the job of run '%s'", runID)
+	}
+	return job, nil
+}
+
+// FollowLogs streams the logs of the containers of the job's first pod, one container
+// after another
+func (r *Runner) FollowLogs(namespace string, jobName string, containers []string) error {
+	var pod *corev1.Pod
+	err := r.poll(func() (bool, error) {
+		pods, err := r.jobPods(namespace, jobName)
+		if err != nil {
+			return false, err
+		}
+		if len(pods) > 0 {
+			pod = &pods[0]
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "waiting for the pod of job '%s'", jobName)
+	}
+
+	podName := pod.Name
+	for _, container := range containers {
+		started := false
+		err = r.poll(func() (bool, error) {
+			pod, err = r.clientset.CoreV1().Pods(namespace).Get(podName, metav1.GetOptions{})
+			if err != nil {
+				return false, errors.Wrapf(err, "could not get pod '%s'", podName)
+			}
+			status := containerStatus(pod, container)
+			started = status != nil && (status.State.Running != nil || status.State.Terminated != nil)
+			// Containers might never start, e.g. if a previous init container failed
+			finished := pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+			return started || finished, nil
+		})
+		if err != nil {
+			return errors.Wrapf(err, "waiting for container '%s' of pod '%s'", container, podName)
+		}
+		if !started {
+			fmt.Fprintf(r.errOut, "Container '%s' did not run\n", container)
+			continue
+		}
+
+		if len(containers) > 1 {
+			fmt.Fprintf(r.errOut, "Logs of container '%s':\n", container)
+		}
+		err = r.copyLogs(namespace, podName, container)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WaitForRun waits until the job finished and its run is recorded in the status of the errand
+func (r *Runner) WaitForRun(namespace string, name string, jobName string) (*ejv1.Run, error) {
+	var run *ejv1.Run
+	err := r.poll(func() (bool, error) {
+		eJob, err := r.versionedClientset.ExtendedjobV1alpha1().ExtendedJobs(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, errors.Wrapf(err, "could not get errand '%s'", name)
+		}
+		for i := range eJob.Status.Runs {
+			if eJob.Status.Runs[i].JobName == jobName {
+				run = &eJob.Status.Runs[i]
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "waiting for job '%s' to finish", jobName)
+	}
+	return run, nil
+}
+
+// exitCode returns the first non-zero exit code of the containers of the failed job's
+// pods. It returns 1 if there is none, e.g. because the job ran into its deadline.
+func (r *Runner) exitCode(namespace string, jobName string) int {
+	pods, err := r.jobPods(namespace, jobName)
+	if err != nil {
+		fmt.Fprintf(r.errOut, "Could not get the exit code: %s\n", err)
+		return 1
+	}
+
+	for _, pod := range pods {
+		statuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			if t := status.State.Terminated; t != nil && t.ExitCode != 0 {
+				return int(t.ExitCode)
+			}
+		}
+	}
+	return 1
+}
+
+// copyLogs writes the log of the container to out
+func (r *Runner) copyLogs(namespace string, podName string, container string) error {
+	logs, err := r.streamLogs(namespace, podName, container)
+	if err != nil {
+		return errors.Wrapf(err, "could not stream logs of container '%s'", container)
+	}
+	defer logs.Close()
+
+	_, err = io.Copy(r.out, logs)
+	if err != nil {
+		return errors.Wrapf(err, "could not stream logs of container '%s'", container)
+	}
+	return nil
+}
+
+// jobPods returns the pods of the job, the oldest first
+func (r *Runner) jobPods(namespace string, jobName string) ([]corev1.Pod, error) {
+	pods, err := r.clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{
+		LabelSelector: "job-name=" + jobName,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list pods of job '%s'", jobName)
+	}
+
+	items := pods.Items
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].CreationTimestamp.Before(&items[j].CreationTimestamp)
+	})
+	return items, nil
+}
+
+// poll calls condition until it returns true or the timeout of the runner expires
+func (r *Runner) poll(condition wait.ConditionFunc) error {
+	if r.timeout == 0 {
+		return wait.PollImmediateInfinite(r.pollInterval, condition)
+	}
+	return wait.PollImmediate(r.pollInterval, r.timeout, condition)
+}
+
+// hasContainer returns true if the template of the extended job has the container
+func hasContainer(eJob *ejv1.ExtendedJob, name string) bool {
+	for _, c := range eJob.Spec.Template.Spec.Containers {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// containerStatus returns the status of the container, which is an init container if
+// the output is collected from files
+func containerStatus(pod *corev1.Pod, name string) *corev1.ContainerStatus {
+	statuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+	for i := range statuses {
+		if statuses[i].Name == name {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package errand_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	ejv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/extendedjob/v1alpha1"
+	versionedfake "code.cloudfoundry.org/cf-operator/pkg/kube/client/clientset/versioned/fake"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/errand"
+	"code.cloudfoundry.org/cf-operator/testing"
+)
+
+var _ = Describe("Runner", func() {
+	var (
+		env                testing.Catalog
+		clientset          *fake.Clientset
+		versionedClientset *versionedfake.Clientset
+		tracker            clienttesting.ObjectTracker
+		runner             *errand.Runner
+		eJob               ejv1.ExtendedJob
+		out                *bytes.Buffer
+		errOut             *bytes.Buffer
+		exitCode           int32
+	)
+
+	streamLogs := func(namespace, podName, containerName string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("log of " + containerName + "\n")), nil
+	}
+
+	// operate acts like the operator, it starts the job of the errand when a run is
+	// requested and records the run once the job finished
+	operate := func(action clienttesting.Action) (bool, runtime.Object, error) {
+		updated := action.(clienttesting.UpdateAction).GetObject().(*ejv1.ExtendedJob)
+		if updated.Spec.Trigger.Strategy != ejv1.TriggerNow {
+			return false, nil, nil
+		}
+
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "errand-job",
+				Namespace:   "default",
+				Labels:      map[string]string{"ejob-name": updated.Name},
+				Annotations: map[string]string{ejv1.AnnotationRunID: updated.Annotations[ejv1.AnnotationRunID]},
+			},
+		}
+		_, err := clientset.BatchV1().Jobs("default").Create(job)
+		Expect(err).ToNot(HaveOccurred())
+
+		phase := corev1.PodSucceeded
+		result := ejv1.RunSucceeded
+		if exitCode != 0 {
+			phase = corev1.PodFailed
+			result = ejv1.RunFailed
+		}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "errand-job-pod",
+				Namespace: "default",
+				Labels:    map[string]string{"job-name": job.Name},
+			},
+			Status: corev1.PodStatus{
+				Phase: phase,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name:  "busybox",
+						State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode}},
+					},
+				},
+			},
+		}
+		_, err = clientset.CoreV1().Pods("default").Create(pod)
+		Expect(err).ToNot(HaveOccurred())
+
+		// Reactors get a copy of the action, so the run is stored here
+		updated.Status.Runs = append(updated.Status.Runs, ejv1.Run{JobName: job.Name, Result: result})
+		err = tracker.Update(action.GetResource(), updated, updated.Namespace)
+		return true, updated, err
+	}
+
+	BeforeEach(func() {
+		eJob = env.ErrandExtendedJob("errand")
+		eJob.Namespace = "default"
+		eJob.Spec.Trigger.Strategy = ejv1.TriggerManual
+		exitCode = 0
+	})
+
+	JustBeforeEach(func() {
+		clientset = fake.NewSimpleClientset()
+		// The tracker of the fake clientset is not exposed, so it is set up here
+		scheme := runtime.NewScheme()
+		Expect(versionedfake.AddToScheme(scheme)).To(Succeed())
+		tracker = clienttesting.NewObjectTracker(scheme, serializer.NewCodecFactory(scheme).UniversalDecoder())
+		versionedClientset = &versionedfake.Clientset{}
+		versionedClientset.AddReactor("*", "*", clienttesting.ObjectReaction(tracker))
+
+		// Created via the client, the generated fake stores extended jobs by its own resource
+		_, err := versionedClientset.ExtendedjobV1alpha1().ExtendedJobs("default").Create(&eJob)
+		Expect(err).ToNot(HaveOccurred())
+		versionedClientset.PrependReactor("update", "extendedjobs", operate)
+		out = &bytes.Buffer{}
+		errOut = &bytes.Buffer{}
+		runner = errand.NewRunner(clientset, versionedClientset, streamLogs, time.Millisecond, time.Second, out, errOut)
+	})
+
+	Describe("Run", func() {
+		It("streams the logs and returns the exit code of a succeeded errand", func() {
+			code, err := runner.Run("default", "errand", nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(code).To(Equal(0))
+			Expect(out.String()).To(Equal("log of busybox\n"))
+			Expect(errOut.String()).To(ContainSubstring("Errand 'errand' succeeded"))
+		})
+
+		Context("when the errand fails", func() {
+			BeforeEach(func() {
+				exitCode = 3
+			})
+
+			It("returns the exit code of the failed container", func() {
+				code, err := runner.Run("default", "errand", nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(code).To(Equal(3))
+				Expect(errOut.String()).To(ContainSubstring("Errand 'errand' failed"))
+			})
+		})
+	})
+
+	Describe("Start", func() {
+		It("requests a run of the errand", func() {
+			_, runID, err := runner.Start("default", "errand", nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			updated, err := versionedClientset.ExtendedjobV1alpha1().ExtendedJobs("default").Get("errand", metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updated.Spec.Trigger.Strategy).To(Equal(ejv1.TriggerNow))
+			Expect(updated.Annotations).To(HaveKeyWithValue(ejv1.AnnotationRunID, runID))
+			Expect(updated.Annotations).ToNot(HaveKey(ejv1.AnnotationRunOverrides))
+		})
+
+		It("stores the overrides for the run", func() {
+			overrides := []ejv1.ContainerOverride{
+				{
+					Name: "busybox",
+					Args: []string{"--dry-run"},
+					Env:  []corev1.EnvVar{{Name: "DEBUG", Value: "1"}},
+				},
+			}
+			_, _, err := runner.Start("default", "errand", overrides)
+			Expect(err).ToNot(HaveOccurred())
+
+			updated, err := versionedClientset.ExtendedjobV1alpha1().ExtendedJobs("default").Get("errand", metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			stored := []ejv1.ContainerOverride{}
+			Expect(json.Unmarshal([]byte(updated.Annotations[ejv1.AnnotationRunOverrides]), &stored)).To(Succeed())
+			Expect(stored).To(Equal(overrides))
+		})
+
+		It("applies overrides without a container name to the first container", func() {
+			_, _, err := runner.Start("default", "errand", []ejv1.ContainerOverride{{Args: []string{"--dry-run"}}})
+			Expect(err).ToNot(HaveOccurred())
+
+			updated, err := versionedClientset.ExtendedjobV1alpha1().ExtendedJobs("default").Get("errand", metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updated.Annotations[ejv1.AnnotationRunOverrides]).To(ContainSubstring(`"name":"busybox"`))
+		})
+
+		It("fails if a container to override does not exist", func() {
+			_, _, err := runner.Start("default", "errand", []ejv1.ContainerOverride{{Name: "missing"}})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("errand 'errand' has no container 'missing'"))
+		})
+
+		Context("when the extended job is not an errand", func() {
+			BeforeEach(func() {
+				eJob.Spec.Trigger.Strategy = ejv1.TriggerOnce
+			})
+
+			It("fails", func() {
+				_, _, err := runner.Start("default", "errand", nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("'errand' is not an errand, its trigger strategy is 'once'"))
+			})
+		})
+
+		Context("when a run was requested already", func() {
+			BeforeEach(func() {
+				eJob.Spec.Trigger.Strategy = ejv1.TriggerNow
+			})
+
+			It("fails", func() {
+				_, _, err := runner.Start("default", "errand", nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("a run of errand 'errand' was requested already"))
+			})
+		})
+	})
+})
@@ -0,0 +1,92 @@
+package operator
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// newNamespacesCache returns a function creating a cluster wide cache, whose informers only
+// pass on events for the given namespaces and for cluster scoped objects.
+func newNamespacesCache(namespaces []string) func(*rest.Config, cache.Options) (cache.Cache, error) {
+	watched := map[string]bool{}
+	for _, namespace := range namespaces {
+		watched[namespace] = true
+	}
+
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		opts.Namespace = ""
+		c, err := cache.New(config, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &namespacesCache{Cache: c, watched: watched}, nil
+	}
+}
+
+// namespacesCache filters the events of its informers by namespace
+type namespacesCache struct {
+	cache.Cache
+	watched map[string]bool
+}
+
+// GetInformer returns a filtering informer for the object
+func (c *namespacesCache) GetInformer(obj runtime.Object) (toolscache.SharedIndexInformer, error) {
+	informer, err := c.Cache.GetInformer(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &namespacesInformer{SharedIndexInformer: informer, watched: c.watched}, nil
+}
+
+// GetInformerForKind returns a filtering informer for the kind
+func (c *namespacesCache) GetInformerForKind(gvk schema.GroupVersionKind) (toolscache.SharedIndexInformer, error) {
+	informer, err := c.Cache.GetInformerForKind(gvk)
+	if err != nil {
+		return nil, err
+	}
+	return &namespacesInformer{SharedIndexInformer: informer, watched: c.watched}, nil
+}
+
+// namespacesInformer only passes events of watched namespaces to its handlers
+type namespacesInformer struct {
+	toolscache.SharedIndexInformer
+	watched map[string]bool
+}
+
+// AddEventHandler adds a filtered event handler
+func (i *namespacesInformer) AddEventHandler(handler toolscache.ResourceEventHandler) {
+	i.SharedIndexInformer.AddEventHandler(i.filter(handler))
+}
+
+// AddEventHandlerWithResyncPeriod adds a filtered event handler
+func (i *namespacesInformer) AddEventHandlerWithResyncPeriod(handler toolscache.ResourceEventHandler, resyncPeriod time.Duration) {
+	i.SharedIndexInformer.AddEventHandlerWithResyncPeriod(i.filter(handler), resyncPeriod)
+}
+
+func (i *namespacesInformer) filter(handler toolscache.ResourceEventHandler) toolscache.ResourceEventHandler {
+	return toolscache.FilteringResourceEventHandler{
+		FilterFunc: i.watches,
+		Handler:    handler,
+	}
+}
+
+// watches returns true for objects in watched namespaces and for cluster scoped objects
+func (i *namespacesInformer) watches(obj interface{}) bool {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+
+	namespace := accessor.GetNamespace()
+	return namespace == "" || i.watched[namespace]
+}
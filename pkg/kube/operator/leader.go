@@ -0,0 +1,122 @@
+package operator
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/config"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
+)
+
+const (
+	// LeaderElectionID is the name of the config map holding the leader lock
+	LeaderElectionID = "cf-operator-leader"
+	// leaderRetryPeriod is the time between tries to acquire or renew the lock
+	leaderRetryPeriod = 2 * time.Second
+)
+
+// leaderElector is used instead of the manager when adding controllers. Controllers added
+// to it are only started once this replica holds the leader lock. Everything added to the
+// manager directly, like the webhook server, runs on every replica.
+type leaderElector struct {
+	manager.Manager
+	ctx       context.Context
+	config    *config.Config
+	lock      resourcelock.Interface
+	runnables []manager.Runnable
+}
+
+// newLeaderElector returns a leader elector using a config map lock in the operator namespace
+func newLeaderElector(ctx context.Context, config *config.Config, cfg *rest.Config, mgr manager.Manager) (*leaderElector, error) {
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating the leader election client")
+	}
+
+	// Identity of the replica, must be unique
+	id, err := os.Hostname()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting the hostname for the leader election")
+	}
+	id = id + "_" + string(uuid.NewUUID())
+
+	lock, err := resourcelock.New(resourcelock.ConfigMapsResourceLock,
+		config.Namespace,
+		LeaderElectionID,
+		client.CoreV1(),
+		resourcelock.ResourceLockConfig{
+			Identity:      id,
+			EventRecorder: mgr.GetRecorder(LeaderElectionID),
+		})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating the leader election lock")
+	}
+
+	return &leaderElector{
+		Manager: mgr,
+		ctx:     ctx,
+		config:  config,
+		lock:    lock,
+	}, nil
+}
+
+// Add sets dependencies on the runnable and starts it after winning the leader election
+func (e *leaderElector) Add(r manager.Runnable) error {
+	if err := e.Manager.SetFields(r); err != nil {
+		return err
+	}
+	e.runnables = append(e.runnables, r)
+	return nil
+}
+
+// Start runs the leader election until stop is closed. It returns an error if the
+// leadership is lost, so the operator exits instead of running controllers twice.
+func (e *leaderElector) Start(stop <-chan struct{}) error {
+	errChan := make(chan error, len(e.runnables)+1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          e.lock,
+		LeaseDuration: e.config.LeaseDuration,
+		RenewDeadline: e.config.RenewDeadline,
+		RetryPeriod:   leaderRetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(_ context.Context) {
+				ctxlog.Infof(e.ctx, "Acquired the leader lock '%s/%s', starting controllers", e.config.Namespace, LeaderElectionID)
+				for _, r := range e.runnables {
+					r := r
+					go func() {
+						errChan <- r.Start(stop)
+					}()
+				}
+			},
+			OnStoppedLeading: func() {
+				errChan <- errors.New("leader election lost")
+			},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "creating the leader elector")
+	}
+
+	ctxlog.Infof(e.ctx, "Waiting for the leader lock '%s/%s'", e.config.Namespace, LeaderElectionID)
+	go elector.Run(ctx)
+
+	select {
+	case <-stop:
+		return nil
+	case err := <-errChan:
+		return err
+	}
+}
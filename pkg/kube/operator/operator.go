@@ -15,6 +15,19 @@ import (
 
 // NewManager adds schemes, controllers and starts the manager
 func NewManager(ctx context.Context, config *config.Config, cfg *rest.Config, options manager.Options) (mgr manager.Manager, err error) {
+	// A single namespace is watched by the default cache, a list of namespaces by
+	// filtering the events of a cluster wide cache
+	namespaces := config.WatchedNamespaces()
+	switch {
+	case config.WatchAllNamespaces:
+		options.Namespace = ""
+	case len(namespaces) == 1:
+		options.Namespace = namespaces[0]
+	default:
+		options.Namespace = ""
+		options.NewCache = newNamespacesCache(namespaces)
+	}
+
 	mgr, err = manager.New(cfg, options)
 	if err != nil {
 		return
@@ -23,7 +36,6 @@ func NewManager(ctx context.Context, config *config.Config, cfg *rest.Config, op
 	log := ctxlog.ExtractLogger(ctx)
 
 	log.Info("Registering Components.")
-	config.Namespace = options.Namespace
 
 	// Setup Scheme for all resources
 	if err = controllers.AddToScheme(mgr.GetScheme()); err != nil {
@@ -35,7 +47,19 @@ func NewManager(ctx context.Context, config *config.Config, cfg *rest.Config, op
 		return
 	}
 
-	// Setup all Controllers
-	err = controllers.AddToManager(ctx, config, mgr)
+	// Setup all Controllers, with leader election they only run on the leader
+	if !config.LeaderElection {
+		err = controllers.AddToManager(ctx, config, mgr)
+		return
+	}
+
+	elector, err := newLeaderElector(ctx, config, cfg, mgr)
+	if err != nil {
+		return
+	}
+	if err = controllers.AddToManager(ctx, config, elector); err != nil {
+		return
+	}
+	err = mgr.Add(elector)
 	return
 }
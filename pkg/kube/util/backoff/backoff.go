@@ -0,0 +1,95 @@
+package backoff
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
+)
+
+// Reconciler requeues failed requests with an exponential backoff, instead of the default
+// rate limiter of the controller's queue
+type Reconciler struct {
+	reconcile.Reconciler
+
+	ctx      context.Context
+	min      time.Duration
+	max      time.Duration
+	mu       sync.Mutex
+	failures map[reconcile.Request]failure
+}
+
+// failure counts the consecutive failures of a request
+type failure struct {
+	count int
+	last  time.Time
+}
+
+// NewReconciler wraps the reconciler, if limits for the backoff are set. Otherwise the
+// reconciler is returned unchanged.
+func NewReconciler(ctx context.Context, r reconcile.Reconciler, min time.Duration, max time.Duration) reconcile.Reconciler {
+	if min <= 0 {
+		return r
+	}
+	if max < min {
+		max = min
+	}
+
+	return &Reconciler{
+		Reconciler: r,
+		ctx:        ctx,
+		min:        min,
+		max:        max,
+		failures:   map[reconcile.Request]failure{},
+	}
+}
+
+// Reconcile calls the wrapped reconciler and turns an error into a delayed requeue
+func (r *Reconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	result, err := r.Reconciler.Reconcile(request)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.forgetStale(now)
+
+	if err == nil {
+		delete(r.failures, request)
+		return result, nil
+	}
+
+	f := r.failures[request]
+	f.count++
+	f.last = now
+	r.failures[request] = f
+	delay := r.Delay(f.count)
+	ctxlog.Infof(r.ctx, "Retrying '%s' in %s after %d failures: %s", request.NamespacedName, delay, f.count, err)
+
+	return reconcile.Result{RequeueAfter: delay}, nil
+}
+
+// forgetStale drops the failures of requests which were not retried for twice the
+// maximum delay, e.g. because their object was deleted. The caller needs to hold the lock.
+func (r *Reconciler) forgetStale(now time.Time) {
+	for request, f := range r.failures {
+		if now.Sub(f.last) > 2*r.max {
+			delete(r.failures, request)
+		}
+	}
+}
+
+// Delay returns the time to wait after the given number of failures
+func (r *Reconciler) Delay(failures int) time.Duration {
+	delay := r.min
+	for i := 1; i < failures && delay < r.max; i++ {
+		delay *= 2
+	}
+	if delay > r.max {
+		delay = r.max
+	}
+	return delay
+}
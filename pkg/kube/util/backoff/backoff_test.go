@@ -0,0 +1,83 @@
+package backoff_test
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	. "code.cloudfoundry.org/cf-operator/pkg/kube/util/backoff"
+	"code.cloudfoundry.org/cf-operator/testing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type reconcileFunc func(reconcile.Request) (reconcile.Result, error)
+
+func (f reconcileFunc) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	return f(request)
+}
+
+var _ = Describe("Reconciler", func() {
+	var (
+		err        error
+		reconciler reconcile.Reconciler
+		request    reconcile.Request
+	)
+
+	BeforeEach(func() {
+		err = fmt.Errorf("failed")
+		request = reconcile.Request{NamespacedName: types.NamespacedName{Name: "foo", Namespace: "default"}}
+		wrapped := reconcileFunc(func(reconcile.Request) (reconcile.Result, error) {
+			return reconcile.Result{}, err
+		})
+		reconciler = NewReconciler(testing.NewContext(), wrapped, time.Second, 5*time.Second)
+	})
+
+	It("requeues failed requests with an increasing delay", func() {
+		for _, delay := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+			result, err := reconciler.Reconcile(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(delay))
+		}
+	})
+
+	It("resets the delay after a successful reconcile", func() {
+		reconciler.Reconcile(request)
+		reconciler.Reconcile(request)
+
+		err = nil
+		result, _ := reconciler.Reconcile(request)
+		Expect(result).To(Equal(reconcile.Result{}))
+
+		err = fmt.Errorf("failed again")
+		result, _ = reconciler.Reconcile(request)
+		Expect(result.RequeueAfter).To(Equal(time.Second))
+	})
+
+	It("forgets the failures of requests which were not retried for a while", func() {
+		wrapped := reconcileFunc(func(reconcile.Request) (reconcile.Result, error) {
+			return reconcile.Result{}, err
+		})
+		reconciler = NewReconciler(testing.NewContext(), wrapped, 10*time.Millisecond, 20*time.Millisecond)
+		reconciler.Reconcile(request)
+		reconciler.Reconcile(request)
+
+		time.Sleep(50 * time.Millisecond)
+		result, _ := reconciler.Reconcile(request)
+		Expect(result.RequeueAfter).To(Equal(10 * time.Millisecond))
+	})
+
+	Context("when no backoff is configured", func() {
+		It("returns the reconciler unchanged", func() {
+			wrapped := reconcileFunc(func(reconcile.Request) (reconcile.Result, error) {
+				return reconcile.Result{}, err
+			})
+			reconciler = NewReconciler(testing.NewContext(), wrapped, 0, 0)
+
+			_, err := reconciler.Reconcile(request)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
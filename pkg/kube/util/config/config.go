@@ -8,9 +8,87 @@ import (
 
 // Config controls the behaviour of different controllers
 type Config struct {
-	CtxTimeOut        time.Duration
-	Namespace         string
+	// CtxTimeOut is the time a single reconcile may take
+	CtxTimeOut time.Duration
+	// MaxConcurrentReconciles is the number of requests a controller handles in parallel
+	MaxConcurrentReconciles int
+	// BackoffMin is the delay before a failed request is retried for the first time. It doubles
+	// with every failure, up to BackoffMax.
+	BackoffMin time.Duration
+	BackoffMax time.Duration
+	// Controllers overrides the settings above for single controllers, by controller name
+	Controllers map[string]ControllerConfig
+	// Namespace the operator runs in, it holds the webhook certificates
+	Namespace string
+	// WatchNamespaces lists the namespaces to watch, defaults to Namespace if empty
+	WatchNamespaces []string
+	// WatchAllNamespaces watches the whole cluster, WatchNamespaces is ignored
+	WatchAllNamespaces bool
+	// LeaderElection only runs the controllers on the replica holding the leader lock
+	LeaderElection bool
+	// LeaseDuration is the time non-leaders wait before trying to acquire the lock
+	LeaseDuration time.Duration
+	// RenewDeadline is the time the leader retries renewing the lock before giving up
+	RenewDeadline time.Duration
+	// WebhookServerHost is the host the API server reaches the webhooks on, if no service is used
 	WebhookServerHost string
 	WebhookServerPort int32
-	Fs                afero.Fs
+	// WebhookServiceName is the name of a service in the operator namespace fronting the
+	// webhook server. If set, the webhooks are registered through the service.
+	WebhookServiceName string
+	// VersionedSecretRetention is the number of versions of each versioned secret kept when
+	// pruning, pruning is disabled if it is zero
+	VersionedSecretRetention int
+	// VersionedSecretPruneInterval is the time between two prunings of versioned secrets
+	VersionedSecretPruneInterval time.Duration
+	Fs                           afero.Fs
+}
+
+// ControllerConfig holds the settings which can differ between controllers. Zero values
+// are not applied.
+type ControllerConfig struct {
+	CtxTimeOut              time.Duration `mapstructure:"ctx-timeout"`
+	MaxConcurrentReconciles int           `mapstructure:"max-concurrent-reconciles"`
+}
+
+// ForController returns a copy of the config with the settings of the named controller applied
+func (c *Config) ForController(name string) *Config {
+	result := *c
+
+	controllerConfig, ok := c.Controllers[name]
+	if !ok {
+		return &result
+	}
+	if controllerConfig.CtxTimeOut > 0 {
+		result.CtxTimeOut = controllerConfig.CtxTimeOut
+	}
+	if controllerConfig.MaxConcurrentReconciles > 0 {
+		result.MaxConcurrentReconciles = controllerConfig.MaxConcurrentReconciles
+	}
+	return &result
+}
+
+// WatchedNamespaces returns the namespaces the operator watches. It returns nil if all
+// namespaces are watched.
+func (c *Config) WatchedNamespaces() []string {
+	if c.WatchAllNamespaces {
+		return nil
+	}
+	if len(c.WatchNamespaces) == 0 {
+		return []string{c.Namespace}
+	}
+	return c.WatchNamespaces
+}
+
+// Watches returns true if the operator watches the given namespace
+func (c *Config) Watches(namespace string) bool {
+	if c.WatchAllNamespaces {
+		return true
+	}
+	for _, ns := range c.WatchedNamespaces() {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,193 @@
+// Package cron parses cron expressions and calculates when they are due
+package cron
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Schedule is a parsed cron expression with the fields minute, hour, day of
+// month, month and day of week
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// A day matches if either the day of month or the day of week matches,
+	// unless one of them is '*'
+	domStar, dowStar bool
+}
+
+type bounds struct {
+	name     string
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	minutes = bounds{name: "minute", min: 0, max: 59}
+	hours   = bounds{name: "hour", min: 0, max: 23}
+	doms    = bounds{name: "day of month", min: 1, max: 31}
+	months  = bounds{name: "month", min: 1, max: 12, names: map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is accepted for Sunday, too
+	dows = bounds{name: "day of week", min: 0, max: 7, names: map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+
+	descriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+// Parse parses a cron expression like '30 2 * * 1-5' or one of the
+// descriptors @yearly, @monthly, @weekly, @daily and @hourly
+func Parse(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if expanded, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("expected 5 fields in cron expression '%s', found %d", spec, len(fields))
+	}
+
+	s := &Schedule{}
+	var err error
+	if s.minute, err = parseField(fields[0], minutes); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[1], hours); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[2], doms); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[3], months); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[4], dows); err != nil {
+		return nil, err
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[2] == "*" || fields[2] == "?"
+	s.dowStar = fields[4] == "*" || fields[4] == "?"
+
+	return s, nil
+}
+
+// parseField returns the set of values of a comma separated list of values,
+// ranges and steps as a bit set
+func parseField(field string, b bounds) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, uint(1)
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.ParseUint(part[i+1:], 10, 8)
+			if err != nil || s == 0 {
+				return 0, errors.Errorf("invalid step '%s' in %s field '%s'", part[i+1:], b.name, field)
+			}
+			rangePart, step = part[:i], uint(s)
+		}
+
+		var start, end uint
+		switch {
+		case rangePart == "*" || rangePart == "?":
+			start, end = b.min, b.max
+		case strings.Contains(rangePart, "-"):
+			bound := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if start, err = parseValue(bound[0], b); err != nil {
+				return 0, err
+			}
+			if end, err = parseValue(bound[1], b); err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			if start, err = parseValue(rangePart, b); err != nil {
+				return 0, err
+			}
+			end = start
+			// A single value with a step, like 5/15, runs until the end of the range
+			if step > 1 {
+				end = b.max
+			}
+		}
+
+		if start > end {
+			return 0, errors.Errorf("invalid range '%s' in %s field '%s'", rangePart, b.name, field)
+		}
+		for v := start; v <= end; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func parseValue(value string, b bounds) (uint, error) {
+	if v, ok := b.names[strings.ToLower(value)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.ParseUint(value, 10, 8)
+	if err != nil {
+		return 0, errors.Errorf("invalid %s '%s'", b.name, value)
+	}
+	if uint(v) < b.min || uint(v) > b.max {
+		return 0, errors.Errorf("%s '%s' out of range %d-%d", b.name, value, b.min, b.max)
+	}
+	return uint(v), nil
+}
+
+// Next returns the first time after t the schedule is due, in the location of t.
+// It returns the zero time if the schedule is not due within the next five years,
+// e.g. for February 30th.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Truncate(time.Minute).Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
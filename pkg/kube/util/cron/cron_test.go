@@ -0,0 +1,78 @@
+package cron_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/cron"
+)
+
+var _ = Describe("Cron", func() {
+	// A Monday
+	start := time.Date(2019, time.April, 22, 10, 17, 30, 0, time.UTC)
+
+	next := func(spec string, t time.Time) time.Time {
+		schedule, err := cron.Parse(spec)
+		Expect(err).ToNot(HaveOccurred())
+		return schedule.Next(t)
+	}
+
+	at := func(month time.Month, day int, hour int, minute int) time.Time {
+		return time.Date(2019, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	Describe("Next", func() {
+		It("supports wildcards, steps, lists and ranges", func() {
+			Expect(next("* * * * *", start)).To(Equal(at(time.April, 22, 10, 18)))
+			Expect(next("*/15 * * * *", start)).To(Equal(at(time.April, 22, 10, 30)))
+			Expect(next("5/20 * * * *", start)).To(Equal(at(time.April, 22, 10, 25)))
+			Expect(next("10,20 * * * *", start)).To(Equal(at(time.April, 22, 10, 20)))
+			Expect(next("0 2-4 * * *", start)).To(Equal(at(time.April, 23, 2, 0)))
+		})
+
+		It("supports names of months and days of week", func() {
+			Expect(next("0 3 * * fri", start)).To(Equal(at(time.April, 26, 3, 0)))
+			Expect(next("0 3 * * 7", start)).To(Equal(at(time.April, 28, 3, 0)))
+			Expect(next("0 0 1 jun *", start)).To(Equal(at(time.June, 1, 0, 0)))
+		})
+
+		It("matches either the day of month or the day of week if both are given", func() {
+			Expect(next("0 0 25 * 2", start)).To(Equal(at(time.April, 23, 0, 0)))
+			Expect(next("0 0 25 * *", start)).To(Equal(at(time.April, 25, 0, 0)))
+		})
+
+		It("supports descriptors", func() {
+			Expect(next("@daily", start)).To(Equal(at(time.April, 23, 0, 0)))
+			Expect(next("@hourly", start)).To(Equal(at(time.April, 22, 11, 0)))
+		})
+
+		It("skips dates which don't exist every year", func() {
+			Expect(next("0 0 29 2 *", start)).To(Equal(time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)))
+			Expect(next("0 0 30 2 *", start)).To(BeZero())
+		})
+
+		It("calculates the next time in the location of the given time", func() {
+			berlin, err := time.LoadLocation("Europe/Berlin")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(next("0 2 * * *", start.In(berlin))).To(Equal(time.Date(2019, time.April, 23, 2, 0, 0, 0, berlin)))
+		})
+	})
+
+	Describe("Parse", func() {
+		It("rejects invalid expressions", func() {
+			for spec, message := range map[string]string{
+				"* * *":       "expected 5 fields",
+				"60 * * * *":  "minute '60' out of range",
+				"* * * foo *": "invalid month 'foo'",
+				"*/0 * * * *": "invalid step",
+				"* 5-2 * * *": "invalid range",
+			} {
+				_, err := cron.Parse(spec)
+				Expect(err).To(MatchError(ContainSubstring(message)), spec)
+			}
+		})
+	})
+})
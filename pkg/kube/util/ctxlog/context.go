@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/client-go/tools/record"
 )
 
@@ -26,11 +27,23 @@ func NewParentContext(log *zap.SugaredLogger) context.Context {
 }
 
 // NewContextWithRecorder returns a new child context with the named
-// recorder and log inside
+// recorder and log inside. Log lines carry the name in the controller field.
 func NewContextWithRecorder(ctx context.Context, name string, recorder record.EventRecorder) context.Context {
 	ctx = context.WithValue(ctx, ctxRecorderKey, recorder)
 	log := ExtractLogger(ctx)
-	log = log.Named(name)
+	log = log.Named(name).With("controller", name)
+	return context.WithValue(ctx, ctxLoggerKey, log)
+}
+
+// NewRequestContext returns a new child context for a reconcile request. Log lines carry
+// a unique request ID and the namespace and name of the requested object.
+func NewRequestContext(ctx context.Context, namespace string, name string) context.Context {
+	return WithFields(ctx, "request-id", string(uuid.NewUUID()), "namespace", namespace, "name", name)
+}
+
+// WithFields returns a new child context, whose log lines carry the given key value pairs
+func WithFields(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	log := ExtractLogger(ctx).With(keysAndValues...)
 	return context.WithValue(ctx, ctxLoggerKey, log)
 }
 
@@ -3,11 +3,14 @@ package ctxlog
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/metrics"
 )
 
 type event struct {
@@ -29,11 +32,13 @@ func WithEvent(object runtime.Object, reason string) EventLogger {
 
 // Infof logs and adds an info event
 func (ev event) Infof(ctx context.Context, format string, v ...interface{}) {
+	msg := Redact(fmt.Sprintf(format, v...))
+
 	log := ExtractLogger(ctx)
-	log.Infof(format, v...)
+	log.Info(msg)
 
 	recorder := ExtractRecorder(ctx)
-	recorder.Eventf(ev.object, corev1.EventTypeNormal, ev.reason, format, v...)
+	recorder.Event(ev.object, corev1.EventTypeNormal, ev.reason, msg)
 }
 
 // Errorf uses the stored zap logger and the recorder to log an error, it returns an error like fmt.Errorf
@@ -56,23 +61,29 @@ func (ev event) Error(ctx context.Context, parts ...interface{}) error {
 	return ev.logAndError(ctx, msg)
 }
 
+// logAndError redacts the message, so secrets neither end up in logs and events, nor in the
+// returned error
 func (ev event) logAndError(ctx context.Context, msg string) error {
+	msg = Redact(msg)
+
 	log := ExtractLogger(ctx)
 	log.Error(msg)
 
 	recorder := ExtractRecorder(ctx)
 	recorder.Event(ev.object, corev1.EventTypeWarning, ev.reason, msg)
+	metrics.EventErrors.WithLabelValues(ev.reason).Inc()
 
 	// first letter of error should be lowercase, so wrap looks nice.
 	// ASCII only
 	if len(msg) > 0 {
 		msg = strings.ToLower(string(msg[0])) + msg[1:]
 	}
-	return fmt.Errorf(msg)
+	return errors.New(msg)
 }
 
 // WarningEvent will create a warning event, without logging
 func WarningEvent(ctx context.Context, object runtime.Object, reason, msg string) {
 	recorder := ExtractRecorder(ctx)
-	recorder.Event(object, corev1.EventTypeWarning, reason, msg)
+	recorder.Event(object, corev1.EventTypeWarning, reason, Redact(msg))
+	metrics.EventErrors.WithLabelValues(reason).Inc()
 }
@@ -1,39 +1,42 @@
 package ctxlog
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
 
 // Debug uses the stored zap logger
 func Debug(ctx context.Context, v ...interface{}) {
 	log := ExtractLogger(ctx)
-	log.Debug(v...)
+	log.Debug(Redact(fmt.Sprint(v...)))
 }
 
 // Info uses the stored zap logger
 func Info(ctx context.Context, v ...interface{}) {
 	log := ExtractLogger(ctx)
-	log.Info(v...)
+	log.Info(Redact(fmt.Sprint(v...)))
 }
 
 // Error uses the stored zap logger
 func Error(ctx context.Context, v ...interface{}) {
 	log := ExtractLogger(ctx)
-	log.Error(v...)
+	log.Error(Redact(fmt.Sprint(v...)))
 }
 
 // Debugf uses the stored zap logger
 func Debugf(ctx context.Context, format string, v ...interface{}) {
 	log := ExtractLogger(ctx)
-	log.Debugf(format, v...)
+	log.Debug(Redact(fmt.Sprintf(format, v...)))
 }
 
 // Infof uses the stored zap logger
 func Infof(ctx context.Context, format string, v ...interface{}) {
 	log := ExtractLogger(ctx)
-	log.Infof(format, v...)
+	log.Info(Redact(fmt.Sprintf(format, v...)))
 }
 
 // Errorf uses the stored zap logger
 func Errorf(ctx context.Context, format string, v ...interface{}) {
 	log := ExtractLogger(ctx)
-	log.Errorf(format, v...)
+	log.Error(Redact(fmt.Sprintf(format, v...)))
 }
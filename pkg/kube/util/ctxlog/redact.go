@@ -0,0 +1,161 @@
+package ctxlog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// MaxMessageLength is the length at which log and event messages are truncated, so
+	// manifests or other large documents are not dumped into them
+	MaxMessageLength = 1024
+	// minSecretLength is the length a secret value needs to be masked, shorter values
+	// would mask unrelated parts of the messages
+	minSecretLength = 8
+	// maxSecretScopes bounds the number of resources whose values are masked, the values
+	// of the least recently registered resources are dropped first
+	maxSecretScopes = 4096
+	// redacted replaces secret values in messages
+	redacted = "<redacted>"
+)
+
+// secretValues holds the values which are masked in messages, by the resource they belong to
+var secretValues = struct {
+	sync.RWMutex
+	scopes   map[string]map[string]struct{}
+	order    []string
+	replacer *strings.Replacer
+}{scopes: map[string]map[string]struct{}{}}
+
+// AddSecretValues registers the values of variables or secrets of a resource, so they are
+// masked in log lines, events and the errors returned by the event logger. The lines of
+// multi-line values, like keys or certificates, are masked on their own, too.
+// The scope identifies the resource, e.g. by its kind and namespaced name. Registering
+// values for a scope again replaces its previous values, so rotated values do not pile up.
+func AddSecretValues(scope string, values ...string) {
+	set := map[string]struct{}{}
+	for _, value := range values {
+		addSecretValue(set, value)
+		if strings.Contains(value, "\n") {
+			for _, line := range strings.Split(value, "\n") {
+				// PEM headers like '-----BEGIN CERTIFICATE-----' are not secret
+				if !strings.HasPrefix(line, "-----") {
+					addSecretValue(set, line)
+				}
+			}
+		}
+	}
+
+	secretValues.Lock()
+	defer secretValues.Unlock()
+
+	removeScope(scope)
+	if len(set) == 0 {
+		return
+	}
+	secretValues.scopes[scope] = set
+	secretValues.order = append(secretValues.order, scope)
+	secretValues.replacer = nil
+
+	for len(secretValues.order) > maxSecretScopes {
+		removeScope(secretValues.order[0])
+	}
+}
+
+// AddSecretData registers the values of a secret's data, see AddSecretValues
+func AddSecretData(scope string, data map[string][]byte) {
+	values := make([]string, 0, len(data))
+	for _, value := range data {
+		values = append(values, string(value))
+	}
+	AddSecretValues(scope, values...)
+}
+
+// RemoveSecretValues stops masking the values of a scope, e.g. after its resource has been deleted
+func RemoveSecretValues(scope string) {
+	secretValues.Lock()
+	defer secretValues.Unlock()
+
+	removeScope(scope)
+}
+
+// addSecretValue adds a value to a set, unless it is too short to be masked
+func addSecretValue(set map[string]struct{}, value string) {
+	value = strings.TrimSpace(value)
+	if len(value) < minSecretLength {
+		return
+	}
+	set[value] = struct{}{}
+}
+
+// removeScope drops the values of a scope, the caller needs to hold the lock
+func removeScope(scope string) {
+	if _, ok := secretValues.scopes[scope]; !ok {
+		return
+	}
+	delete(secretValues.scopes, scope)
+	for i, s := range secretValues.order {
+		if s == scope {
+			secretValues.order = append(secretValues.order[:i], secretValues.order[i+1:]...)
+			break
+		}
+	}
+	secretValues.replacer = nil
+}
+
+// Redact masks registered secret values in a message and truncates it to MaxMessageLength
+func Redact(msg string) string {
+	msg = secretReplacer().Replace(msg)
+
+	if len(msg) > MaxMessageLength {
+		cut := MaxMessageLength
+		for cut > 0 && !utf8.RuneStart(msg[cut]) {
+			cut--
+		}
+		msg = fmt.Sprintf("%s... (%d characters truncated)", msg[:cut], len(msg)-cut)
+	}
+
+	return msg
+}
+
+// secretReplacer returns a replacer for all registered values. It is only built again when
+// the registered values changed. Longer values are replaced first, so a value containing
+// another one is masked as a whole.
+func secretReplacer() *strings.Replacer {
+	secretValues.RLock()
+	replacer := secretValues.replacer
+	secretValues.RUnlock()
+	if replacer != nil {
+		return replacer
+	}
+
+	secretValues.Lock()
+	defer secretValues.Unlock()
+
+	if secretValues.replacer != nil {
+		return secretValues.replacer
+	}
+
+	unique := map[string]struct{}{}
+	for _, set := range secretValues.scopes {
+		for value := range set {
+			unique[value] = struct{}{}
+		}
+	}
+	values := make([]string, 0, len(unique))
+	for value := range unique {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	oldnew := make([]string, 0, 2*len(values))
+	for _, value := range values {
+		oldnew = append(oldnew, value, redacted)
+	}
+	secretValues.replacer = strings.NewReplacer(oldnew...)
+
+	return secretValues.replacer
+}
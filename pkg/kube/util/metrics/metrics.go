@@ -0,0 +1,116 @@
+// Package metrics contains the Prometheus metrics of the operator. They are served by the
+// manager, together with the reconcile metrics of controller-runtime.
+package metrics
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// EventErrors counts the warning events by reason, e.g. 'BadManifestError'
+	EventErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cf_operator_event_errors_total",
+		Help: "Total number of errors reported as events, by event reason",
+	}, []string{"reason"})
+
+	// BOSHDeploymentStateDuration observes how long BOSHDeployments stayed in a state
+	BOSHDeploymentStateDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cf_operator_boshdeployment_state_duration_seconds",
+		Help:    "Time BOSHDeployments spent in a state before moving to the next one",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 14),
+	}, []string{"state"})
+
+	// ExtendedStatefulSetVersions is the number of StatefulSet versions of an ExtendedStatefulSet
+	ExtendedStatefulSetVersions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cf_operator_extendedstatefulset_versions",
+		Help: "Number of StatefulSet versions alive per ExtendedStatefulSet",
+	}, []string{"namespace", "name"})
+
+	// ExtendedJobRuns counts the finished jobs of an ExtendedJob by outcome, 'succeeded' or 'failed'
+	ExtendedJobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cf_operator_extendedjob_runs_total",
+		Help: "Total number of finished ExtendedJob runs by outcome",
+	}, []string{"namespace", "name", "outcome"})
+
+	// CertificateExpiry reports the days until the certificates of ExtendedSecrets expire
+	CertificateExpiry = NewCertificateExpiryCollector()
+)
+
+func init() {
+	crmetrics.Registry.MustRegister(
+		EventErrors,
+		BOSHDeploymentStateDuration,
+		ExtendedStatefulSetVersions,
+		ExtendedJobRuns,
+		CertificateExpiry,
+	)
+}
+
+// CertificateExpiryCollector calculates the days until expiry when it is scraped, so the
+// value does not depend on when the certificate was last reconciled
+type CertificateExpiryCollector struct {
+	desc     *prometheus.Desc
+	mu       sync.Mutex
+	notAfter map[types.NamespacedName]time.Time
+}
+
+// NewCertificateExpiryCollector returns a collector without certificates
+func NewCertificateExpiryCollector() *CertificateExpiryCollector {
+	return &CertificateExpiryCollector{
+		desc: prometheus.NewDesc(
+			"cf_operator_extendedsecret_certificate_expiry_days",
+			"Days until the certificate of an ExtendedSecret expires",
+			[]string{"namespace", "name"},
+			nil,
+		),
+		notAfter: map[types.NamespacedName]time.Time{},
+	}
+}
+
+// Set records the PEM encoded certificate of an ExtendedSecret
+func (c *CertificateExpiryCollector) Set(name types.NamespacedName, certificate []byte) error {
+	block, _ := pem.Decode(certificate)
+	if block == nil {
+		return errors.New("failed to decode PEM certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse certificate")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notAfter[name] = cert.NotAfter
+	return nil
+}
+
+// Delete removes the certificate of a deleted ExtendedSecret
+func (c *CertificateExpiryCollector) Delete(name types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.notAfter, name)
+}
+
+// Describe implements prometheus.Collector
+func (c *CertificateExpiryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector
+func (c *CertificateExpiryCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name, notAfter := range c.notAfter {
+		days := time.Until(notAfter).Hours() / 24
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, days, name.Namespace, name.Name)
+	}
+}
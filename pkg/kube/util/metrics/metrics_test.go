@@ -0,0 +1,66 @@
+package metrics_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/types"
+
+	. "code.cloudfoundry.org/cf-operator/pkg/kube/util/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CertificateExpiryCollector", func() {
+	var (
+		collector   *CertificateExpiryCollector
+		name        types.NamespacedName
+		certificate []byte
+	)
+
+	BeforeEach(func() {
+		collector = NewCertificateExpiryCollector()
+		name = types.NamespacedName{Namespace: "default", Name: "foo-cert"}
+
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		Expect(err).ToNot(HaveOccurred())
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "foo"},
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(30 * 24 * time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		Expect(err).ToNot(HaveOccurred())
+		certificate = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	})
+
+	It("reports the days until the certificate expires", func() {
+		err := collector.Set(name, certificate)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(testutil.ToFloat64(collector)).To(BeNumerically("~", 30, 0.01))
+	})
+
+	It("stops reporting deleted certificates", func() {
+		err := collector.Set(name, certificate)
+		Expect(err).ToNot(HaveOccurred())
+		collector.Delete(name)
+
+		ch := make(chan prometheus.Metric, 1)
+		collector.Collect(ch)
+		Expect(ch).To(BeEmpty())
+	})
+
+	It("fails for invalid certificates", func() {
+		err := collector.Set(name, []byte("thecert"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,46 @@
+package nslabel
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// LabelOperatorNamespace is set on every watched namespace. The webhooks of an operator
+// only select namespaces labeled with its own namespace.
+const LabelOperatorNamespace = "cf-operator-ns"
+
+// Set labels the namespace, so the webhooks of the operator running in operatorNamespace apply to it
+func Set(ctx context.Context, c client.Client, namespace string, operatorNamespace string) error {
+	ns := &unstructured.Unstructured{}
+	ns.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "",
+		Kind:    "Namespace",
+		Version: "v1",
+	})
+	err := c.Get(ctx, types.NamespacedName{Name: namespace}, ns)
+	if err != nil {
+		return errors.Wrapf(err, "getting the namespace object '%s'", namespace)
+	}
+
+	labels := ns.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	if labels[LabelOperatorNamespace] == operatorNamespace {
+		return nil
+	}
+	labels[LabelOperatorNamespace] = operatorNamespace
+	ns.SetLabels(labels)
+
+	err = c.Update(ctx, ns)
+	if err != nil {
+		return errors.Wrapf(err, "updating the namespace object '%s'", namespace)
+	}
+
+	return nil
+}
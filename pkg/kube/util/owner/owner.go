@@ -228,6 +228,17 @@ func GetConfigNamesFromSpec(spec corev1.PodSpec) (map[string]struct{}, map[strin
 		if s := vol.VolumeSource.Secret; s != nil {
 			secrets[s.SecretName] = struct{}{}
 		}
+		// Projected volumes hold versioned secrets which are split into chunks
+		if p := vol.VolumeSource.Projected; p != nil {
+			for _, source := range p.Sources {
+				if cm := source.ConfigMap; cm != nil {
+					configMaps[cm.Name] = struct{}{}
+				}
+				if s := source.Secret; s != nil {
+					secrets[s.Name] = struct{}{}
+				}
+			}
+		}
 	}
 
 	// Iterate over all Containers and their respective EnvFrom and Env
@@ -0,0 +1,387 @@
+package versionedsecretstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"code.cloudfoundry.org/cf-operator/pkg/kube/apis"
+)
+
+var (
+	// AnnotationEncoding is the annotation key for the encoding of a version's data
+	AnnotationEncoding = fmt.Sprintf("%s/encoding", apis.GroupName)
+	// AnnotationChunks is the annotation key for the number of chunks a version is split into
+	AnnotationChunks = fmt.Sprintf("%s/chunks", apis.GroupName)
+	// LabelChunkOf is the label key for the name of the version a chunk belongs to
+	LabelChunkOf = fmt.Sprintf("%s/chunk-of", apis.GroupName)
+)
+
+const (
+	// VersionSecretChunkKind is the kind of the secrets holding the chunks of a versioned secret
+	VersionSecretChunkKind = "versionedSecretChunk"
+	// EncodingGzip is the encoding of compressed versions
+	EncodingGzip = "gzip"
+	// ChunksKey is the key listing the chunk secrets of a version which was split into chunks
+	ChunksKey = "versioned-secret-chunks"
+	// EncodingKey is the key holding the encoding of a compressed version. Annotations
+	// are not available in volumes, so mounted versions are recognized by this key.
+	EncodingKey = "versioned-secret-encoding"
+	// ChunkKey is the key holding the data of a chunk secret
+	ChunkKey = "chunk"
+
+	// CompressionThreshold is the size of the data above which its values are compressed
+	CompressionThreshold = 256 * 1024
+	// ChunkSize is the maximum size of the compressed data stored in a single secret
+	ChunkSize = 512 * 1024
+)
+
+// ChunkGetter returns the data of the chunk secret with the given name
+type ChunkGetter func(name string) ([]byte, error)
+
+// encodedVersion is the representation of a version's data in Kubernetes
+type encodedVersion struct {
+	data        map[string][]byte
+	annotations map[string]string
+	chunks      map[string][]byte
+}
+
+// encodeData compresses the data of a version if it is large, and splits it into
+// chunks if it is still too large for a single secret. Small data is stored as is,
+// so it stays readable by consumers which don't know about the encoding.
+func encodeData(name string, secretData map[string]string) (*encodedVersion, error) {
+	size := 0
+	for _, value := range secretData {
+		size += len(value)
+	}
+
+	for _, key := range []string{ChunksKey, EncodingKey} {
+		if _, ok := secretData[key]; ok {
+			return nil, errors.Errorf("key '%s' is reserved for the encoding of versions", key)
+		}
+	}
+
+	version := &encodedVersion{data: map[string][]byte{}, annotations: map[string]string{}}
+	if size <= CompressionThreshold {
+		for key, value := range secretData {
+			version.data[key] = []byte(value)
+		}
+		return version, nil
+	}
+
+	version.annotations[AnnotationEncoding] = EncodingGzip
+	compressedSize := 0
+	for key, value := range secretData {
+		compressed, err := compress([]byte(value))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compress key '%s'", key)
+		}
+		version.data[key] = compressed
+		compressedSize += len(compressed)
+	}
+	version.data[EncodingKey] = []byte(EncodingGzip)
+	if compressedSize <= ChunkSize {
+		return version, nil
+	}
+
+	// Split the compressed data of all keys into chunks, the version itself only lists them
+	dataBytes, err := json.Marshal(secretData)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal data for chunking")
+	}
+	payload, err := compress(dataBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compress data for chunking")
+	}
+
+	chunkNames := []string{}
+	version.chunks = map[string][]byte{}
+	for i := 0; i*ChunkSize < len(payload); i++ {
+		end := (i + 1) * ChunkSize
+		if end > len(payload) {
+			end = len(payload)
+		}
+		chunkName, err := generateChunkName(name, i)
+		if err != nil {
+			return nil, err
+		}
+		chunkNames = append(chunkNames, chunkName)
+		version.chunks[chunkName] = payload[i*ChunkSize : end]
+	}
+
+	index, err := json.Marshal(chunkNames)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal chunk names")
+	}
+	version.data = map[string][]byte{ChunksKey: index}
+	version.annotations[AnnotationChunks] = fmt.Sprintf("%d", len(chunkNames))
+
+	return version, nil
+}
+
+// decodeData returns the data of a version as it was passed to Create. Compressed
+// values are decompressed and chunks are reassembled using getChunk.
+func decodeData(data map[string][]byte, encoding string, getChunk ChunkGetter) (map[string][]byte, error) {
+	if index, ok := data[ChunksKey]; ok {
+		return reassemble(index, getChunk)
+	}
+
+	if encoding != EncodingGzip {
+		return data, nil
+	}
+
+	result := map[string][]byte{}
+	for key, value := range data {
+		if key == EncodingKey {
+			continue
+		}
+		decompressed, err := decompress(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decompress key '%s'", key)
+		}
+		result[key] = decompressed
+	}
+	return result, nil
+}
+
+// reassemble joins the chunks listed in index and returns the data they hold
+func reassemble(index []byte, getChunk ChunkGetter) (map[string][]byte, error) {
+	chunkNames := []string{}
+	if err := json.Unmarshal(index, &chunkNames); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal chunk names")
+	}
+
+	var payload []byte
+	for _, chunkName := range chunkNames {
+		chunk, err := getChunk(chunkName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get chunk '%s'", chunkName)
+		}
+		payload = append(payload, chunk...)
+	}
+
+	dataBytes, err := decompress(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress chunks")
+	}
+
+	secretData := map[string]string{}
+	if err := json.Unmarshal(dataBytes, &secretData); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal chunks")
+	}
+
+	result := map[string][]byte{}
+	for key, value := range secretData {
+		result[key] = []byte(value)
+	}
+	return result, nil
+}
+
+// NewChunkGetter returns a ChunkGetter reading chunk secrets from the namespace
+func NewChunkGetter(ctx context.Context, c client.Client, namespace string) ChunkGetter {
+	return func(name string) ([]byte, error) {
+		secret := &corev1.Secret{}
+		err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, secret)
+		if err != nil {
+			return nil, err
+		}
+		return secret.Data[ChunkKey], nil
+	}
+}
+
+// ReadMountedFile reads a key of a versioned secret, which is mounted as a volume.
+// Annotations are not available in the volume, so compressed versions are recognized
+// by the EncodingKey file next to the key. If the version was split into chunks, the
+// chunks are read from the same directory, UpdateSecretReferences mounts them as files
+// named like the chunk secrets. Files of secrets which are neither compressed nor
+// split are returned as they are.
+func ReadMountedFile(path string) ([]byte, error) {
+	dir := filepath.Dir(path)
+	index, err := ioutil.ReadFile(filepath.Join(dir, ChunksKey))
+	if err == nil {
+		data, err := reassemble(index, func(name string) ([]byte, error) {
+			return ioutil.ReadFile(filepath.Join(dir, name))
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to reassemble chunks of '%s'", path)
+		}
+		content, ok := data[filepath.Base(path)]
+		if !ok {
+			return nil, errors.Errorf("key '%s' not found in the chunks of '%s'", filepath.Base(path), dir)
+		}
+		return content, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "failed to read chunk index of '%s'", path)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	encoding, err := ioutil.ReadFile(filepath.Join(dir, EncodingKey))
+	if os.IsNotExist(err) {
+		return content, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read encoding of '%s'", path)
+	}
+	if string(encoding) != EncodingGzip {
+		return nil, errors.Errorf("unknown encoding '%s' of '%s'", string(encoding), path)
+	}
+
+	decompressed, err := decompress(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decompress '%s'", path)
+	}
+	return decompressed, nil
+}
+
+// projectChunks mounts the chunks of a version along with it, so pods can read the
+// version without access to the Kubernetes API. Secret volumes of a version which was
+// split into chunks become projected volumes, holding the keys of the version and a
+// file for each chunk, which is named like the chunk secret. Projected volumes of a
+// version which is not split become secret volumes again.
+func projectChunks(volumes []corev1.Volume, secret *corev1.Secret) error {
+	// The chunk names are derived from the annotation, the data might be decoded already
+	chunkNames := []string{}
+	if chunks, ok := secret.GetAnnotations()[AnnotationChunks]; ok {
+		count, err := strconv.Atoi(chunks)
+		if err != nil {
+			return errors.Wrapf(err, "invalid number of chunks of secret '%s'", secret.GetName())
+		}
+		for i := 0; i < count; i++ {
+			chunkName, err := generateChunkName(secret.GetName(), i)
+			if err != nil {
+				return err
+			}
+			chunkNames = append(chunkNames, chunkName)
+		}
+	}
+
+	for i := range volumes {
+		source := &volumes[i].VolumeSource
+
+		var projection corev1.SecretProjection
+		var mode *int32
+		switch {
+		case source.Secret != nil && source.Secret.SecretName == secret.GetName():
+			if len(chunkNames) == 0 {
+				source.Secret.Items = encodingItems(source.Secret.Items, secret)
+				continue
+			}
+			projection = corev1.SecretProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secret.GetName()},
+				Items:                encodingItems(source.Secret.Items, secret),
+				Optional:             source.Secret.Optional,
+			}
+			mode = source.Secret.DefaultMode
+		case source.Projected != nil && len(source.Projected.Sources) > 0 &&
+			source.Projected.Sources[0].Secret != nil && source.Projected.Sources[0].Secret.Name == secret.GetName():
+			projection = *source.Projected.Sources[0].Secret
+			projection.Items = encodingItems(projection.Items, secret)
+			mode = source.Projected.DefaultMode
+		default:
+			continue
+		}
+
+		if len(chunkNames) == 0 {
+			*source = corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName:  projection.Name,
+					Items:       projection.Items,
+					Optional:    projection.Optional,
+					DefaultMode: mode,
+				},
+			}
+			continue
+		}
+
+		sources := []corev1.VolumeProjection{{Secret: &projection}}
+		for _, chunkName := range chunkNames {
+			sources = append(sources, corev1.VolumeProjection{
+				Secret: &corev1.SecretProjection{
+					LocalObjectReference: corev1.LocalObjectReference{Name: chunkName},
+					Items:                []corev1.KeyToPath{{Key: ChunkKey, Path: chunkName}},
+				},
+			})
+		}
+		*source = corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources:     sources,
+				DefaultMode: mode,
+			},
+		}
+	}
+
+	return nil
+}
+
+// encodingItems returns the items of a volume mounting only some keys of a version,
+// along with the keys ReadMountedFile needs to decode them. A version which was split
+// into chunks only holds the chunk index, the keys are read from the chunks. Volumes
+// without items mount all keys anyway.
+func encodingItems(items []corev1.KeyToPath, secret *corev1.Secret) []corev1.KeyToPath {
+	if len(items) == 0 {
+		return items
+	}
+
+	annotations := secret.GetAnnotations()
+	if _, ok := annotations[AnnotationChunks]; ok {
+		return []corev1.KeyToPath{{Key: ChunksKey, Path: ChunksKey}}
+	}
+
+	result := []corev1.KeyToPath{}
+	for _, item := range items {
+		if item.Key != ChunksKey && item.Key != EncodingKey {
+			result = append(result, item)
+		}
+	}
+	if annotations[AnnotationEncoding] == EncodingGzip {
+		result = append(result, corev1.KeyToPath{Key: EncodingKey, Path: EncodingKey})
+	}
+	return result
+}
+
+func generateChunkName(name string, index int) (string, error) {
+	proposedName := fmt.Sprintf("%s-chunk-%d", name, index)
+
+	const maxChars = 253
+	if len(proposedName) > maxChars {
+		return "", fmt.Errorf("chunk name exceeds maximum number of allowed characters (actual=%d, allowed=%d)", len(proposedName), maxChars)
+	}
+
+	return proposedName, nil
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
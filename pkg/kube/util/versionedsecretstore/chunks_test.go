@@ -0,0 +1,100 @@
+package versionedsecretstore_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "code.cloudfoundry.org/cf-operator/pkg/kube/util/versionedsecretstore"
+)
+
+var _ = Describe("ReadMountedFile", func() {
+	var dir string
+
+	compress := func(data []byte) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		_, err := w.Write(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+		return buf.Bytes()
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "versioned-secret")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("returns files of plain secrets as they are", func() {
+		Expect(ioutil.WriteFile(filepath.Join(dir, "manifest.yaml"), []byte("name: foo"), 0644)).To(Succeed())
+
+		content, err := ReadMountedFile(filepath.Join(dir, "manifest.yaml"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(content)).To(Equal("name: foo"))
+	})
+
+	It("decompresses the files of compressed secrets", func() {
+		Expect(ioutil.WriteFile(filepath.Join(dir, "manifest.yaml"), compress([]byte("name: foo")), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, EncodingKey), []byte(EncodingGzip), 0644)).To(Succeed())
+
+		content, err := ReadMountedFile(filepath.Join(dir, "manifest.yaml"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(content)).To(Equal("name: foo"))
+	})
+
+	It("returns gzip files of plain secrets as they are", func() {
+		compressed := compress([]byte("name: foo"))
+		Expect(ioutil.WriteFile(filepath.Join(dir, "archive.gz"), compressed, 0644)).To(Succeed())
+
+		content, err := ReadMountedFile(filepath.Join(dir, "archive.gz"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(content).To(Equal(compressed))
+	})
+
+	It("fails for unknown encodings", func() {
+		Expect(ioutil.WriteFile(filepath.Join(dir, "manifest.yaml"), []byte("name: foo"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, EncodingKey), []byte("zstd"), 0644)).To(Succeed())
+
+		_, err := ReadMountedFile(filepath.Join(dir, "manifest.yaml"))
+		Expect(err).To(MatchError(ContainSubstring("unknown encoding 'zstd'")))
+	})
+
+	It("reassembles the mounted chunks of large secrets", func() {
+		data, err := json.Marshal(map[string]string{"manifest.yaml": "name: foo"})
+		Expect(err).ToNot(HaveOccurred())
+		payload := compress(data)
+		Expect(ioutil.WriteFile(filepath.Join(dir, "manifest-v1-chunk-0"), payload[:10], 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "manifest-v1-chunk-1"), payload[10:], 0644)).To(Succeed())
+
+		index, err := json.Marshal([]string{"manifest-v1-chunk-0", "manifest-v1-chunk-1"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ioutil.WriteFile(filepath.Join(dir, ChunksKey), index, 0644)).To(Succeed())
+
+		content, err := ReadMountedFile(filepath.Join(dir, "manifest.yaml"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(content)).To(Equal("name: foo"))
+
+		_, err = ReadMountedFile(filepath.Join(dir, "missing.yaml"))
+		Expect(err).To(MatchError(ContainSubstring("key 'missing.yaml' not found")))
+	})
+
+	It("fails if a chunk is not mounted", func() {
+		index, err := json.Marshal([]string{"manifest-v1-chunk-0"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ioutil.WriteFile(filepath.Join(dir, ChunksKey), index, 0644)).To(Succeed())
+
+		_, err = ReadMountedFile(filepath.Join(dir, "manifest.yaml"))
+		Expect(err).To(MatchError(ContainSubstring("failed to get chunk 'manifest-v1-chunk-0'")))
+	})
+})
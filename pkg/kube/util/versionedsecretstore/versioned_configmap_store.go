@@ -0,0 +1,254 @@
+package versionedsecretstore
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/names"
+	"code.cloudfoundry.org/cf-operator/pkg/kube/util/owner"
+)
+
+const (
+	// VersionConfigMapKind is the kind of versioned config map, it is stored in the
+	// LabelSecretKind label like the kind of versioned secrets
+	VersionConfigMapKind = "versionedConfigMap"
+)
+
+var _ VersionedConfigMapStore = &VersionedConfigMapStoreImpl{}
+
+// VersionedConfigMapStore is the interface to version config maps in Kubernetes
+//
+// Versioned config maps are named and labeled like versioned secrets, see VersionedSecretStore.
+// They hold data which is not sensitive. No new version is created if the data and labels are
+// identical to the latest version.
+type VersionedConfigMapStore interface {
+	UpdateConfigMapReferences(ctx context.Context, namespace string, podSpec *corev1.PodSpec) error
+	Create(ctx context.Context, namespace string, configMapName string, data map[string]string, labels map[string]string, sourceDescription string) (string, error)
+	Get(ctx context.Context, namespace string, configMapName string, version int) (*corev1.ConfigMap, error)
+	Latest(ctx context.Context, namespace string, configMapName string) (*corev1.ConfigMap, error)
+	List(ctx context.Context, namespace string, configMapName string) ([]corev1.ConfigMap, error)
+}
+
+// VersionedConfigMapStoreImpl contains the required fields to persist a config map
+type VersionedConfigMapStoreImpl struct {
+	client client.Client
+}
+
+// NewVersionedConfigMapStore returns a VersionedConfigMapStore implementation
+func NewVersionedConfigMapStore(client client.Client) VersionedConfigMapStoreImpl {
+	return VersionedConfigMapStoreImpl{
+		client,
+	}
+}
+
+// UpdateConfigMapReferences update versioned config map references in pod spec
+func (p VersionedConfigMapStoreImpl) UpdateConfigMapReferences(ctx context.Context, namespace string, podSpec *corev1.PodSpec) error {
+	configMapsInSpec, _ := owner.GetConfigNamesFromSpec(*podSpec)
+	for configMapNameInSpec := range configMapsInSpec {
+
+		versionedPrefix := names.GetPrefixFromVersionedSecretName(configMapNameInSpec)
+		// If this config map doesn't look like a versioned config map (e.g. <name>-v2), move on
+		if versionedPrefix == "" {
+			continue
+		}
+
+		versionedConfigMap, err := p.Latest(ctx, namespace, versionedPrefix)
+		if err != nil && apierrors.IsNotFound(err) {
+			ctxlog.Debugf(ctx, "versioned config map %s in namespace %s doesn't exist", versionedPrefix, namespace)
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to get latest versioned config map %s in namespace %s", versionedPrefix, namespace)
+		}
+
+		if versionedConfigMap.Labels[LabelSecretKind] != VersionConfigMapKind {
+			continue
+		}
+
+		if versionedConfigMap.Name != configMapNameInSpec {
+			replaceVolumesConfigMapRef(podSpec.Volumes, configMapNameInSpec, versionedConfigMap.GetName())
+			replaceContainerEnvsConfigMapRef(podSpec.Containers, configMapNameInSpec, versionedConfigMap.GetName())
+		}
+	}
+
+	return nil
+}
+
+// Create creates a new version of the config map, unless the latest version holds the same data
+// and labels. It returns the name of the created version, or of the latest one if it was kept.
+func (p VersionedConfigMapStoreImpl) Create(ctx context.Context, namespace string, configMapName string, data map[string]string, labels map[string]string, sourceDescription string) (string, error) {
+	currentVersion, err := p.getGreatestVersion(ctx, namespace, configMapName)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := contentHash(data)
+	if err != nil {
+		return "", err
+	}
+
+	if currentVersion > 0 {
+		latest, err := p.Get(ctx, namespace, configMapName, currentVersion)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return "", errors.Wrapf(err, "failed to get version %d of config map '%s'", currentVersion, configMapName)
+		}
+		if err == nil {
+			latestHash, ok := latest.GetAnnotations()[AnnotationContentHash]
+			if !ok {
+				latestHash, err = contentHash(latest.Data)
+				if err != nil {
+					return "", err
+				}
+			}
+			if latestHash == hash && hasLabels(latest.GetLabels(), labels) {
+				ctxlog.Debugf(ctx, "Skip creating a new version of config map '%s': data and labels are identical to version %d", configMapName, currentVersion)
+				return latest.GetName(), nil
+			}
+		}
+	}
+
+	version := currentVersion + 1
+	labels[LabelVersion] = strconv.Itoa(version)
+	labels[LabelSecretKind] = VersionConfigMapKind
+
+	generatedName, err := generateSecretName(configMapName, version)
+	if err != nil {
+		return "", err
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generatedName,
+			Namespace: namespace,
+			Labels:    labels,
+			Annotations: map[string]string{
+				AnnotationSourceDescription: sourceDescription,
+				AnnotationContentHash:       hash,
+			},
+		},
+		Data: data,
+	}
+
+	err = p.client.Create(ctx, configMap)
+	if err != nil {
+		return "", err
+	}
+
+	return generatedName, nil
+}
+
+// Get returns a specific version of the config map
+func (p VersionedConfigMapStoreImpl) Get(ctx context.Context, namespace string, configMapName string, version int) (*corev1.ConfigMap, error) {
+	name, err := generateSecretName(configMapName, version)
+	if err != nil {
+		return nil, err
+	}
+
+	configMap := &corev1.ConfigMap{}
+	err = p.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, configMap)
+	if err != nil {
+		return nil, err
+	}
+
+	return configMap, nil
+}
+
+// Latest returns the latest version of the config map
+func (p VersionedConfigMapStoreImpl) Latest(ctx context.Context, namespace string, configMapName string) (*corev1.ConfigMap, error) {
+	latestVersion, err := p.getGreatestVersion(ctx, namespace, configMapName)
+	if err != nil {
+		return nil, err
+	}
+	return p.Get(ctx, namespace, configMapName, latestVersion)
+}
+
+// List returns all versions of the config map
+func (p VersionedConfigMapStoreImpl) List(ctx context.Context, namespace string, configMapName string) ([]corev1.ConfigMap, error) {
+	configMapLabelsSet := labels.Set{
+		LabelSecretKind: VersionConfigMapKind,
+	}
+
+	configMaps := &corev1.ConfigMapList{}
+	if err := p.client.List(ctx, &client.ListOptions{
+		Namespace:     namespace,
+		LabelSelector: configMapLabelsSet.AsSelector(),
+	}, configMaps); err != nil {
+		return nil, err
+	}
+
+	result := []corev1.ConfigMap{}
+
+	nameRegex := regexp.MustCompile(fmt.Sprintf(`^%s-v\d+$`, configMapName))
+	for _, configMap := range configMaps.Items {
+		if nameRegex.MatchString(configMap.Name) {
+			result = append(result, configMap)
+		}
+	}
+
+	return result, nil
+}
+
+func (p VersionedConfigMapStoreImpl) getGreatestVersion(ctx context.Context, namespace string, configMapName string) (int, error) {
+	list, err := p.List(ctx, namespace, configMapName)
+	if err != nil {
+		return -1, err
+	}
+
+	var greatestVersion int
+	for _, configMap := range list {
+		version, err := names.GetVersionFromVersionedSecretName(configMap.GetName())
+		if err != nil {
+			return 0, err
+		}
+
+		if version > greatestVersion {
+			greatestVersion = version
+		}
+	}
+
+	return greatestVersion, nil
+}
+
+// replaceVolumesConfigMapRef replace config map reference of volumes
+func replaceVolumesConfigMapRef(volumes []corev1.Volume, configMapName string, versionedConfigMapName string) {
+	for _, vol := range volumes {
+		if vol.VolumeSource.ConfigMap != nil && vol.VolumeSource.ConfigMap.Name == configMapName {
+			vol.VolumeSource.ConfigMap.Name = versionedConfigMapName
+		}
+	}
+}
+
+// replaceContainerEnvsConfigMapRef replace config map reference of envs for each container
+func replaceContainerEnvsConfigMapRef(containers []corev1.Container, configMapName string, versionedConfigMapName string) {
+	for _, container := range containers {
+
+		for _, env := range container.EnvFrom {
+			if cm := env.ConfigMapRef; cm != nil {
+				if cm.Name == configMapName {
+					cm.Name = versionedConfigMapName
+				}
+			}
+		}
+
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if cmRef := env.ValueFrom.ConfigMapKeyRef; cmRef != nil {
+				if cmRef.Name == configMapName {
+					cmRef.Name = versionedConfigMapName
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package versionedsecretstore_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	. "code.cloudfoundry.org/cf-operator/pkg/kube/util/versionedsecretstore"
+	"code.cloudfoundry.org/cf-operator/testing"
+)
+
+var _ = Describe("VersionedConfigMapStore", func() {
+	var (
+		store VersionedConfigMapStore
+		ctx   context.Context
+	)
+
+	versionedConfigMap := func(name string, version string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name + "-v" + version,
+				Namespace: "default",
+				Labels: map[string]string{
+					LabelSecretKind: VersionConfigMapKind,
+					LabelVersion:    version,
+				},
+			},
+			Data: map[string]string{"topology": version},
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = testing.NewContext()
+		store = NewVersionedConfigMapStore(fake.NewFakeClient(
+			versionedConfigMap("topology", "1"),
+			versionedConfigMap("topology", "2"),
+			versionedConfigMap("other", "3"),
+		))
+	})
+
+	Describe("Create", func() {
+		It("creates the next version", func() {
+			name, err := store.Create(ctx, "default", "topology", map[string]string{"topology": "new"}, map[string]string{"key": "value"}, "created by a unit-test")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(name).To(Equal("topology-v3"))
+
+			configMap, err := store.Latest(ctx, "default", "topology")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(configMap.Name).To(Equal("topology-v3"))
+			Expect(configMap.Data).To(Equal(map[string]string{"topology": "new"}))
+			Expect(configMap.Labels).To(HaveKeyWithValue("key", "value"))
+			Expect(configMap.Labels).To(HaveKeyWithValue(LabelSecretKind, VersionConfigMapKind))
+			Expect(configMap.Labels).To(HaveKeyWithValue(LabelVersion, "3"))
+			Expect(configMap.Annotations).To(HaveKeyWithValue(AnnotationSourceDescription, "created by a unit-test"))
+			Expect(configMap.Annotations).To(HaveKey(AnnotationContentHash))
+		})
+	})
+
+	Describe("Create with unchanged data", func() {
+		It("does not create a new version", func() {
+			name, err := store.Create(ctx, "default", "topology", map[string]string{"topology": "2"}, map[string]string{}, "created by a unit-test")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(name).To(Equal("topology-v2"))
+
+			configMap, err := store.Latest(ctx, "default", "topology")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(configMap.Name).To(Equal("topology-v2"))
+		})
+	})
+
+	Describe("Create with unchanged data and changed labels", func() {
+		It("creates a new version", func() {
+			_, err := store.Create(ctx, "default", "topology", map[string]string{"topology": "2"}, map[string]string{"key": "value"}, "created by a unit-test")
+			Expect(err).ToNot(HaveOccurred())
+
+			configMap, err := store.Latest(ctx, "default", "topology")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(configMap.Name).To(Equal("topology-v3"))
+			Expect(configMap.Labels).To(HaveKeyWithValue("key", "value"))
+		})
+	})
+
+	Describe("List", func() {
+		It("returns all versions of the config map", func() {
+			configMaps, err := store.List(ctx, "default", "topology")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(configMaps).To(HaveLen(2))
+		})
+	})
+
+	Describe("UpdateConfigMapReferences", func() {
+		It("replaces references to older versions with the latest version", func() {
+			podSpec := &corev1.PodSpec{
+				Volumes: []corev1.Volume{
+					{
+						Name: "topology",
+						VolumeSource: corev1.VolumeSource{
+							ConfigMap: &corev1.ConfigMapVolumeSource{
+								LocalObjectReference: corev1.LocalObjectReference{Name: "topology-v1"},
+							},
+						},
+					},
+				},
+				Containers: []corev1.Container{
+					{
+						Name: "container",
+						EnvFrom: []corev1.EnvFromSource{
+							{
+								ConfigMapRef: &corev1.ConfigMapEnvSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: "topology-v1"},
+								},
+							},
+						},
+						Env: []corev1.EnvVar{
+							{
+								Name: "OTHER",
+								ValueFrom: &corev1.EnvVarSource{
+									ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+										LocalObjectReference: corev1.LocalObjectReference{Name: "other-v3"},
+										Key:                  "topology",
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			err := store.UpdateConfigMapReferences(ctx, "default", podSpec)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(podSpec.Volumes[0].ConfigMap.Name).To(Equal("topology-v2"))
+			Expect(podSpec.Containers[0].EnvFrom[0].ConfigMapRef.Name).To(Equal("topology-v2"))
+			Expect(podSpec.Containers[0].Env[0].ValueFrom.ConfigMapKeyRef.Name).To(Equal("other-v3"))
+		})
+	})
+})
@@ -1,12 +1,16 @@
 package versionedsecretstore
 
 import (
+	"crypto/sha1"
+	"encoding/json"
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
+	"k8s.io/api/apps/v1beta2"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -14,6 +18,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"code.cloudfoundry.org/cf-operator/pkg/kube/apis"
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/ctxlog"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/names"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/owner"
@@ -26,6 +31,8 @@ var (
 	LabelVersion = fmt.Sprintf("%s/secret-version", apis.GroupName)
 	// AnnotationSourceDescription is the label key for source description
 	AnnotationSourceDescription = fmt.Sprintf("%s/source-description", apis.GroupName)
+	// AnnotationContentHash is the annotation key for the hash of a version's data
+	AnnotationContentHash = fmt.Sprintf("%s/content-hash", apis.GroupName)
 )
 
 const (
@@ -47,15 +54,31 @@ var _ VersionedSecretStore = &VersionedSecretStoreImpl{}
 // When saving a new secret, a source description is required, which
 // should explain the sources of the rendered secret, e.g. the location of
 // the Custom Resource Definition that generated it.
+//
+// No new version is created if the data and labels are identical to the latest version,
+// so pods referencing the secret are not restarted for nothing.
+//
+// Old versions are removed by Prune, which keeps the latest versions and the
+// ones still in use.
+//
+// Large data is compressed and, if it still doesn't fit into a single secret, split
+// into chunk secrets, which are listed by the version. Get and Decode return the
+// original data, consumers of mounted versions can use ReadMountedFile. The chunks
+// are mounted along with the version by UpdateSecretReferences. Environment variables
+// can't be decoded, UpdateSecretReferences fails for pods which use a compressed
+// version in their environment.
 type VersionedSecretStore interface {
 	UpdateSecretReferences(ctx context.Context, namespace string, podSpec *corev1.PodSpec) error
-	Create(ctx context.Context, namespace string, secretName string, secretData map[string]string, labels map[string]string, sourceDescription string) error
+	Create(ctx context.Context, namespace string, secretName string, secretData map[string]string, labels map[string]string, sourceDescription string) (string, error)
 	Get(ctx context.Context, namespace string, secretName string, version int) (*corev1.Secret, error)
+	Decode(ctx context.Context, secret *corev1.Secret) (map[string][]byte, error)
 	Latest(ctx context.Context, namespace string, secretName string) (*corev1.Secret, error)
 	List(ctx context.Context, namespace string, secretName string) ([]corev1.Secret, error)
 	VersionCount(ctx context.Context, namespace string, secretName string) (int, error)
 	Delete(ctx context.Context, namespace string, secretName string) error
+	DeleteVersion(ctx context.Context, namespace string, secretName string, version int) error
 	Decorate(ctx context.Context, namespace string, secretName string, key string, value string) error
+	Prune(ctx context.Context, namespace string, keep int) error
 }
 
 // VersionedSecretStoreImpl contains the required fields to persist a secret
@@ -107,6 +130,11 @@ func (p VersionedSecretStoreImpl) UpdateSecretReferences(ctx context.Context, na
 			continue
 		}
 
+		// Environment variables get the raw data, which can't be decoded by the container
+		if _, ok := versionedSecret.GetAnnotations()[AnnotationEncoding]; ok && referencedByEnvs(podSpec.Containers, secretNameInSpec) {
+			return errors.Errorf("versioned secret %s in namespace %s is compressed and can't be used in environment variables, mount it as a volume instead", versionedSecret.GetName(), namespace)
+		}
+
 		// if the latest version is different than the current version in the spec, replace it
 		if versionedSecret.Name != secretNameInSpec {
 			replaceVolumesSecretRef(
@@ -121,16 +149,44 @@ func (p VersionedSecretStoreImpl) UpdateSecretReferences(ctx context.Context, na
 				versionedSecret.GetName(),
 			)
 		}
+
+		err = projectChunks(podSpec.Volumes, versionedSecret)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// Create creates a new version of the secret from secret data
-func (p VersionedSecretStoreImpl) Create(ctx context.Context, namespace string, secretName string, secretData map[string]string, labels map[string]string, sourceDescription string) error {
+// Create creates a new version of the secret from secret data, unless the latest version holds the same data
+// and labels. It returns the name of the created version, or of the latest one if it was kept.
+func (p VersionedSecretStoreImpl) Create(ctx context.Context, namespace string, secretName string, secretData map[string]string, labels map[string]string, sourceDescription string) (string, error) {
 	currentVersion, err := p.getGreatestVersion(ctx, namespace, secretName)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	hash, err := contentHash(secretData)
+	if err != nil {
+		return "", err
+	}
+
+	if currentVersion > 0 {
+		latest, err := p.Get(ctx, namespace, secretName, currentVersion)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return "", errors.Wrapf(err, "failed to get version %d of secret '%s'", currentVersion, secretName)
+		}
+		if err == nil {
+			latestHash, err := secretContentHash(latest)
+			if err != nil {
+				return "", err
+			}
+			if latestHash == hash && hasLabels(latest.GetLabels(), labels) {
+				ctxlog.Debugf(ctx, "Skip creating a new version of secret '%s': data and labels are identical to version %d", secretName, currentVersion)
+				return latest.GetName(), nil
+			}
+		}
 	}
 
 	version := currentVersion + 1
@@ -139,22 +195,60 @@ func (p VersionedSecretStoreImpl) Create(ctx context.Context, namespace string,
 
 	generatedSecretName, err := generateSecretName(secretName, version)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	encoded, err := encodeData(generatedSecretName, secretData)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to encode version %d of secret '%s'", version, secretName)
+	}
+
+	// Chunks are created first, so the version is complete once it exists
+	for chunkName, chunk := range encoded.chunks {
+		err := p.client.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      chunkName,
+				Namespace: namespace,
+				Labels: map[string]string{
+					LabelSecretKind: VersionSecretChunkKind,
+					LabelChunkOf:    generatedSecretName,
+				},
+			},
+			Data: map[string][]byte{ChunkKey: chunk},
+		})
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to create chunk '%s' of secret '%s'", chunkName, secretName)
+		}
+	}
+
+	annotations := map[string]string{
+		AnnotationSourceDescription: sourceDescription,
+		AnnotationContentHash:       hash,
+	}
+	for key, value := range encoded.annotations {
+		annotations[key] = value
 	}
 
 	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      generatedSecretName,
-			Namespace: namespace,
-			Labels:    labels,
-			Annotations: map[string]string{
-				AnnotationSourceDescription: sourceDescription,
-			},
+			Name:        generatedSecretName,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
 		},
-		StringData: secretData,
+	}
+	if len(encoded.annotations) == 0 {
+		secret.StringData = secretData
+	} else {
+		secret.Data = encoded.data
+	}
+
+	err = p.client.Create(ctx, secret)
+	if err != nil {
+		return "", err
 	}
 
-	return p.client.Create(ctx, secret)
+	return generatedSecretName, nil
 }
 
 // Get returns a specific version of the secret
@@ -170,9 +264,35 @@ func (p VersionedSecretStoreImpl) Get(ctx context.Context, namespace string, dep
 		return nil, err
 	}
 
+	if _, ok := secret.GetAnnotations()[AnnotationEncoding]; ok {
+		data, err := p.Decode(ctx, secret)
+		if err != nil {
+			return nil, err
+		}
+		secret.Data = data
+	}
+
 	return secret, nil
 }
 
+// Decode returns the original data of a version, which was compressed or split into chunks
+// if it was large. Secrets which are not encoded are returned as they are.
+func (p VersionedSecretStoreImpl) Decode(ctx context.Context, secret *corev1.Secret) (map[string][]byte, error) {
+	data := map[string][]byte{}
+	for key, value := range secret.Data {
+		data[key] = value
+	}
+	for key, value := range secret.StringData {
+		data[key] = []byte(value)
+	}
+
+	data, err := decodeData(data, secret.GetAnnotations()[AnnotationEncoding], NewChunkGetter(ctx, p.client, secret.GetNamespace()))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode secret '%s/%s'", secret.GetNamespace(), secret.GetName())
+	}
+	return data, nil
+}
+
 // Latest returns the latest version of the secret
 func (p VersionedSecretStoreImpl) Latest(ctx context.Context, namespace string, secretName string) (*corev1.Secret, error) {
 	latestVersion, err := p.getGreatestVersion(ctx, namespace, secretName)
@@ -242,11 +362,153 @@ func (p VersionedSecretStoreImpl) Delete(ctx context.Context, namespace string,
 		if err := p.client.Delete(ctx, &secret); err != nil {
 			return err
 		}
+		if err := p.deleteChunks(ctx, namespace, secret.GetName()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteVersion removes a single version of the secret
+func (p VersionedSecretStoreImpl) DeleteVersion(ctx context.Context, namespace string, secretName string, version int) error {
+	name, err := generateSecretName(secretName, version)
+	if err != nil {
+		return err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+
+	if err := p.client.Delete(ctx, secret); err != nil {
+		return err
+	}
+
+	return p.deleteChunks(ctx, namespace, name)
+}
+
+// deleteChunks removes the chunk secrets of a version
+func (p VersionedSecretStoreImpl) deleteChunks(ctx context.Context, namespace string, name string) error {
+	secrets := &corev1.SecretList{}
+	err := p.client.List(ctx, &client.ListOptions{
+		Namespace:     namespace,
+		LabelSelector: labels.Set{LabelChunkOf: name}.AsSelector(),
+	}, secrets)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list chunks of secret '%s/%s'", namespace, name)
+	}
+
+	for _, secret := range secrets.Items {
+		// The label selector is checked again, it isn't applied by every client
+		if secret.GetLabels()[LabelChunkOf] != name {
+			continue
+		}
+		if err := p.client.Delete(ctx, &secret); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete chunk '%s/%s'", namespace, secret.GetName())
+		}
 	}
 
 	return nil
 }
 
+// Prune deletes the old versions of all versioned secrets in the namespace, the latest keep
+// versions of each secret are kept. Versions referenced by pods or stateful sets are kept,
+// too. BOSH manifest revisions, labelled with LabelManifestRevision, are skipped, their
+// history is limited by the BOSHDeployment.
+func (p VersionedSecretStoreImpl) Prune(ctx context.Context, namespace string, keep int) error {
+	secrets := &corev1.SecretList{}
+	err := p.client.List(ctx, &client.ListOptions{
+		Namespace:     namespace,
+		LabelSelector: labels.Set{LabelSecretKind: VersionSecretKind}.AsSelector(),
+	}, secrets)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list versioned secrets in namespace %s", namespace)
+	}
+
+	referenced, err := p.referencedSecrets(ctx, namespace)
+	if err != nil {
+		return err
+	}
+
+	// Collect the versions of every secret
+	versions := map[string][]int{}
+	for _, secret := range secrets.Items {
+		if _, ok := secret.GetLabels()[bdv1.LabelManifestRevision]; ok {
+			continue
+		}
+
+		prefix := names.GetPrefixFromVersionedSecretName(secret.GetName())
+		version, err := names.GetVersionFromVersionedSecretName(secret.GetName())
+		if prefix == "" || err != nil {
+			continue
+		}
+		versions[prefix] = append(versions[prefix], version)
+	}
+
+	for prefix, secretVersions := range versions {
+		if len(secretVersions) <= keep {
+			continue
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(secretVersions)))
+
+		for _, version := range secretVersions[keep:] {
+			name, err := generateSecretName(prefix, version)
+			if err != nil {
+				return err
+			}
+			if _, ok := referenced[name]; ok {
+				ctxlog.Debugf(ctx, "Keeping version %d of secret '%s/%s', it is still referenced", version, namespace, prefix)
+				continue
+			}
+
+			ctxlog.Debugf(ctx, "Pruning version %d of secret '%s/%s'", version, namespace, prefix)
+			err = p.DeleteVersion(ctx, namespace, prefix, version)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return errors.Wrapf(err, "failed to prune version %d of secret '%s/%s'", version, namespace, prefix)
+			}
+		}
+	}
+
+	return nil
+}
+
+// referencedSecrets returns the names of the secrets referenced by the pods and stateful sets in the namespace
+func (p VersionedSecretStoreImpl) referencedSecrets(ctx context.Context, namespace string) (map[string]struct{}, error) {
+	referenced := map[string]struct{}{}
+	addSecrets := func(spec corev1.PodSpec) {
+		// Secrets referenced by the environment of init containers are in use, too
+		spec.Containers = append(append([]corev1.Container{}, spec.Containers...), spec.InitContainers...)
+		_, secrets := owner.GetConfigNamesFromSpec(spec)
+		for name := range secrets {
+			referenced[name] = struct{}{}
+		}
+	}
+
+	pods := &corev1.PodList{}
+	err := p.client.List(ctx, client.InNamespace(namespace), pods)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list pods in namespace %s", namespace)
+	}
+	for _, pod := range pods.Items {
+		addSecrets(pod.Spec)
+	}
+
+	statefulSets := &v1beta2.StatefulSetList{}
+	err = p.client.List(ctx, client.InNamespace(namespace), statefulSets)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list stateful sets in namespace %s", namespace)
+	}
+	for _, statefulSet := range statefulSets.Items {
+		addSecrets(statefulSet.Spec.Template.Spec)
+	}
+
+	return referenced, nil
+}
+
 func (p VersionedSecretStoreImpl) listSecrets(ctx context.Context, namespace string, secretName string) ([]corev1.Secret, error) {
 	secretLabelsSet := labels.Set{
 		LabelSecretKind: VersionSecretKind,
@@ -293,6 +555,48 @@ func (p VersionedSecretStoreImpl) getGreatestVersion(ctx context.Context, namesp
 	return greatestVersion, nil
 }
 
+// contentHash returns the hash of the data of a version
+func contentHash(data map[string]string) (string, error) {
+	// Map keys are sorted when marshalling, so the same data always has the same hash
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal data for the content hash")
+	}
+	return fmt.Sprintf("%x", sha1.Sum(dataBytes)), nil
+}
+
+// secretContentHash returns the content hash of a secret, it is calculated for
+// versions created before the hash was recorded
+func secretContentHash(secret *corev1.Secret) (string, error) {
+	if hash, ok := secret.GetAnnotations()[AnnotationContentHash]; ok {
+		return hash, nil
+	}
+
+	data := map[string]string{}
+	for key, value := range secret.Data {
+		data[key] = string(value)
+	}
+	for key, value := range secret.StringData {
+		data[key] = value
+	}
+	return contentHash(data)
+}
+
+// hasLabels returns true if all labels are set to the same values in actual. Labels
+// like the manifest SHA1 of a version are part of its identity, a version with the
+// same data but other labels must not be skipped.
+func hasLabels(actual map[string]string, labels map[string]string) bool {
+	for key, value := range labels {
+		if key == LabelVersion || key == LabelSecretKind {
+			continue
+		}
+		if actualValue, ok := actual[key]; !ok || actualValue != value {
+			return false
+		}
+	}
+	return true
+}
+
 func generateSecretName(namePrefix string, version int) (string, error) {
 	proposedName := fmt.Sprintf("%s-v%d", namePrefix, version)
 
@@ -316,6 +620,13 @@ func replaceVolumesSecretRef(volumes []corev1.Volume, secretName string, version
 		if vol.VolumeSource.Secret != nil && vol.VolumeSource.Secret.SecretName == secretName {
 			vol.VolumeSource.Secret.SecretName = versionedSecretName
 		}
+		if vol.VolumeSource.Projected != nil {
+			for _, source := range vol.VolumeSource.Projected.Sources {
+				if source.Secret != nil && source.Secret.Name == secretName {
+					source.Secret.Name = versionedSecretName
+				}
+			}
+		}
 	}
 }
 
@@ -343,3 +654,23 @@ func replaceContainerEnvsSecretRef(containers []corev1.Container, secretName str
 		}
 	}
 }
+
+func referencedByEnvs(containers []corev1.Container, secretName string) bool {
+	for _, container := range containers {
+		for _, env := range container.EnvFrom {
+			if s := env.SecretRef; s != nil && s.Name == secretName {
+				return true
+			}
+		}
+
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if sRef := env.ValueFrom.SecretKeyRef; sRef != nil && sRef.Name == secretName {
+				return true
+			}
+		}
+	}
+	return false
+}
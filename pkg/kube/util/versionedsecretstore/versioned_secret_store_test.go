@@ -2,9 +2,15 @@ package versionedsecretstore_test
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
 	"strings"
 
+	"k8s.io/api/apps/v1beta2"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -12,7 +18,9 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	crc "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	bdv1 "code.cloudfoundry.org/cf-operator/pkg/kube/apis/boshdeployment/v1alpha1"
 	cfakes "code.cloudfoundry.org/cf-operator/pkg/kube/controllers/fakes"
 	"code.cloudfoundry.org/cf-operator/pkg/kube/util/owner"
 	. "code.cloudfoundry.org/cf-operator/pkg/kube/util/versionedsecretstore"
@@ -50,8 +58,9 @@ var _ = Describe("VersionedSecretStore", func() {
 				Namespace: "default",
 				UID:       "",
 				Labels: map[string]string{
-					LabelSecretKind: "versionedSecret",
-					LabelVersion:    "1",
+					LabelSecretKind:   "versionedSecret",
+					LabelVersion:      "1",
+					"deployment-name": secretNamePrefix,
 				},
 			},
 			Data: map[string][]byte{
@@ -69,8 +78,9 @@ name: fake-deployment-v1
 				Namespace: "default",
 				UID:       "",
 				Labels: map[string]string{
-					LabelSecretKind: "versionedSecret",
-					LabelVersion:    "2",
+					LabelSecretKind:   "versionedSecret",
+					LabelVersion:      "2",
+					"deployment-name": secretNamePrefix,
 				},
 			},
 			Data: map[string][]byte{
@@ -261,7 +271,7 @@ name: fake-deployment-v4
 					return nil
 				})
 
-				err := store.Create(ctx, namespace, secretNamePrefix, map[string]string{"manifest": `{"instance_groups":[{"instances":3,"name":"diego"},{"instances":2,"name":"mysql"}]}`}, secretLabels, exampleSourceDescription)
+				_, err := store.Create(ctx, namespace, secretNamePrefix, map[string]string{"manifest": `{"instance_groups":[{"instances":3,"name":"diego"},{"instances":2,"name":"mysql"}]}`}, secretLabels, exampleSourceDescription)
 				Expect(err).ToNot(HaveOccurred())
 			})
 		})
@@ -288,20 +298,359 @@ name: fake-deployment-v4
 					return nil
 				})
 
-				err := store.Create(ctx, namespace, secretNamePrefix, map[string]string{"manifest": `{"instance_groups":[{"instances":3,"name":"diego"},{"instances":2,"name":"mysql"}]}`}, secretLabels, exampleSourceDescription)
+				_, err := store.Create(ctx, namespace, secretNamePrefix, map[string]string{"manifest": `{"instance_groups":[{"instances":3,"name":"diego"},{"instances":2,"name":"mysql"}]}`}, secretLabels, exampleSourceDescription)
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the latest version holds the same data", func() {
+			var (
+				fakeClient crc.Client
+				data       map[string]string
+			)
+
+			BeforeEach(func() {
+				data = map[string]string{"manifest": string(secretV2.Data["manifest"])}
+				fakeClient = fake.NewFakeClient(secretV1, secretV2)
+				store = NewVersionedSecretStore(fakeClient)
+			})
+
+			It("should not create a new version", func() {
+				name, err := store.Create(ctx, namespace, secretNamePrefix, data, secretLabels, exampleSourceDescription)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(name).To(Equal(secretNamePrefix + "-v2"))
+
+				secrets, err := store.List(ctx, namespace, secretNamePrefix)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(secrets).To(HaveLen(2))
+			})
+
+			It("should create a new version if the labels changed", func() {
+				secretLabels[bdv1.LabelManifestSHA1] = "new-manifest-sha1"
+				_, err := store.Create(ctx, namespace, secretNamePrefix, data, secretLabels, exampleSourceDescription)
+				Expect(err).ToNot(HaveOccurred())
+
+				secret, err := store.Latest(ctx, namespace, secretNamePrefix)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(secret.Name).To(Equal(secretNamePrefix + "-v3"))
+				Expect(secret.Labels).To(HaveKeyWithValue(bdv1.LabelManifestSHA1, "new-manifest-sha1"))
+			})
+
+			It("should record the content hash and create a new version once the data changes", func() {
+				data["manifest"] = "changed"
+				name, err := store.Create(ctx, namespace, secretNamePrefix, data, secretLabels, exampleSourceDescription)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(name).To(Equal(secretNamePrefix + "-v3"))
+
+				secret, err := store.Latest(ctx, namespace, secretNamePrefix)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(secret.Name).To(Equal(secretNamePrefix + "-v3"))
+				Expect(secret.Annotations).To(HaveKey(AnnotationContentHash))
+
+				_, err = store.Create(ctx, namespace, secretNamePrefix, data, secretLabels, exampleSourceDescription)
+				Expect(err).ToNot(HaveOccurred())
+				secrets, err := store.List(ctx, namespace, secretNamePrefix)
 				Expect(err).ToNot(HaveOccurred())
+				Expect(secrets).To(HaveLen(3))
+			})
+		})
+
+		Context("when the data is large", func() {
+			var (
+				fakeClient crc.Client
+				data       map[string]string
+			)
+
+			secretNames := func() []string {
+				list := &corev1.SecretList{}
+				Expect(fakeClient.List(ctx, &crc.ListOptions{}, list)).To(Succeed())
+				result := []string{}
+				for _, secret := range list.Items {
+					result = append(result, secret.Name)
+				}
+				return result
+			}
+
+			BeforeEach(func() {
+				fakeClient = fake.NewFakeClient()
+				store = NewVersionedSecretStore(fakeClient)
+			})
+
+			It("compresses the data", func() {
+				data = map[string]string{"manifest": strings.Repeat("instances: 1\n", CompressionThreshold)}
+				_, err := store.Create(ctx, namespace, secretNamePrefix, data, secretLabels, exampleSourceDescription)
+				Expect(err).ToNot(HaveOccurred())
+
+				raw := &corev1.Secret{}
+				Expect(fakeClient.Get(ctx, crc.ObjectKey{Namespace: namespace, Name: secretNamePrefix + "-v1"}, raw)).To(Succeed())
+				Expect(raw.Annotations).To(HaveKeyWithValue(AnnotationEncoding, EncodingGzip))
+				Expect(raw.Data).To(HaveKeyWithValue(EncodingKey, []byte(EncodingGzip)))
+				Expect(len(raw.Data["manifest"])).To(BeNumerically("<", CompressionThreshold))
+
+				secret, err := store.Get(ctx, namespace, secretNamePrefix, 1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(secret.Data).To(HaveLen(1))
+				Expect(string(secret.Data["manifest"])).To(Equal(data["manifest"]))
+			})
+
+			It("fails to create versions with reserved keys", func() {
+				_, err := store.Create(ctx, namespace, secretNamePrefix, map[string]string{EncodingKey: "gzip"}, secretLabels, exampleSourceDescription)
+				Expect(err).To(MatchError(ContainSubstring("is reserved")))
+			})
+
+			It("mounts the encoding along with the selected keys of a compressed version", func() {
+				data = map[string]string{"manifest": strings.Repeat("instances: 1\n", CompressionThreshold)}
+				_, err := store.Create(ctx, namespace, secretNamePrefix, data, secretLabels, exampleSourceDescription)
+				Expect(err).ToNot(HaveOccurred())
+
+				podSpec := &corev1.PodSpec{
+					Volumes: []corev1.Volume{
+						{
+							Name: "manifest",
+							VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+								SecretName: secretNamePrefix + "-v0",
+								Items:      []corev1.KeyToPath{{Key: "manifest", Path: "manifest"}},
+							}},
+						},
+					},
+				}
+				err = store.UpdateSecretReferences(ctx, namespace, podSpec)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(podSpec.Volumes[0].Secret.Items).To(ConsistOf(
+					corev1.KeyToPath{Key: "manifest", Path: "manifest"},
+					corev1.KeyToPath{Key: EncodingKey, Path: EncodingKey},
+				))
+
+				By("removing the encoding for a later version, which is not compressed")
+				_, err = store.Create(ctx, namespace, secretNamePrefix, map[string]string{"manifest": "small"}, secretLabels, exampleSourceDescription)
+				Expect(err).ToNot(HaveOccurred())
+				err = store.UpdateSecretReferences(ctx, namespace, podSpec)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(podSpec.Volumes[0].Secret.Items).To(ConsistOf(corev1.KeyToPath{Key: "manifest", Path: "manifest"}))
+			})
+
+			It("fails to update pods using a compressed version in their environment", func() {
+				data = map[string]string{"manifest": strings.Repeat("instances: 1\n", CompressionThreshold)}
+				_, err := store.Create(ctx, namespace, secretNamePrefix, data, secretLabels, exampleSourceDescription)
+				Expect(err).ToNot(HaveOccurred())
+
+				podSpec := &corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "app",
+							Env: []corev1.EnvVar{
+								{
+									Name: "MANIFEST",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{Name: secretNamePrefix + "-v0"},
+											Key:                  "manifest",
+										},
+									},
+								},
+							},
+						},
+					},
+				}
+				err = store.UpdateSecretReferences(ctx, namespace, podSpec)
+				Expect(err).To(MatchError(ContainSubstring("can't be used in environment variables")))
+			})
+
+			It("splits data, which is too large for a single secret, into chunks", func() {
+				random := make([]byte, 3*ChunkSize)
+				rand.New(rand.NewSource(1)).Read(random)
+				data = map[string]string{"manifest": hex.EncodeToString(random), "other": "value"}
+
+				_, err := store.Create(ctx, namespace, secretNamePrefix, data, secretLabels, exampleSourceDescription)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(secretNames()).To(ContainElement(secretNamePrefix + "-v1-chunk-0"))
+				Expect(len(secretNames())).To(BeNumerically(">", 2))
+
+				secret, err := store.Latest(ctx, namespace, secretNamePrefix)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(secret.Name).To(Equal(secretNamePrefix + "-v1"))
+				Expect(string(secret.Data["manifest"])).To(Equal(data["manifest"]))
+				Expect(string(secret.Data["other"])).To(Equal("value"))
+
+				By("skipping unchanged data")
+				_, err = store.Create(ctx, namespace, secretNamePrefix, data, secretLabels, exampleSourceDescription)
+				Expect(err).ToNot(HaveOccurred())
+				secrets, err := store.List(ctx, namespace, secretNamePrefix)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(secrets).To(HaveLen(1))
+
+				By("deleting the chunks with the version")
+				err = store.DeleteVersion(ctx, namespace, secretNamePrefix, 1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(secretNames()).To(BeEmpty())
+			})
+			It("mounts the chunks along with the version, so pods can read them from the volume", func() {
+				random := make([]byte, 3*ChunkSize)
+				rand.New(rand.NewSource(1)).Read(random)
+				data = map[string]string{"manifest": hex.EncodeToString(random)}
+				_, err := store.Create(ctx, namespace, secretNamePrefix, data, secretLabels, exampleSourceDescription)
+				Expect(err).ToNot(HaveOccurred())
+
+				podSpec := &corev1.PodSpec{
+					Volumes: []corev1.Volume{
+						{
+							Name:         "manifest",
+							VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: secretNamePrefix + "-v0"}},
+						},
+					},
+				}
+				err = store.UpdateSecretReferences(ctx, namespace, podSpec)
+				Expect(err).ToNot(HaveOccurred())
+				projected := podSpec.Volumes[0].VolumeSource.Projected
+				Expect(projected).ToNot(BeNil())
+				Expect(projected.Sources[0].Secret.Name).To(Equal(secretNamePrefix + "-v1"))
+				Expect(len(projected.Sources)).To(BeNumerically(">", 2))
+
+				By("reading the file from the projected volume")
+				dir, err := ioutil.TempDir("", "versioned-secret")
+				Expect(err).ToNot(HaveOccurred())
+				defer os.RemoveAll(dir)
+				for _, source := range projected.Sources {
+					secret := &corev1.Secret{}
+					Expect(fakeClient.Get(ctx, crc.ObjectKey{Namespace: namespace, Name: source.Secret.Name}, secret)).To(Succeed())
+					for key, value := range secret.Data {
+						path := key
+						for _, item := range source.Secret.Items {
+							if item.Key == key {
+								path = item.Path
+							}
+						}
+						Expect(ioutil.WriteFile(filepath.Join(dir, path), value, 0644)).To(Succeed())
+					}
+				}
+				content, err := ReadMountedFile(filepath.Join(dir, "manifest"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(content)).To(Equal(data["manifest"]))
+
+				By("mounting a later version without chunks as a secret volume again")
+				_, err = store.Create(ctx, namespace, secretNamePrefix, map[string]string{"manifest": "small"}, secretLabels, exampleSourceDescription)
+				Expect(err).ToNot(HaveOccurred())
+				err = store.UpdateSecretReferences(ctx, namespace, podSpec)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(podSpec.Volumes[0].VolumeSource.Projected).To(BeNil())
+				Expect(podSpec.Volumes[0].VolumeSource.Secret.SecretName).To(Equal(secretNamePrefix + "-v2"))
 			})
 		})
 
 		Context("when the deployment name exceeds a length of 253 characters", func() {
 			It("should fail to create a new version", func() {
 				store = NewVersionedSecretStore(client)
-				err := store.Create(ctx, namespace, strings.Repeat("foobar", 42), map[string]string{"manifest": `{"instance_groups":[{"instances":3,"name":"diego"},{"instances":2,"name":"mysql"}]}`}, secretLabels, exampleSourceDescription)
+				_, err := store.Create(ctx, namespace, strings.Repeat("foobar", 42), map[string]string{"manifest": `{"instance_groups":[{"instances":3,"name":"diego"},{"instances":2,"name":"mysql"}]}`}, secretLabels, exampleSourceDescription)
 				Expect(err).To(HaveOccurred())
 			})
 		})
 	})
 
+	Describe("Prune", func() {
+		var fakeClient crc.Client
+
+		versionedSecret := func(name string, version int) *corev1.Secret {
+			return &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("%s-v%d", name, version),
+					Namespace: namespace,
+					Labels: map[string]string{
+						LabelSecretKind: VersionSecretKind,
+						LabelVersion:    fmt.Sprintf("%d", version),
+					},
+				},
+			}
+		}
+
+		secretNames := func() []string {
+			list := &corev1.SecretList{}
+			Expect(fakeClient.List(ctx, &crc.ListOptions{}, list)).To(Succeed())
+			result := []string{}
+			for _, secret := range list.Items {
+				result = append(result, secret.Name)
+			}
+			return result
+		}
+
+		BeforeEach(func() {
+			manifestRevision := versionedSecret("manifest", 1)
+			manifestRevision.Labels[bdv1.LabelManifestSHA1] = "abc"
+			manifestRevision.Labels[bdv1.LabelManifestRevision] = "1"
+
+			// Job outputs are labelled with the SHA1 of the manifest they were created for
+			jobOutput := func(version int) *corev1.Secret {
+				secret := versionedSecret("job-output", version)
+				secret.Labels[bdv1.LabelManifestSHA1] = "abc"
+				return secret
+			}
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: namespace},
+				Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{
+						{
+							Name:         "output",
+							VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "output-v1"}},
+						},
+					},
+				},
+			}
+			statefulSet := &v1beta2.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "statefulset", Namespace: namespace},
+				Spec: v1beta2.StatefulSetSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							InitContainers: []corev1.Container{
+								{
+									Name: "init",
+									EnvFrom: []corev1.EnvFromSource{
+										{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "output-v2"}}},
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			fakeClient = fake.NewFakeClient(
+				versionedSecret("output", 1),
+				versionedSecret("output", 2),
+				versionedSecret("output", 3),
+				versionedSecret("output", 4),
+				versionedSecret("output", 5),
+				versionedSecret("other", 1),
+				jobOutput(1),
+				jobOutput(2),
+				jobOutput(3),
+				manifestRevision,
+				pod,
+				statefulSet,
+			)
+			store = NewVersionedSecretStore(fakeClient)
+		})
+
+		It("keeps the latest and the referenced versions", func() {
+			err := store.Prune(ctx, namespace, 2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(secretNames()).To(ConsistOf("output-v1", "output-v2", "output-v4", "output-v5", "other-v1", "job-output-v2", "job-output-v3", "manifest-v1"))
+		})
+
+		It("prunes versions labelled with a manifest SHA1 which are no manifest revisions", func() {
+			err := store.Prune(ctx, namespace, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(secretNames()).ToNot(ContainElement("job-output-v1"))
+			Expect(secretNames()).ToNot(ContainElement("job-output-v2"))
+			Expect(secretNames()).To(ContainElement("job-output-v3"))
+		})
+
+		It("does not prune BOSH manifest revisions", func() {
+			err := store.Prune(ctx, namespace, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(secretNames()).To(ConsistOf("output-v1", "output-v2", "manifest-v1"))
+		})
+	})
+
 	Describe("Delete", func() {
 		Context("when a manifest with multiple version exists", func() {
 			It("should get rid of all versions of a manifest", func() {
@@ -334,6 +683,21 @@ name: fake-deployment-v4
 		})
 	})
 
+	Describe("DeleteVersion", func() {
+		It("should only delete the specified version", func() {
+			client.DeleteCalls(func(context context.Context, object runtime.Object, opts ...crc.DeleteOptionFunc) error {
+				secret := object.(*corev1.Secret)
+				Expect(secret.GetName()).To(Equal(fmt.Sprintf("%s-v%d", secretNamePrefix, 2)))
+				Expect(secret.GetNamespace()).To(Equal(namespace))
+				return nil
+			})
+
+			err := store.DeleteVersion(ctx, namespace, secretNamePrefix, 2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.DeleteCallCount()).To(Equal(1))
+		})
+	})
+
 	Describe("Decorate", func() {
 		Context("when there is a manifest with multiple versions", func() {
 			It("should decorate the latest version with the provided key and value", func() {
@@ -1106,7 +1106,12 @@ func (c *Catalog) DefaultExtendedJobWithSucceededJob(name string) (*ejv1.Extende
 				},
 			},
 		},
-		Status: batchv1.JobStatus{Succeeded: 1},
+		Status: batchv1.JobStatus{
+			Succeeded: 1,
+			Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+			},
+		},
 	}
 	pod := c.DefaultPod(name + "-pod")
 	pod.Labels = map[string]string{
@@ -1129,6 +1134,51 @@ func (c *Catalog) ErrandExtendedJob(name string) ejv1.ExtendedJob {
 	}
 }
 
+// ScheduledExtendedJob runs with the given cron expression
+func (c *Catalog) ScheduledExtendedJob(name string, cron string) ejv1.ExtendedJob {
+	cmd := []string{"sleep", "1"}
+	return ejv1.ExtendedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: ejv1.ExtendedJobSpec{
+			Trigger: ejv1.Trigger{
+				Strategy: ejv1.TriggerSchedule,
+				Schedule: &ejv1.ScheduleTrigger{Cron: cron},
+			},
+			Template: c.CmdPodTemplate(cmd),
+		},
+	}
+}
+
+// ConfigChangeExtendedJob returns an extended job triggered by changes of the named config
+func (c *Catalog) ConfigChangeExtendedJob(name string, kind string, configName string) ejv1.ExtendedJob {
+	cmd := []string{"sleep", "1"}
+	return ejv1.ExtendedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: ejv1.ExtendedJobSpec{
+			Trigger: ejv1.Trigger{
+				Strategy: ejv1.TriggerConfigChange,
+				Config:   &ejv1.ConfigTrigger{Kind: kind, Name: configName},
+			},
+			Template: c.CmdPodTemplate(cmd),
+		},
+	}
+}
+
+// JobCompletionExtendedJob returns an extended job triggered by jobs of another extended job
+func (c *Catalog) JobCompletionExtendedJob(name string, eJobName string, when ejv1.JobOutcome) ejv1.ExtendedJob {
+	cmd := []string{"sleep", "1"}
+	return ejv1.ExtendedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: ejv1.ExtendedJobSpec{
+			Trigger: ejv1.Trigger{
+				Strategy: ejv1.TriggerJobCompletion,
+				Job:      &ejv1.JobCompletionTrigger{Name: eJobName, When: when},
+			},
+			Template: c.CmdPodTemplate(cmd),
+		},
+	}
+}
+
 // AutoErrandExtendedJob default values
 func (c *Catalog) AutoErrandExtendedJob(name string) ejv1.ExtendedJob {
 	cmd := []string{"sleep", "1"}